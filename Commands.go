@@ -0,0 +1,194 @@
+package main
+
+import (
+	"auth-service/config"
+	"auth-service/connections"
+	"auth-service/logging"
+	"auth-service/migrations"
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/policy"
+	"auth-service/repositories"
+	"auth-service/services"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// commands - служебные команды, запускаемые вместо сервера: auth-service <команда> [флаги].
+var commands = map[string]func(args []string) int{
+	"policy-test": policyTest,
+	"seed-users":  seedUsers,
+	"migrate":     migrate,
+}
+
+func runCommand(args []string) int {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		return 2
+	}
+	return cmd(args[1:])
+}
+
+// policyTest загружает политики каталога и выполняет тесты *_test.yml. Код выхода 1 при ошибках -
+// команда предназначена для CI.
+func policyTest(args []string) int {
+	fs := flag.NewFlagSet("policy-test", flag.ContinueOnError)
+	dir := fs.String("dir", "policies", "каталог с политиками и тестами")
+	verbose := fs.Bool("v", false, "выводить успешные тесты")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	engine, err := policy.LoadDir(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load policies: %s\n", err)
+		return 1
+	}
+	results, err := policy.RunTests(engine, *dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load tests: %s\n", err)
+		return 1
+	}
+
+	failed := 0
+	for _, r := range results {
+		if !r.Passed {
+			failed++
+			fmt.Printf("FAIL %s: %s: %s\n", r.File, r.Name, r.Message)
+		} else if *verbose {
+			fmt.Printf("ok   %s: %s (%s)\n", r.File, r.Name, r.Decision.Rule)
+		}
+	}
+	fmt.Printf("%d tests, %d failed\n", len(results), failed)
+	if failed > 0 || len(results) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// migrate управляет схемой базы из конфигурации: migrate up|down|status [флаги].
+// up применяет все миграции, down откатывает -steps последних, status печатает состояние
+// миграций. Код выхода status - 1, если схема отстаёт от сборки.
+func migrate(args []string) int {
+	if len(args) == 0 || !slices.Contains([]string{"up", "down", "status"}, args[0]) {
+		fmt.Fprintln(os.Stderr, "usage: migrate up|down|status [-config file] [-steps n]")
+		return 2
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	configPath := fs.String("config", "config/config.yml", "файл конфигурации")
+	steps := fs.Int("steps", 1, "количество откатываемых миграций для down")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if _, err := loadConfig(*configPath); err != nil {
+		return 1
+	}
+	if err := connections.Connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "connect: %s\n", err)
+		return 1
+	}
+
+	switch action {
+	case "up":
+		if err := models.Migrate(); err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %s\n", err)
+			return 1
+		}
+	case "down":
+		rolledBack, err := migrations.Down(connections.DB, *steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %s\n", err)
+			return 1
+		}
+	case "status":
+		states, err := migrations.Status(connections.DB)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "status: %s\n", err)
+			return 1
+		}
+		pending := 0
+		for _, s := range states {
+			switch {
+			case s.Unknown:
+				fmt.Printf("%04d_%s\tapplied %s, unknown to this build\n", s.Version, s.Name, s.AppliedAt.Format(time.RFC3339))
+			case s.AppliedAt != nil:
+				fmt.Printf("%04d_%s\tapplied %s\n", s.Version, s.Name, s.AppliedAt.Format(time.RFC3339))
+			default:
+				pending++
+				fmt.Printf("%04d_%s\tpending\n", s.Version, s.Name)
+			}
+		}
+		if pending > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// seedUsers создаёт тестовых пользователей в арендаторе и печатает их GUID. Команда для
+// разработки: в рабочем окружении пользователи приходят через приглашения.
+func seedUsers(args []string) int {
+	fs := flag.NewFlagSet("seed-users", flag.ContinueOnError)
+	configPath := fs.String("config", "config/config.yml", "файл конфигурации")
+	tenantSlug := fs.String("tenant", consts.DefaultTenant, "арендатор, в который добавляются пользователи")
+	count := fs.Int("count", 0, "количество пользователей, по умолчанию usr.count из конфигурации")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	c, err := loadConfig(*configPath)
+	if err != nil {
+		return 1
+	}
+	if *count <= 0 {
+		*count = c.Usr.Count
+	}
+	if err := connections.Connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "connect: %s\n", err)
+		return 1
+	}
+	if err := models.Migrate(); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %s\n", err)
+		return 1
+	}
+
+	userRepo := repositories.NewUserRepository(connections.DB)
+	sessions := repositories.NewTokenRepository(connections.DB)
+	tenant, err := services.NewTenantService(repositories.NewTenantRepository(connections.DB), userRepo, sessions).GetTenant(*tenantSlug)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tenant %q not found\n", *tenantSlug)
+		return 1
+	}
+	users, err := services.NewUserService(userRepo, sessions).NewUsers(tenant.ID, *count)
+	for _, u := range users {
+		if u.CreatedAt.IsZero() {
+			break
+		}
+		fmt.Println(u.Guid)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "create users: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// loadConfig читает конфигурацию команды и настраивает по ней журнал.
+func loadConfig(path string) (*config.Config, error) {
+	c, err := config.Load(path)
+	if err == nil {
+		err = logging.Setup(*c)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return c, err
+}
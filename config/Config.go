@@ -3,13 +3,47 @@ package config
 import (
 	"fmt"
 	"gopkg.in/yaml.v3"
-	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 var config Config
 
 type Config struct {
+	Storage struct {
+		Driver   string `yaml:"driver"`
+		Sessions string `yaml:"sessions"`
+	}
+	Migrations struct {
+		OnStart string `yaml:"on_start"`
+	}
+	Scheduler struct {
+		Enabled       bool          `yaml:"enabled"`
+		PurgeInterval time.Duration `yaml:"purge_interval"`
+		Retention     time.Duration `yaml:"retention"`
+		BatchSize     int           `yaml:"batch_size"`
+	}
+	Logging struct {
+		Level  string `yaml:"level"`
+		Format string `yaml:"format"`
+	}
+	Tracing struct {
+		Exporter    string  `yaml:"exporter"`
+		Endpoint    string  `yaml:"endpoint"`
+		Insecure    bool    `yaml:"insecure"`
+		ServiceName string  `yaml:"service_name"`
+		SampleRatio float64 `yaml:"sample_ratio"`
+	}
+	Sqlite struct {
+		Path string `yaml:"path"`
+	}
+	Redis struct {
+		Addr     string `yaml:"addr"`
+		Password string `yaml:"password"`
+		DB       int    `yaml:"db"`
+		Prefix   string `yaml:"prefix"`
+	}
 	Postgres struct {
 		Host     string `yaml:"host"`
 		Port     uint   `yaml:"port"`
@@ -22,17 +56,55 @@ type Config struct {
 		Prefix string `yaml:"prefix"`
 		Port   string `yaml:"port"`
 		Name   string `yaml:"name"`
+		// ShutdownTimeout - срок остановки: завершение запросов, отправка webhook'ов, задачи
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+		// ShutdownDelay - сколько после сигнала /readyz отвечает 503, а запросы ещё принимаются
+		ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+		// Dev - режим разработки: без jwt.private_key_path ключ id_token создаётся при запуске.
+		// Переопределяется переменной окружения AUTH_DEV
+		Dev bool `yaml:"dev"`
 	}
 	Jwt struct {
-		SecretKey string `yaml:"secret_key"`
-		Issuer    string `yaml:"issuer"`
+		SecretKey      string   `yaml:"secret_key"`
+		Issuer         string   `yaml:"issuer"`
+		PrivateKeyPath string   `yaml:"private_key_path"`
+		Audience       []string `yaml:"audience"`
+		DefaultScopes  []string `yaml:"default_scopes"`
 	}
 	Webhook struct {
 		Url string `yaml:"url"`
+		// Workers - одновременных отправок в реплике
+		Workers int `yaml:"workers"`
+		// MaxAttempts - попыток, после которых доставка переходит в dead
+		MaxAttempts int `yaml:"max_attempts"`
+		// Backoff - пауза перед первым повтором; каждый следующий вдвое дольше, до MaxBackoff
+		Backoff    time.Duration `yaml:"backoff"`
+		MaxBackoff time.Duration `yaml:"max_backoff"`
+		// Timeout ограничивает одну попытку
+		Timeout time.Duration `yaml:"timeout"`
+		// PollInterval - как часто воркеры проверяют очередь на доставки других реплик и повторы
+		PollInterval time.Duration `yaml:"poll_interval"`
 	}
 	Usr struct {
 		Count int `yaml:"count"`
 	}
+	Admin struct {
+		ApiKey string `yaml:"api_key"`
+	}
+	Policy struct {
+		Dir string `yaml:"dir"`
+	}
+	Notifier struct {
+		Type string `yaml:"type"`
+		Url  string `yaml:"url"`
+		Smtp struct {
+			Host     string `yaml:"host"`
+			Port     int    `yaml:"port"`
+			Username string `yaml:"username"`
+			Password string `yaml:"password"`
+			From     string `yaml:"from"`
+		} `yaml:"smtp"`
+	}
 }
 
 func GetConfig() *Config {
@@ -53,19 +125,74 @@ func (c Config) GetPostgresDsn() string {
 func Load(configPath string) (*Config, error) {
 	content, err := os.ReadFile(configPath)
 	if err != nil {
-		log.Fatalf("Error read c file: %s", err)
-		return nil, err
+		return nil, fmt.Errorf("read config: %w", err)
 	}
 	var c Config
 	err = yaml.Unmarshal(content, &c)
 	if err != nil {
-		log.Fatalf("Error parse c file: %s", err)
-		return nil, err
+		return nil, fmt.Errorf("parse config %s: %w", configPath, err)
 	}
 
+	if c.Sqlite.Path == "" {
+		c.Sqlite.Path = "auth-service.db"
+	}
+	if c.Redis.Addr == "" {
+		c.Redis.Addr = "localhost:6379"
+	}
+	if c.Redis.Prefix == "" {
+		c.Redis.Prefix = "auth:"
+	}
+	if c.Application.ShutdownTimeout <= 0 {
+		c.Application.ShutdownTimeout = 30 * time.Second
+	}
+	if c.Logging.Level == "" {
+		c.Logging.Level = "info"
+	}
+	if c.Logging.Format == "" {
+		c.Logging.Format = "json"
+	}
+	if c.Tracing.ServiceName == "" {
+		c.Tracing.ServiceName = "auth-service"
+	}
+	if c.Tracing.SampleRatio <= 0 {
+		c.Tracing.SampleRatio = 1
+	}
+	if c.Scheduler.PurgeInterval <= 0 {
+		c.Scheduler.PurgeInterval = 10 * time.Minute
+	}
+	if c.Scheduler.Retention <= 0 {
+		c.Scheduler.Retention = 24 * time.Hour
+	}
+	if c.Scheduler.BatchSize <= 0 {
+		c.Scheduler.BatchSize = 1000
+	}
+	if c.Webhook.Workers <= 0 {
+		c.Webhook.Workers = 4
+	}
+	if c.Webhook.MaxAttempts <= 0 {
+		c.Webhook.MaxAttempts = 10
+	}
+	if c.Webhook.Backoff <= 0 {
+		c.Webhook.Backoff = 5 * time.Second
+	}
+	if c.Webhook.MaxBackoff <= 0 {
+		c.Webhook.MaxBackoff = time.Hour
+	}
+	if c.Webhook.Timeout <= 0 {
+		c.Webhook.Timeout = 10 * time.Second
+	}
+	if c.Webhook.PollInterval <= 0 {
+		c.Webhook.PollInterval = time.Second
+	}
 	if c.Postgres.Database == "" {
 		c.Postgres.Database = c.Postgres.User
 	}
+	// режим разработки включается для локального запуска без правки общей конфигурации
+	if v, ok := os.LookupEnv("AUTH_DEV"); ok {
+		if c.Application.Dev, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("AUTH_DEV: %w", err)
+		}
+	}
 	config = c
 	return &c, err
 }
@@ -0,0 +1,21 @@
+package connections
+
+import "errors"
+
+// Close закрывает пул соединений с базой и клиент Redis, если они открыты.
+func Close() error {
+	var errs []error
+	if DB != nil {
+		if pool, err := DB.DB(); err != nil {
+			errs = append(errs, err)
+		} else if err := pool.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if Redis != nil {
+		if err := Redis.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
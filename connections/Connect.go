@@ -0,0 +1,23 @@
+package connections
+
+import (
+	"auth-service/config"
+	"fmt"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Connect подключает DB к хранилищу storage.driver из конфигурации, по умолчанию - PostgreSQL.
+func Connect() error {
+	switch driver := config.GetConfig().Storage.Driver; driver {
+	case "", DriverPostgres:
+		return ConnectPostgres()
+	case DriverSQLite:
+		return ConnectSQLite()
+	default:
+		return fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
@@ -0,0 +1,16 @@
+package connections
+
+import "context"
+
+// PingDatabase проверяет, что пул DB может получить соединение и база отвечает.
+func PingDatabase(ctx context.Context) error {
+	pool, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return pool.PingContext(ctx)
+}
+
+func PingRedis(ctx context.Context) error {
+	return Redis.Ping(ctx).Err()
+}
@@ -2,9 +2,9 @@ package connections
 
 import (
 	"auth-service/config"
+	"auth-service/logging"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
@@ -13,7 +13,7 @@ func ConnectPostgres() error {
 	c := config.GetConfig()
 	dsn := c.GetPostgresDsn()
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: logging.Gorm(),
 	})
 
 	if err != nil {
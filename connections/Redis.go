@@ -0,0 +1,31 @@
+package connections
+
+import (
+	"auth-service/config"
+	"context"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	SessionsDatabase = "database"
+	SessionsRedis    = "redis"
+)
+
+var Redis *redis.Client
+
+// ConnectRedis подключает Redis к серверу из раздела redis конфигурации.
+func ConnectRedis() error {
+	c := config.GetConfig()
+	client := redis.NewClient(&redis.Options{
+		Addr:     c.Redis.Addr,
+		Password: c.Redis.Password,
+		DB:       c.Redis.DB,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close()
+		return err
+	}
+
+	Redis = client
+	return nil
+}
@@ -0,0 +1,144 @@
+package connections
+
+import (
+	"auth-service/config"
+	"auth-service/logging"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// ConnectSQLite подключает DB к файлу sqlite.path.
+func ConnectSQLite() error {
+	db, err := OpenSQLite(config.GetConfig().Sqlite.Path)
+	if err != nil {
+		return err
+	}
+
+	DB = db
+	return nil
+}
+
+// OpenSQLite открывает файл path (драйвер без cgo). SQLite хранит время текстом и
+// сравнивает его как строки, поэтому все значения time.Time в запросах приводятся к UTC:
+// иначе метки с разными смещениями (время сервера, летнее время) сравнивались бы неверно.
+func OpenSQLite(path string) (*gorm.DB, error) {
+	conn, err := sql.Open(sqlite.DriverName, sqliteDsn(path))
+	if err != nil {
+		return nil, err
+	}
+	// одна запись за раз: иначе параллельные транзакции получают SQLITE_BUSY
+	conn.SetMaxOpenConns(1)
+
+	db, err := gorm.Open(sqlite.Dialector{Conn: &utcPool{db: conn}}, &gorm.Config{
+		Logger:  logging.Gorm(),
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func sqliteDsn(path string) string {
+	q := url.Values{}
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	return "file:" + path + "?" + q.Encode()
+}
+
+// utcPool - gorm.ConnPool поверх *sql.DB, приводящий аргументы запросов time.Time к UTC.
+type utcPool struct {
+	db *sql.DB
+}
+
+func (p *utcPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.db.PrepareContext(ctx, query)
+}
+
+func (p *utcPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.db.ExecContext(ctx, query, utc(args)...)
+}
+
+func (p *utcPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.db.QueryContext(ctx, query, utc(args)...)
+}
+
+func (p *utcPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.db.QueryRowContext(ctx, query, utc(args)...)
+}
+
+func (p *utcPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &utcTx{tx: tx}, nil
+}
+
+func (p *utcPool) GetDBConn() (*sql.DB, error) {
+	return p.db, nil
+}
+
+type utcTx struct {
+	tx *sql.Tx
+}
+
+func (t *utcTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.tx.PrepareContext(ctx, query)
+}
+
+func (t *utcTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, query, utc(args)...)
+}
+
+func (t *utcTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, query, utc(args)...)
+}
+
+func (t *utcTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, query, utc(args)...)
+}
+
+func (t *utcTx) StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	return t.tx.StmtContext(ctx, stmt)
+}
+
+func (t *utcTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *utcTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// utc заменяет значения времени (в том числе за driver.Valuer, например gorm.DeletedAt) на UTC.
+func utc(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC()
+		case *time.Time:
+			if v != nil {
+				args[i] = v.UTC()
+			}
+		case driver.Valuer:
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+				continue
+			}
+			if value, err := v.Value(); err == nil {
+				if t, ok := value.(time.Time); ok {
+					args[i] = t.UTC()
+				}
+			}
+		}
+	}
+	return args
+}
@@ -0,0 +1,66 @@
+// Package lifecycle - остановка сервиса: при сигнале выполняются зарегистрированные шаги
+// в обратном порядке регистрации (как defer) с общим сроком.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+type Manager struct {
+	mu       sync.Mutex
+	hooks    []hook
+	draining atomic.Bool
+}
+
+func New() *Manager {
+	return &Manager{}
+}
+
+// OnShutdown регистрирует шаг остановки. Шаги выполняются в обратном порядке: ресурс,
+// созданный раньше (подключение к базе), закрывается после тех, кто им пользуется.
+func (m *Manager) OnShutdown(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Draining сообщает, что началась остановка и новые запросы принимать не следует.
+func (m *Manager) Draining() bool {
+	return m.draining.Load()
+}
+
+// Shutdown выполняет все шаги со сроком ctx, даже если предыдущие завершились ошибкой
+// или срок истёк, и возвращает их ошибки.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	if !m.draining.CompareAndSwap(false, true) {
+		return errors.New("shutdown already in progress")
+	}
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		started := time.Now()
+		if err := h.stop(ctx); err != nil {
+			slog.Error("Shutdown step failed", "step", h.name, "duration", time.Since(started).Round(time.Millisecond).String(), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		slog.Info("Shutdown step done", "step", h.name, "duration", time.Since(started).Round(time.Millisecond).String())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,150 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Redacted заменяет в журнале значения секретов.
+const Redacted = "[REDACTED]"
+
+// secretKeys - атрибуты, значения которых не пишутся в журнал целиком (после normalizeKey).
+var secretKeys = map[string]bool{
+	"authorization":     true,
+	"password":          true,
+	"secret":            true,
+	"token":             true,
+	"codeverifier":      true,
+	"authorizationcode": true,
+	"devicecode":        true,
+	"apikey":            true,
+	"xadminkey":         true,
+	"cookie":            true,
+}
+
+// secretPatterns - секреты внутри строк: сообщения ошибок и SQL не должны раскрывать их.
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// заголовки Authorization
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`), "$1 " + Redacted},
+	// JWT
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), Redacted},
+	// refresh токены selector.verifier
+	{regexp.MustCompile(`\b[A-Za-z0-9_-]{22}\.[A-Za-z0-9_-]{43}\b`), Redacted},
+	// хеши bcrypt
+	{regexp.MustCompile(`\$2[aby]?\$\d{2}\$[./A-Za-z0-9]{53}`), Redacted},
+}
+
+// Redact маскирует секреты в s.
+func Redact(s string) string {
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// isSecretKey сообщает, является ли key именем секрета. Имена сравниваются без регистра,
+// "_" и "-", поэтому поля структур (RefreshToken) совпадают с атрибутами (refresh_token).
+func isSecretKey(key string) bool {
+	key = normalizeKey(key)
+	return secretKeys[key] || strings.HasSuffix(key, "token") || strings.HasSuffix(key, "secret") ||
+		strings.HasSuffix(key, "password")
+}
+
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+// maxDepth - глубина вложенности групп, глубже которой значения не пишутся: ссылки
+// структур друг на друга не должны зацикливать обход.
+const maxDepth = 8
+
+// redactAttr - slog.HandlerOptions.ReplaceAttr: атрибуты с именами секретов заменяются целиком,
+// в остальных строках и ошибках маскируются известные форматы секретов. Структуры, map и срезы
+// из slog.Any превращаются в группы: обработчик вызывает redactAttr для каждого их поля, так что
+// секреты маскируются на любой глубине. Группы и LogValuer обработчик раскрывает сам.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if isSecretKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(Redact(a.Value.String()))
+	case slog.KindAny:
+		if len(groups) >= maxDepth {
+			return slog.String(a.Key, Redacted)
+		}
+		a.Value = redactAny(a.Value.Any())
+	}
+	return a
+}
+
+// redactAny представляет значение slog.Any так, чтобы redactAttr мог проверить его содержимое.
+func redactAny(v any) slog.Value {
+	switch v := v.(type) {
+	case nil:
+		return slog.AnyValue(nil)
+	case error:
+		return slog.StringValue(Redact(v.Error()))
+	case fmt.Stringer:
+		return slog.StringValue(Redact(v.String()))
+	case []byte:
+		return slog.StringValue(Redact(string(v)))
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return slog.AnyValue(nil)
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		return slog.StringValue(Redact(rv.String()))
+	case reflect.Struct:
+		return slog.GroupValue(structAttrs(rv)...)
+	case reflect.Map:
+		attrs := make([]slog.Attr, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			attrs = append(attrs, slog.Any(fmt.Sprint(key.Interface()), rv.MapIndex(key).Interface()))
+		}
+		slices.SortFunc(attrs, func(a, b slog.Attr) int { return strings.Compare(a.Key, b.Key) })
+		return slog.GroupValue(attrs...)
+	case reflect.Slice, reflect.Array:
+		attrs := make([]slog.Attr, rv.Len())
+		for i := range attrs {
+			attrs[i] = slog.Any(strconv.Itoa(i), rv.Index(i).Interface())
+		}
+		return slog.GroupValue(attrs...)
+	}
+	return slog.AnyValue(v)
+}
+
+// structAttrs - экспортируемые поля структуры под именами из тега json, как их записал бы
+// json.Marshal.
+func structAttrs(rv reflect.Value) []slog.Attr {
+	attrs := []slog.Attr{}
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		attrs = append(attrs, slog.Any(name, rv.Field(i).Interface()))
+	}
+	return attrs
+}
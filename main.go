@@ -4,19 +4,33 @@ import (
 	"auth-service/config"
 	"auth-service/connections"
 	_ "auth-service/docs"
+	"auth-service/lifecycle"
+	"auth-service/logging"
+	"auth-service/metrics"
+	"auth-service/migrations"
 	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/notifier"
 	"auth-service/repositories"
 	"auth-service/routers"
+	"auth-service/scheduler"
 	"auth-service/services"
-	"fmt"
+	"auth-service/tracing"
+	"auth-service/webhook"
+	"context"
+	"errors"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/log"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	fiberSwagger "github.com/swaggo/fiber-swagger"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 // @title Тестовое задание на позицию Junior Backend Developer
@@ -37,67 +51,254 @@ import (
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
+// @securityDefinitions.apikey AdminKeyAuth
+// @in header
+// @name X-Admin-Key
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	os.Exit(serve())
+}
+
+// serve запускает сервер и останавливает его по SIGINT/SIGTERM. Код выхода 1 - сервер не
+// смог слушать порт или остановка не уложилась в application.shutdown_timeout.
+func serve() int {
 	c := config.GetConfig()
-	app := Setup(c)
+	lc := lifecycle.New()
+	app, jobs, webhooks := Setup(c, lc)
 
 	if !fiber.IsChild() {
-
-		models.Migrate()
+		prepareSchema(c)
+		jobs.Start()
+		webhooks.Start()
 	}
 
+	listenErr := make(chan error, 1)
 	go func() {
-		if err := app.Listen("0.0.0.0:" + c.Application.Port); err != nil {
-			log.Fatal(err)
-		}
+		addr := "0.0.0.0:" + c.Application.Port
+		slog.Info("Listening", "addr", addr)
+		listenErr <- app.Listen(addr)
 	}()
 
-	// graceful shutdown (не дописанный) :D
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
-	_ = <-ch
-	log.Info("Graceful shutdown")
-	_ = app.Shutdown()
-	fmt.Println("Running cleanup tasks...")
-	// db.Close()
-	fmt.Println("Fiber was successful shutdown.")
+	code := 0
+	select {
+	case sig := <-ch:
+		slog.Info("Graceful shutdown", "signal", sig.String())
+	case err := <-listenErr:
+		slog.Error("Server stopped", "error", err)
+		code = 1
+	}
+	signal.Stop(ch)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Application.ShutdownTimeout)
+	defer cancel()
+	if err := lc.Shutdown(ctx); err != nil {
+		slog.Error("Shutdown incomplete", "error", err)
+		return 1
+	}
+	slog.Info("Shutdown complete")
+	return code
+}
+
+// prepareSchema готовит схему базы при запуске согласно migrations.on_start.
+func prepareSchema(c *config.Config) {
+	switch c.Migrations.OnStart {
+	case "", "up":
+		if err := models.Migrate(); err != nil {
+			logging.Fatal("Failed to migrate database", "error", err)
+		}
+	case "check":
+		if err := migrations.Check(connections.DB); err != nil {
+			logging.Fatal("Database schema is not up to date, run \"auth-service migrate up\"", "error", err)
+		}
+	case "off":
+	default:
+		logging.Fatal("Unknown migrations.on_start", "value", c.Migrations.OnStart)
+	}
 }
 
 func CheckConnections(err error) {
 	if err != nil {
-		log.Fatalf("Fatal connection error: %v", err)
+		logging.Fatal("Fatal connection error", "error", err)
 	}
 }
 
-func Setup(c *config.Config) *fiber.App {
+// Setup собирает приложение и регистрирует в lc шаги остановки: в обратном порядке
+// выполняются завершение HTTP запросов, фоновых задач, отправка webhook'ов и span'ов и
+// закрытие соединений. Фоновые задачи и отправку webhook'ов запускает вызывающий код после
+// подготовки схемы.
+func Setup(c *config.Config, lc *lifecycle.Manager) (*fiber.App, *scheduler.Scheduler, *webhook.Dispatcher) {
 	_, err := config.Load("config/config.yml")
 	CheckConnections(err)
-	CheckConnections(connections.ConnectPostgres())
+	CheckConnections(logging.Setup(*c))
+	CheckConnections(connections.Connect())
+	CheckConnections(connections.DB.Use(metrics.GormPlugin{}))
+	lc.OnShutdown("connections", func(context.Context) error { return connections.Close() })
+	shutdownTracing, err := tracing.Setup(*c)
+	CheckConnections(err)
+	lc.OnShutdown("tracing", shutdownTracing)
+	CheckConnections(connections.DB.Use(tracing.GormPlugin{}))
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(routers.RequestID())
+	app.Use(routers.RequestInfo())
+	app.Use(routers.Tracing())
+	app.Use(routers.Metrics())
 
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:8080, http://127.0.0.1:8080",
 		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH",
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-Admin-Key, X-Tenant",
 		AllowCredentials: true,
 	}))
 
-	logg := logger.New(logger.Config{
-		Format: "${ip}\t- -\t[${time}]\t\"${method} ${path} ${protocol}\" ${status} ${bytesSent} ${referer} ${ua} ${latency} ${error}\n",
-	})
 	app.Get("/swagger/*", fiberSwagger.WrapHandler)
-	app.Use(logg)
-	api := app.Group("/api")
+	// пробы Kubernetes и сбор метрик приходят каждые несколько секунд и не пишутся в журнал
+	app.Use(routers.AccessLog("/healthz", "/readyz", "/metrics"))
 
-	TokenRepository := repositories.NewTokenRepository()
-	TokenService := services.NewTokenService(TokenRepository, *c)
-	userRepo := repositories.NewUserRepository()
-	userService := services.NewUserService(userRepo)
-	handler := routers.NewTokenHandler(TokenService, userService)
-	Route(api, handler)
+	userRepo := repositories.NewUserRepository(connections.DB)
+	TokenRepository := sessionRepository(c)
+	userService := services.NewUserService(userRepo, TokenRepository)
+	rbacService := services.NewRBACService(repositories.NewRoleRepository(connections.DB))
+	tenantService := services.NewTenantService(repositories.NewTenantRepository(connections.DB), userRepo, TokenRepository)
+	auditService := services.NewAuditService(repositories.NewAuditRepository(connections.DB))
+	auditService.Start()
+	lc.OnShutdown("audit", auditService.Flush)
+	webhookRepo := repositories.NewWebhookRepository(connections.DB)
+	webhooks := webhook.NewDispatcher(webhookRepo, *c)
+	lc.OnShutdown("webhooks", webhooks.Flush)
+	webhookService := services.NewWebhookService(webhookRepo, webhooks.Wake, *c)
+	TokenService := services.NewTokenService(TokenRepository, rbacService, auditService, webhookService, *c)
+	clientRepo := repositories.NewOAuthClientRepository(connections.DB)
+	clientService := services.NewOAuthClientService(clientRepo)
+	handler := routers.NewTokenHandler(TokenService, userService, clientService, auditService, webhookService)
 
-	return app
+	keyService, err := services.NewKeyService(*c)
+	CheckConnections(err)
+	authCodeRepo := repositories.NewAuthorizationCodeRepository(connections.DB)
+	authService := services.NewAuthorizationService(authCodeRepo)
+	oidcService := services.NewOIDCService(keyService, userRepo, *c)
+	deviceRepo := repositories.NewDeviceAuthorizationRepository(connections.DB)
+	deviceService := services.NewDeviceService(deviceRepo, clientRepo)
+	exchangeService := services.NewTokenExchangeService(TokenService, *c)
+	oauthHandler := routers.NewOAuthHandler(
+		clientService,
+		TokenService,
+		authService,
+		oidcService,
+		userService,
+		deviceService,
+		exchangeService,
+	)
+	oidcHandler := routers.NewOIDCHandler(oidcService)
+	policyService, err := services.NewPolicyService(userRepo, rbacService, *c)
+	CheckConnections(err)
+	policyHandler := routers.NewPolicyHandler(policyService)
+	notify, err := notifier.New(*c)
+	CheckConnections(err)
+	invitationService := services.NewInvitationService(repositories.NewInvitationRepository(connections.DB), tenantService, notify, *c)
+	orgHandler := routers.NewOrgHandler(tenantService, invitationService)
+	orgAdmin := routers.RequireMemberRole(tenantService, consts.MemberRoleOwner, consts.MemberRoleAdmin)
+	jobs := newScheduler(c, services.NewPurgeService(TokenRepository, authCodeRepo, deviceRepo, webhookRepo, clientRepo, *c))
+	lc.OnShutdown("scheduler", jobs.Stop)
+	healthHandler := routers.NewHealthHandler(newHealthService(c, lc, keyService))
+
+	app.Get("/healthz", healthHandler.Liveness)
+	app.Get("/readyz", healthHandler.Readiness)
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+
+	// административный API общий для всех арендаторов и регистрируется до ResolveTenant
+	RouteAdmin(
+		app.Group("/api/admin", routers.AuditAdmin(auditService), routers.AdminAuth(c.Admin.ApiKey)),
+		routers.NewClientHandler(clientService),
+		routers.NewRoleHandler(rbacService, tenantService),
+		routers.NewTenantHandler(tenantService),
+		routers.NewUserHandler(userService, tenantService),
+		routers.NewJobHandler(jobs),
+		routers.NewAuditHandler(auditService, tenantService),
+		routers.NewWebhookHandler(webhookService),
+	)
+
+	app.Use(routers.ResolveTenant(tenantService))
+	app.Use(routers.RejectRevoked(TokenService))
+	// маршруты арендатора доступны и без префикса (Host или X-Tenant), и по пути /t/{tenant}
+	for _, root := range []fiber.Router{app, app.Group("/t/:tenant")} {
+		api := root.Group("/api")
+		Route(api, handler)
+		api.Post("/authorize", policyHandler.Authorize)
+		RouteOrg(api.Group("/org"), orgHandler, orgAdmin)
+		RouteOAuth(root.Group("/oauth"), oauthHandler)
+		RouteOIDC(root, oidcHandler)
+	}
+
+	lc.OnShutdown("http", func(ctx context.Context) error {
+		// /readyz уже отвечает 503; пока балансировщик убирает реплику, запросы ещё принимаются
+		select {
+		case <-time.After(c.Application.ShutdownDelay):
+		case <-ctx.Done():
+		}
+		return app.ShutdownWithContext(ctx)
+	})
+	return app, jobs, webhooks
+}
+
+// newHealthService собирает проверки /readyz. Во время остановки реплика не готова, чтобы
+// балансировщик перестал направлять на неё запросы.
+func newHealthService(c *config.Config, lc *lifecycle.Manager, keys *services.KeyService) *services.HealthService {
+	checks := []services.HealthCheck{
+		{Name: "database", Check: connections.PingDatabase},
+		{Name: "migrations", Check: func(ctx context.Context) error {
+			return migrations.Check(connections.DB.Session(&gorm.Session{Context: ctx, Logger: gormlogger.Discard}))
+		}},
+		{Name: "signing_keys", Check: keys.Check},
+		{Name: "shutdown", Check: func(context.Context) error {
+			if lc.Draining() {
+				return errors.New("shutting down")
+			}
+			return nil
+		}},
+	}
+	if c.Storage.Sessions == connections.SessionsRedis {
+		checks = append(checks, services.HealthCheck{Name: "redis", Check: connections.PingRedis})
+	}
+	return services.NewHealthService(checks...)
+}
+
+// newScheduler регистрирует фоновые задачи; при scheduler.enabled: false их нет.
+func newScheduler(c *config.Config, purge *services.PurgeService) *scheduler.Scheduler {
+	jobs := scheduler.New(scheduler.NewLocker(connections.DB))
+	if !c.Scheduler.Enabled {
+		return jobs
+	}
+	for name, run := range map[string]func(ctx context.Context) (int64, error){
+		"purge-sessions":              purge.Sessions,
+		"purge-denied-tokens":         purge.DeniedTokens,
+		"purge-authorization-codes":   purge.AuthorizationCodes,
+		"purge-device-authorizations": purge.DeviceAuthorizations,
+		"purge-webhook-deliveries":    purge.WebhookDeliveries,
+		"purge-client-assertions":     purge.ClientAssertions,
+	} {
+		jobs.Add(scheduler.Job{Name: name, Interval: c.Scheduler.PurgeInterval, Run: run})
+	}
+	return jobs
+}
+
+// sessionRepository - хранилище refresh сессий storage.sessions: база данных или Redis.
+func sessionRepository(c *config.Config) repositories.TokenRepository {
+	switch c.Storage.Sessions {
+	case "", connections.SessionsDatabase:
+		return repositories.NewTokenRepository(connections.DB)
+	case connections.SessionsRedis:
+		CheckConnections(connections.ConnectRedis())
+		connections.Redis.AddHook(tracing.RedisHook{})
+		return repositories.NewRedisTokenRepository(connections.Redis, c.Redis.Prefix)
+	}
+	logging.Fatal("Unknown sessions storage", "value", c.Storage.Sessions)
+	return nil
 }
 
 func Route(api fiber.Router, h *routers.TokenH) {
@@ -105,5 +306,76 @@ func Route(api fiber.Router, h *routers.TokenH) {
 	api.Post("/refresh", h.RefreshTokenHandler)
 	api.Post("/me", h.GetUser)
 	api.Post("/logout", h.Logout)
-	api.Get("/get-users-GUID", h.GetAllUsers) // этот маршрут сделан для проверяющего!
+}
+
+func RouteOAuth(oauth fiber.Router, h *routers.OAuthH) {
+	oauth.Get("/authorize", h.Authorize)
+	oauth.Post("/token", h.Token)
+	oauth.Post("/device_authorization", h.DeviceAuthorization)
+	oauth.Get("/device", h.GetDeviceVerification)
+	oauth.Post("/device", h.VerifyDevice)
+}
+
+func RouteOIDC(app fiber.Router, h *routers.OIDCH) {
+	app.Get("/.well-known/openid-configuration", h.Discovery)
+	app.Get("/.well-known/jwks.json", h.JWKS)
+	app.Get("/userinfo", h.UserInfo)
+	app.Post("/userinfo", h.UserInfo)
+}
+
+func RouteOrg(org fiber.Router, h *routers.OrgH, orgAdmin fiber.Handler) {
+	org.Post("/invitations/accept", h.AcceptInvitation)
+	org.Post("/invitations", orgAdmin, h.Invite)
+	org.Get("/invitations", orgAdmin, h.GetInvitations)
+	org.Delete("/invitations/:id", orgAdmin, h.RevokeInvitation)
+	org.Get("/members", orgAdmin, h.GetMembers)
+	org.Put("/members/:guid", orgAdmin, h.SetMemberRole)
+	org.Delete("/members/:guid", orgAdmin, h.RemoveMember)
+}
+
+func RouteAdmin(admin fiber.Router, clients *routers.ClientH, roles *routers.RoleH, tenants *routers.TenantH, users *routers.UserH, jobs *routers.JobH, audit *routers.AuditH, webhooks *routers.WebhookH) {
+	admin.Post("/clients", clients.CreateClient)
+	admin.Get("/clients", clients.GetClients)
+	admin.Get("/clients/:client_id", clients.GetClient)
+	admin.Put("/clients/:client_id", clients.UpdateClient)
+	admin.Delete("/clients/:client_id", clients.DeleteClient)
+	admin.Post("/clients/:client_id/secret", clients.RotateClientSecret)
+
+	admin.Post("/permissions", roles.CreatePermission)
+	admin.Get("/permissions", roles.GetPermissions)
+	admin.Delete("/permissions/:name", roles.DeletePermission)
+	admin.Post("/roles", roles.CreateRole)
+	admin.Get("/roles", roles.GetRoles)
+	admin.Get("/roles/:name", roles.GetRole)
+	admin.Put("/roles/:name", roles.UpdateRole)
+	admin.Delete("/roles/:name", roles.DeleteRole)
+	admin.Get("/users", users.SearchUsers)
+	admin.Get("/users/:guid", users.GetUser)
+	admin.Delete("/users/:guid", users.DeleteUser)
+	admin.Post("/users/:guid/disable", users.DisableUser)
+	admin.Post("/users/:guid/enable", users.EnableUser)
+	admin.Post("/users/:guid/logout", users.LogoutUser)
+	admin.Get("/sessions", users.GetSessions)
+	admin.Get("/users/:guid/roles", roles.GetUserRoles)
+	admin.Put("/users/:guid/roles", roles.SetUserRoles)
+
+	admin.Post("/tenants", tenants.CreateTenant)
+	admin.Get("/tenants", tenants.GetTenants)
+	admin.Get("/tenants/:slug", tenants.GetTenant)
+	admin.Put("/tenants/:slug", tenants.UpdateTenant)
+	admin.Delete("/tenants/:slug", tenants.DeleteTenant)
+	admin.Get("/tenants/:slug/members", tenants.GetMembers)
+	admin.Put("/tenants/:slug/members/:guid", tenants.AddMember)
+	admin.Delete("/tenants/:slug/members/:guid", tenants.RemoveMember)
+
+	admin.Get("/jobs", jobs.GetJobs)
+
+	admin.Get("/audit", audit.GetAuditEvents)
+	admin.Get("/audit/export", audit.ExportAuditEvents)
+	admin.Get("/audit/verify", audit.VerifyAuditChain)
+
+	admin.Get("/webhooks", webhooks.GetWebhookDeliveries)
+	admin.Post("/webhooks/replay", webhooks.ReplayDeadWebhooks)
+	admin.Get("/webhooks/:id", webhooks.GetWebhookDelivery)
+	admin.Post("/webhooks/:id/replay", webhooks.ReplayWebhookDelivery)
 }
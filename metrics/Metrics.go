@@ -0,0 +1,92 @@
+// Package metrics - метрики Prometheus, публикуемые на /metrics. Счётчики событий
+// увеличиваются там, где событие происходит: в сервисах, хендлерах и webhook.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "auth"
+
+// Причины отказа в выдаче (LoginFailures) и обновлении (RefreshFailures) токенов.
+const (
+	ReasonInvalidRequest   = "invalid_request"
+	ReasonInvalidToken     = "invalid_token"
+	ReasonNotFound         = "not_found"
+	ReasonPairMismatch     = "pair_mismatch"
+	ReasonUserDisabled     = "user_disabled"
+	ReasonInvalidScope     = "invalid_scope"
+	ReasonUserAgentChanged = "user_agent_changed"
+	ReasonClientNotFound   = "client_not_found"
+	ReasonSessionActive    = "session_active"
+	ReasonAlreadyRotated   = "already_rotated"
+	ReasonLogout           = "logout"
+	ReasonRotated          = "rotated"
+)
+
+var (
+	// TokensIssued - выданные токены: session - пара access/refresh, access - access токен
+	// OAuth гранта без refresh токена.
+	TokensIssued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_issued_total",
+		Help:      "Issued tokens by type (session, access).",
+	}, []string{"type"})
+
+	TokensRefreshed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_refreshed_total",
+		Help:      "Sessions rotated by refresh token.",
+	})
+
+	// TokensRevoked - отозванные access токены сессий по причине: logout, user_agent_changed,
+	// rotated (прежний access токен при обновлении).
+	TokensRevoked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_revoked_total",
+		Help:      "Revoked session access tokens by reason.",
+	}, []string{"reason"})
+
+	RefreshFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refresh_failures_total",
+		Help:      "Rejected token refresh requests by reason.",
+	}, []string{"reason"})
+
+	LoginFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Rejected token issuance requests by reason.",
+	}, []string{"reason"})
+
+	// WebhookDeliveries - попытки доставки webhook'ов: success, retry (неудача, будет повтор)
+	// или dead (неудача, попытки исчерпаны).
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by result (success, retry, dead).",
+	}, []string{"result"})
+
+	// HTTPDuration размечается шаблоном маршрута (/api/admin/users/:guid), а не путём запроса.
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP handler latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	BcryptDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bcrypt_duration_seconds",
+		Help:      "Time spent in bcrypt by operation (hash, compare).",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query time by operation and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "table"})
+)
@@ -0,0 +1,249 @@
+// Package migrations - версионные SQL миграции схемы базы данных. Файлы миграций встроены
+// в бинарный файл: {dialect}/{версия}_{имя}.up.sql и .down.sql, где dialect - имя
+// диалекта gorm (postgres, sqlite). Применённые версии хранятся в таблице schema_migrations.
+package migrations
+
+import (
+	"cmp"
+	"embed"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// lockID - ключ pg_advisory_lock, под которым миграции выполняет только одна реплика.
+const lockID = 7234918243
+
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// State - миграция и время её применения; AppliedAt nil - миграция не применена.
+// Unknown - версия применена, но неизвестна этой сборке (база новее бинарного файла).
+type State struct {
+	Version   uint
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+type applied struct {
+	Version   uint `gorm:"primaryKey"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (applied) TableName() string {
+	return "schema_migrations"
+}
+
+var historyTables = map[string]string{
+	"postgres": `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`,
+	"sqlite": `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at datetime NOT NULL
+	)`,
+}
+
+// Load возвращает миграции диалекта по возрастанию версии.
+func Load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %q", dialect)
+	}
+	byVersion := map[uint]*Migration{}
+	for _, e := range entries {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), ".")
+		number, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(number, 10, 32)
+		if !ok || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %q", e.Name())
+		}
+		content, err := files.ReadFile(dialect + "/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[uint(version)]
+		if m == nil {
+			m = &Migration{Version: uint(version), Name: name}
+			byVersion[uint(version)] = m
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+// Up применяет все неприменённые миграции и возвращает их. Каждая миграция выполняется
+// в своей транзакции вместе с записью в schema_migrations.
+func Up(db *gorm.DB) ([]Migration, error) {
+	var done []Migration
+	err := withLock(db, func(conn *gorm.DB, migrations []Migration) error {
+		for _, m := range migrations {
+			ran := false
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				// другой процесс мог применить миграцию, пока мы ждали блокировку
+				var count int64
+				if err := tx.Model(&applied{}).Where("version = ?", m.Version).Count(&count).Error; err != nil || count > 0 {
+					return err
+				}
+				if err := tx.Exec(m.Up).Error; err != nil {
+					return err
+				}
+				ran = true
+				return tx.Create(&applied{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			if ran {
+				done = append(done, m)
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down откатывает steps последних применённых миграций и возвращает их в порядке отката.
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	var done []Migration
+	err := withLock(db, func(conn *gorm.DB, migrations []Migration) error {
+		var history []applied
+		if err := conn.Order("version DESC").Limit(steps).Find(&history).Error; err != nil {
+			return err
+		}
+		for _, h := range history {
+			i := slices.IndexFunc(migrations, func(m Migration) bool { return m.Version == h.Version })
+			if i < 0 {
+				return fmt.Errorf("migration %04d_%s is not known to this build", h.Version, h.Name)
+			}
+			m := migrations[i]
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Down).Error; err != nil {
+					return err
+				}
+				return tx.Where("version = ?", m.Version).Delete(&applied{}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback %04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status возвращает состояние всех миграций сборки и применённых версий, неизвестных ей.
+func Status(db *gorm.DB) ([]State, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	history, err := readHistory(db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]State, 0, len(migrations))
+	for _, m := range migrations {
+		s := State{Version: m.Version, Name: m.Name}
+		if h, ok := history[m.Version]; ok {
+			s.AppliedAt = &h.AppliedAt
+			delete(history, m.Version)
+		}
+		states = append(states, s)
+	}
+	for _, h := range history {
+		states = append(states, State{Version: h.Version, Name: h.Name, AppliedAt: &h.AppliedAt, Unknown: true})
+	}
+	slices.SortFunc(states, func(a, b State) int { return cmp.Compare(a.Version, b.Version) })
+	return states, nil
+}
+
+// Check возвращает ErrSchemaBehind, если в базе применены не все миграции сборки.
+func Check(db *gorm.DB) error {
+	states, err := Status(db)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, s := range states {
+		if s.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migrations", ErrSchemaBehind, pending)
+	}
+	return nil
+}
+
+// withLock выполняет fn на одном соединении, создав таблицу schema_migrations. В PostgreSQL
+// соединение держит pg_advisory_lock, поэтому реплики, запущенные одновременно, выполняют
+// миграции по очереди. SQLite работает через одно соединение и блокировка не нужна.
+func withLock(db *gorm.DB, fn func(conn *gorm.DB, migrations []Migration) error) error {
+	dialect := db.Dialector.Name()
+	migrations, err := Load(dialect)
+	if err != nil {
+		return err
+	}
+	return db.Connection(func(conn *gorm.DB) error {
+		if dialect == "postgres" {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", lockID).Error; err != nil {
+				return err
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", lockID)
+		}
+		if err := conn.Exec(historyTables[dialect]).Error; err != nil {
+			return err
+		}
+		return fn(conn, migrations)
+	})
+}
+
+func readHistory(db *gorm.DB) (map[uint]applied, error) {
+	history := map[uint]applied{}
+	if !db.Migrator().HasTable(&applied{}) {
+		return history, nil
+	}
+	var rows []applied
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		history[r.Version] = r
+	}
+	return history, nil
+}
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS "denied_tokens" CASCADE;
+DROP TABLE IF EXISTS "invitations" CASCADE;
+DROP TABLE IF EXISTS "tenant_members" CASCADE;
+DROP TABLE IF EXISTS "tenants" CASCADE;
+DROP TABLE IF EXISTS "user_roles" CASCADE;
+DROP TABLE IF EXISTS "role_permissions" CASCADE;
+DROP TABLE IF EXISTS "roles" CASCADE;
+DROP TABLE IF EXISTS "permissions" CASCADE;
+DROP TABLE IF EXISTS "device_authorizations" CASCADE;
+DROP TABLE IF EXISTS "authorization_codes" CASCADE;
+DROP TABLE IF EXISTS "o_auth_clients" CASCADE;
+DROP TABLE IF EXISTS "users" CASCADE;
+DROP TABLE IF EXISTS "tokens" CASCADE;
@@ -0,0 +1,297 @@
+-- Схема, которую создавал AutoMigrate до версионных миграций. Базы, созданные им, приводятся
+-- к ней: таблицы и индексы создаются при отсутствии, недостающие столбцы добавляются.
+
+CREATE TABLE IF NOT EXISTS "tokens" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "tenant_id" bigint,
+    "user_guid" text,
+    "client_id" text,
+    "scopes" text,
+    "user_agent" text,
+    "ip_address" text,
+    "refresh_token" text,
+    "expires_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "tokens"
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "tenant_id" bigint,
+    ADD COLUMN IF NOT EXISTS "user_guid" text,
+    ADD COLUMN IF NOT EXISTS "client_id" text,
+    ADD COLUMN IF NOT EXISTS "scopes" text,
+    ADD COLUMN IF NOT EXISTS "user_agent" text,
+    ADD COLUMN IF NOT EXISTS "ip_address" text,
+    ADD COLUMN IF NOT EXISTS "refresh_token" text,
+    ADD COLUMN IF NOT EXISTS "expires_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_tokens_tenant_id" ON "tokens" ("tenant_id");
+CREATE INDEX IF NOT EXISTS "idx_tokens_deleted_at" ON "tokens" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "guid" text NOT NULL,
+    "name" text,
+    "email" text,
+    "email_verified" boolean,
+    "disabled" boolean NOT NULL DEFAULT false,
+    "permission_version" bigint NOT NULL DEFAULT 1,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    CONSTRAINT "uni_users_guid" UNIQUE ("guid")
+);
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "guid" text,
+    ADD COLUMN IF NOT EXISTS "name" text,
+    ADD COLUMN IF NOT EXISTS "email" text,
+    ADD COLUMN IF NOT EXISTS "email_verified" boolean,
+    ADD COLUMN IF NOT EXISTS "disabled" boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS "permission_version" bigint NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "o_auth_clients" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "client_id" text NOT NULL,
+    "name" text,
+    "secret_hash" text,
+    "token_endpoint_auth_method" text,
+    "grant_types" text,
+    "scopes" text,
+    "redirect_uris" text,
+    "public_key" text,
+    "access_token_lifetime" bigint,
+    "refresh_token_lifetime" bigint,
+    "exchange_audiences" text,
+    "allow_impersonation" boolean,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "o_auth_clients"
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "client_id" text,
+    ADD COLUMN IF NOT EXISTS "name" text,
+    ADD COLUMN IF NOT EXISTS "secret_hash" text,
+    ADD COLUMN IF NOT EXISTS "token_endpoint_auth_method" text,
+    ADD COLUMN IF NOT EXISTS "grant_types" text,
+    ADD COLUMN IF NOT EXISTS "scopes" text,
+    ADD COLUMN IF NOT EXISTS "redirect_uris" text,
+    ADD COLUMN IF NOT EXISTS "public_key" text,
+    ADD COLUMN IF NOT EXISTS "access_token_lifetime" bigint,
+    ADD COLUMN IF NOT EXISTS "refresh_token_lifetime" bigint,
+    ADD COLUMN IF NOT EXISTS "exchange_audiences" text,
+    ADD COLUMN IF NOT EXISTS "allow_impersonation" boolean;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_o_auth_clients_client_id" ON "o_auth_clients" ("client_id");
+CREATE INDEX IF NOT EXISTS "idx_o_auth_clients_deleted_at" ON "o_auth_clients" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "authorization_codes" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "code_hash" text NOT NULL,
+    "client_id" text,
+    "tenant_id" bigint,
+    "user_guid" text,
+    "redirect_uri" text,
+    "scopes" text,
+    "nonce" text,
+    "auth_time" timestamptz,
+    "code_challenge" text,
+    "code_challenge_method" text,
+    "expires_at" timestamptz,
+    "used" boolean,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "authorization_codes"
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "code_hash" text,
+    ADD COLUMN IF NOT EXISTS "client_id" text,
+    ADD COLUMN IF NOT EXISTS "tenant_id" bigint,
+    ADD COLUMN IF NOT EXISTS "user_guid" text,
+    ADD COLUMN IF NOT EXISTS "redirect_uri" text,
+    ADD COLUMN IF NOT EXISTS "scopes" text,
+    ADD COLUMN IF NOT EXISTS "nonce" text,
+    ADD COLUMN IF NOT EXISTS "auth_time" timestamptz,
+    ADD COLUMN IF NOT EXISTS "code_challenge" text,
+    ADD COLUMN IF NOT EXISTS "code_challenge_method" text,
+    ADD COLUMN IF NOT EXISTS "expires_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "used" boolean;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_authorization_codes_code_hash" ON "authorization_codes" ("code_hash");
+CREATE INDEX IF NOT EXISTS "idx_authorization_codes_deleted_at" ON "authorization_codes" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "device_authorizations" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "device_code_hash" text NOT NULL,
+    "user_code" text NOT NULL,
+    "client_id" text,
+    "scopes" text,
+    "status" text,
+    "tenant_id" bigint,
+    "user_guid" text,
+    "auth_time" timestamptz,
+    "interval" bigint,
+    "last_polled_at" timestamptz,
+    "expires_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "device_authorizations"
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "deleted_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "device_code_hash" text,
+    ADD COLUMN IF NOT EXISTS "user_code" text,
+    ADD COLUMN IF NOT EXISTS "client_id" text,
+    ADD COLUMN IF NOT EXISTS "scopes" text,
+    ADD COLUMN IF NOT EXISTS "status" text,
+    ADD COLUMN IF NOT EXISTS "tenant_id" bigint,
+    ADD COLUMN IF NOT EXISTS "user_guid" text,
+    ADD COLUMN IF NOT EXISTS "auth_time" timestamptz,
+    ADD COLUMN IF NOT EXISTS "interval" bigint,
+    ADD COLUMN IF NOT EXISTS "last_polled_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "expires_at" timestamptz;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_device_authorizations_user_code" ON "device_authorizations" ("user_code");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_device_authorizations_device_code_hash" ON "device_authorizations" ("device_code_hash");
+CREATE INDEX IF NOT EXISTS "idx_device_authorizations_deleted_at" ON "device_authorizations" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "permissions" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "description" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "permissions"
+    ADD COLUMN IF NOT EXISTS "name" text,
+    ADD COLUMN IF NOT EXISTS "description" text,
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamptz;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_permissions_name" ON "permissions" ("name");
+
+CREATE TABLE IF NOT EXISTS "roles" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "description" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "roles"
+    ADD COLUMN IF NOT EXISTS "name" text,
+    ADD COLUMN IF NOT EXISTS "description" text,
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamptz;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_roles_name" ON "roles" ("name");
+
+CREATE TABLE IF NOT EXISTS "role_permissions" (
+    "role_id" bigint,
+    "permission_id" bigint,
+    PRIMARY KEY ("role_id","permission_id"),
+    CONSTRAINT "fk_role_permissions_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_role_permissions_permission" FOREIGN KEY ("permission_id") REFERENCES "permissions"("id") ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "user_roles" (
+    "user_guid" text,
+    "role_id" bigint,
+    "created_at" timestamptz,
+    PRIMARY KEY ("user_guid","role_id"),
+    CONSTRAINT "fk_user_roles_role" FOREIGN KEY ("role_id") REFERENCES "roles"("id") ON DELETE CASCADE
+);
+ALTER TABLE "user_roles"
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz;
+
+CREATE TABLE IF NOT EXISTS "tenants" (
+    "id" bigserial,
+    "slug" text NOT NULL,
+    "name" text,
+    "domains" text,
+    "issuer" text,
+    "audience" text,
+    "default_scopes" text,
+    "access_token_lifetime" bigint,
+    "disabled" boolean,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+ALTER TABLE "tenants"
+    ADD COLUMN IF NOT EXISTS "slug" text,
+    ADD COLUMN IF NOT EXISTS "name" text,
+    ADD COLUMN IF NOT EXISTS "domains" text,
+    ADD COLUMN IF NOT EXISTS "issuer" text,
+    ADD COLUMN IF NOT EXISTS "audience" text,
+    ADD COLUMN IF NOT EXISTS "default_scopes" text,
+    ADD COLUMN IF NOT EXISTS "access_token_lifetime" bigint,
+    ADD COLUMN IF NOT EXISTS "disabled" boolean,
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "updated_at" timestamptz;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tenants_slug" ON "tenants" ("slug");
+
+CREATE TABLE IF NOT EXISTS "tenant_members" (
+    "tenant_id" bigint,
+    "user_guid" text,
+    "role" text NOT NULL DEFAULT 'member',
+    "created_at" timestamptz,
+    PRIMARY KEY ("tenant_id","user_guid"),
+    CONSTRAINT "fk_tenant_members_tenant" FOREIGN KEY ("tenant_id") REFERENCES "tenants"("id") ON DELETE CASCADE
+);
+ALTER TABLE "tenant_members"
+    ADD COLUMN IF NOT EXISTS "role" text NOT NULL DEFAULT 'member',
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz;
+
+CREATE TABLE IF NOT EXISTS "invitations" (
+    "id" bigserial,
+    "tenant_id" bigint NOT NULL,
+    "email" text NOT NULL,
+    "role" text NOT NULL,
+    "token_id" text NOT NULL,
+    "invited_by" text,
+    "expires_at" timestamptz,
+    "accepted_at" timestamptz,
+    "accepted_by" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_invitations_tenant" FOREIGN KEY ("tenant_id") REFERENCES "tenants"("id") ON DELETE CASCADE
+);
+ALTER TABLE "invitations"
+    ADD COLUMN IF NOT EXISTS "tenant_id" bigint,
+    ADD COLUMN IF NOT EXISTS "email" text,
+    ADD COLUMN IF NOT EXISTS "role" text,
+    ADD COLUMN IF NOT EXISTS "token_id" text,
+    ADD COLUMN IF NOT EXISTS "invited_by" text,
+    ADD COLUMN IF NOT EXISTS "expires_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "accepted_at" timestamptz,
+    ADD COLUMN IF NOT EXISTS "accepted_by" text,
+    ADD COLUMN IF NOT EXISTS "created_at" timestamptz;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_invitations_token_id" ON "invitations" ("token_id");
+CREATE INDEX IF NOT EXISTS "idx_invitations_tenant_id" ON "invitations" ("tenant_id");
+
+CREATE TABLE IF NOT EXISTS "denied_tokens" (
+    "tenant_id" bigint,
+    "user_guid" text,
+    "sig" text,
+    "expires_at" timestamptz,
+    PRIMARY KEY ("tenant_id","user_guid","sig")
+);
+ALTER TABLE "denied_tokens"
+    ADD COLUMN IF NOT EXISTS "expires_at" timestamptz;
+
+-- до мягкого удаления deleted_at заполнялся нулевой датой, такие пользователи не удалены
+ALTER TABLE "users" ALTER COLUMN "deleted_at" DROP NOT NULL;
+UPDATE "users" SET "deleted_at" = NULL WHERE "deleted_at" < '1970-01-01';
@@ -0,0 +1,14 @@
+-- Удалённые при миграции сессии не восстанавливаются.
+DROP INDEX IF EXISTS "idx_denied_tokens_expires_at";
+DROP INDEX IF EXISTS "idx_tokens_refresh_token";
+DROP INDEX IF EXISTS "idx_tokens_expires_at";
+DROP INDEX IF EXISTS "idx_tokens_user_tenant";
+
+ALTER TABLE "tokens"
+    DROP CONSTRAINT IF EXISTS "fk_tokens_user",
+    ALTER COLUMN "user_guid" DROP NOT NULL,
+    ALTER COLUMN "refresh_token" DROP NOT NULL,
+    ALTER COLUMN "expires_at" DROP NOT NULL;
+
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "users_pkey";
+ALTER TABLE "users" ADD CONSTRAINT "uni_users_guid" UNIQUE ("guid");
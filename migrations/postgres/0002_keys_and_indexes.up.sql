@@ -0,0 +1,27 @@
+-- GUID пользователя (UUID) становится первичным ключом вместо отдельного ограничения уникальности.
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "uni_users_guid";
+DROP INDEX IF EXISTS "idx_users_guid";
+ALTER TABLE "users" ADD CONSTRAINT "users_pkey" PRIMARY KEY ("guid");
+
+-- Сессии без пользователя, refresh токена или срока действия обновить нельзя - они удаляются
+-- перед добавлением ограничений. Из сессий с одинаковым refresh токеном остаётся последняя.
+DELETE FROM "tokens"
+WHERE "user_guid" IS NULL
+   OR "refresh_token" IS NULL
+   OR "expires_at" IS NULL
+   OR "user_guid" NOT IN (SELECT "guid" FROM "users");
+DELETE FROM "tokens" t
+USING "tokens" newer
+WHERE t."refresh_token" = newer."refresh_token" AND t."id" < newer."id";
+
+ALTER TABLE "tokens"
+    ALTER COLUMN "user_guid" SET NOT NULL,
+    ALTER COLUMN "refresh_token" SET NOT NULL,
+    ALTER COLUMN "expires_at" SET NOT NULL,
+    ADD CONSTRAINT "fk_tokens_user" FOREIGN KEY ("user_guid") REFERENCES "users"("guid") ON DELETE CASCADE;
+
+-- поиск сессий пользователя (в арендаторе и во всех арендаторах), удаление истёкших
+CREATE INDEX "idx_tokens_user_tenant" ON "tokens" ("user_guid", "tenant_id");
+CREATE INDEX "idx_tokens_expires_at" ON "tokens" ("expires_at");
+CREATE UNIQUE INDEX "idx_tokens_refresh_token" ON "tokens" ("refresh_token");
+CREATE INDEX "idx_denied_tokens_expires_at" ON "denied_tokens" ("expires_at");
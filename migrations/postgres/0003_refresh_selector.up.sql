@@ -0,0 +1,4 @@
+-- Refresh токены selector.verifier: сессия ищется по selector. У сессий, выданных раньше,
+-- selector остаётся NULL - они проверяются прежним способом, пока не истекут.
+ALTER TABLE "tokens" ADD COLUMN IF NOT EXISTS "selector" text;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tokens_selector" ON "tokens" ("selector");
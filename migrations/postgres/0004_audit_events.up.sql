@@ -0,0 +1,36 @@
+-- Журнал аудита: записи только добавляются, каждая содержит хеш предыдущей (prev_hash) и свой
+-- хеш. Уникальный prev_hash не даёт цепочке ветвиться, триггер запрещает изменение и удаление.
+CREATE TABLE IF NOT EXISTS "audit_events" (
+    "id" bigserial,
+    "created_at" timestamptz NOT NULL,
+    "tenant_id" bigint NOT NULL DEFAULT 0,
+    "action" text NOT NULL,
+    "outcome" text NOT NULL,
+    "reason" text,
+    "actor" text NOT NULL,
+    "subject" text,
+    "ip" text,
+    "user_agent" text,
+    "request_id" text,
+    "details" text,
+    "prev_hash" text NOT NULL,
+    "hash" text NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_audit_events_prev_hash" ON "audit_events" ("prev_hash");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_audit_events_hash" ON "audit_events" ("hash");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_created_at" ON "audit_events" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_subject" ON "audit_events" ("subject");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_action" ON "audit_events" ("action");
+
+CREATE OR REPLACE FUNCTION "audit_events_append_only"() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS "audit_events_append_only" ON "audit_events";
+CREATE TRIGGER "audit_events_append_only" BEFORE UPDATE OR DELETE ON "audit_events"
+    FOR EACH ROW EXECUTE FUNCTION "audit_events_append_only"();
+DROP TRIGGER IF EXISTS "audit_events_no_truncate" ON "audit_events";
+CREATE TRIGGER "audit_events_no_truncate" BEFORE TRUNCATE ON "audit_events"
+    FOR EACH STATEMENT EXECUTE FUNCTION "audit_events_append_only"();
@@ -0,0 +1,22 @@
+-- Outbox webhook'ов: события записываются в той же транзакции, что и изменение сессии, и
+-- отправляются фоновыми воркерами с повторами. locked_until - срок, на который доставку занял
+-- воркер; dead - попытки исчерпаны, доставка ждёт повторной постановки в очередь.
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" bigserial,
+    "created_at" timestamptz NOT NULL,
+    "updated_at" timestamptz NOT NULL,
+    "event" text NOT NULL,
+    "payload" text NOT NULL,
+    "status" text NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz NOT NULL,
+    "locked_until" timestamptz,
+    "last_error" text,
+    "last_status" bigint NOT NULL DEFAULT 0,
+    "delivered_at" timestamptz,
+    "request_id" text,
+    "trace_parent" text,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_due" ON "webhook_deliveries" ("status", "next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_delivered_at" ON "webhook_deliveries" ("delivered_at");
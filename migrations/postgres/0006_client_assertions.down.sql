@@ -0,0 +1,2 @@
+ALTER TABLE "o_auth_clients" ADD COLUMN IF NOT EXISTS "refresh_token_lifetime" bigint;
+DROP TABLE IF EXISTS "used_client_assertions";
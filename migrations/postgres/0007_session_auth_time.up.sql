@@ -0,0 +1,5 @@
+-- Момент аутентификации пользователя переходит из сессии в сессию при обновлении токенов
+-- (auth_time в id_token). Для выданных раньше сессий известен только момент их создания.
+ALTER TABLE "tokens" ADD COLUMN IF NOT EXISTS "auth_time" timestamptz;
+UPDATE "tokens" SET "auth_time" = "created_at" WHERE "auth_time" IS NULL;
+ALTER TABLE "tokens" ALTER COLUMN "auth_time" SET NOT NULL;
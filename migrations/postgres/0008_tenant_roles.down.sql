@@ -0,0 +1,10 @@
+ALTER TABLE "tenant_members" DROP COLUMN IF EXISTS "permission_version";
+
+-- Роли пользователя во всех арендаторах объединяются.
+ALTER TABLE "user_roles" DROP CONSTRAINT IF EXISTS "fk_user_roles_member";
+ALTER TABLE "user_roles" DROP CONSTRAINT IF EXISTS "user_roles_pkey";
+DELETE FROM "user_roles" r
+USING "user_roles" other
+WHERE r."user_guid" = other."user_guid" AND r."role_id" = other."role_id" AND r."tenant_id" > other."tenant_id";
+ALTER TABLE "user_roles" DROP COLUMN IF EXISTS "tenant_id";
+ALTER TABLE "user_roles" ADD CONSTRAINT "user_roles_pkey" PRIMARY KEY ("user_guid", "role_id");
@@ -0,0 +1,19 @@
+-- Роли назначаются пользователю в арендаторе: назначение ссылается на членство и удаляется
+-- вместе с ним. Назначения, сделанные раньше, переносятся во все арендаторы пользователя.
+ALTER TABLE "user_roles" ADD COLUMN IF NOT EXISTS "tenant_id" bigint;
+ALTER TABLE "user_roles" DROP CONSTRAINT IF EXISTS "user_roles_pkey";
+INSERT INTO "user_roles" ("tenant_id", "user_guid", "role_id", "created_at")
+SELECT m."tenant_id", r."user_guid", r."role_id", r."created_at"
+FROM "user_roles" r
+JOIN "tenant_members" m ON m."user_guid" = r."user_guid"
+WHERE r."tenant_id" IS NULL;
+DELETE FROM "user_roles" WHERE "tenant_id" IS NULL;
+ALTER TABLE "user_roles"
+    ALTER COLUMN "tenant_id" SET NOT NULL,
+    ADD CONSTRAINT "user_roles_pkey" PRIMARY KEY ("tenant_id", "user_guid", "role_id"),
+    ADD CONSTRAINT "fk_user_roles_member" FOREIGN KEY ("tenant_id", "user_guid")
+        REFERENCES "tenant_members"("tenant_id", "user_guid") ON DELETE CASCADE;
+
+-- Версия ролей пользователя в арендаторе. Claim pv - сумма версий учётной записи и членства,
+-- поэтому с нулевой начальной версией выданные раньше токены остаются действительными.
+ALTER TABLE "tenant_members" ADD COLUMN IF NOT EXISTS "permission_version" bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS `denied_tokens`;
+DROP TABLE IF EXISTS `invitations`;
+DROP TABLE IF EXISTS `tenant_members`;
+DROP TABLE IF EXISTS `tenants`;
+DROP TABLE IF EXISTS `user_roles`;
+DROP TABLE IF EXISTS `role_permissions`;
+DROP TABLE IF EXISTS `roles`;
+DROP TABLE IF EXISTS `permissions`;
+DROP TABLE IF EXISTS `device_authorizations`;
+DROP TABLE IF EXISTS `authorization_codes`;
+DROP TABLE IF EXISTS `o_auth_clients`;
+DROP TABLE IF EXISTS `users`;
+DROP TABLE IF EXISTS `tokens`;
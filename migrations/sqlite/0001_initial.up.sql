@@ -0,0 +1,177 @@
+-- Схема, которую создавал AutoMigrate до версионных миграций.
+
+CREATE TABLE IF NOT EXISTS `tokens` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `tenant_id` integer,
+    `user_guid` text,
+    `client_id` text,
+    `scopes` text,
+    `user_agent` text,
+    `ip_address` text,
+    `refresh_token` text,
+    `expires_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_tokens_tenant_id` ON `tokens`(`tenant_id`);
+CREATE INDEX IF NOT EXISTS `idx_tokens_deleted_at` ON `tokens`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `guid` text NOT NULL,
+    `name` text,
+    `email` text,
+    `email_verified` numeric,
+    `disabled` numeric NOT NULL DEFAULT false,
+    `permission_version` integer NOT NULL DEFAULT 1,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `uni_users_guid` UNIQUE (`guid`)
+);
+CREATE INDEX IF NOT EXISTS `idx_users_deleted_at` ON `users`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `o_auth_clients` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `client_id` text NOT NULL,
+    `name` text,
+    `secret_hash` text,
+    `token_endpoint_auth_method` text,
+    `grant_types` text,
+    `scopes` text,
+    `redirect_uris` text,
+    `public_key` text,
+    `access_token_lifetime` integer,
+    `refresh_token_lifetime` integer,
+    `exchange_audiences` text,
+    `allow_impersonation` numeric
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_o_auth_clients_client_id` ON `o_auth_clients`(`client_id`);
+CREATE INDEX IF NOT EXISTS `idx_o_auth_clients_deleted_at` ON `o_auth_clients`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `authorization_codes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `code_hash` text NOT NULL,
+    `client_id` text,
+    `tenant_id` integer,
+    `user_guid` text,
+    `redirect_uri` text,
+    `scopes` text,
+    `nonce` text,
+    `auth_time` datetime,
+    `code_challenge` text,
+    `code_challenge_method` text,
+    `expires_at` datetime,
+    `used` numeric
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_authorization_codes_code_hash` ON `authorization_codes`(`code_hash`);
+CREATE INDEX IF NOT EXISTS `idx_authorization_codes_deleted_at` ON `authorization_codes`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `device_authorizations` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `device_code_hash` text NOT NULL,
+    `user_code` text NOT NULL,
+    `client_id` text,
+    `scopes` text,
+    `status` text,
+    `tenant_id` integer,
+    `user_guid` text,
+    `auth_time` datetime,
+    `interval` integer,
+    `last_polled_at` datetime,
+    `expires_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_device_authorizations_user_code` ON `device_authorizations`(`user_code`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_device_authorizations_device_code_hash` ON `device_authorizations`(`device_code_hash`);
+CREATE INDEX IF NOT EXISTS `idx_device_authorizations_deleted_at` ON `device_authorizations`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `permissions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` text NOT NULL,
+    `description` text,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_permissions_name` ON `permissions`(`name`);
+
+CREATE TABLE IF NOT EXISTS `roles` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` text NOT NULL,
+    `description` text,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_roles_name` ON `roles`(`name`);
+
+CREATE TABLE IF NOT EXISTS `role_permissions` (
+    `role_id` integer,
+    `permission_id` integer,
+    PRIMARY KEY (`role_id`,`permission_id`),
+    CONSTRAINT `fk_role_permissions_role` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_role_permissions_permission` FOREIGN KEY (`permission_id`) REFERENCES `permissions`(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `user_roles` (
+    `user_guid` text,
+    `role_id` integer,
+    `created_at` datetime,
+    PRIMARY KEY (`user_guid`,`role_id`),
+    CONSTRAINT `fk_user_roles_role` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `tenants` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `slug` text NOT NULL,
+    `name` text,
+    `domains` text,
+    `issuer` text,
+    `audience` text,
+    `default_scopes` text,
+    `access_token_lifetime` integer,
+    `disabled` numeric,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_tenants_slug` ON `tenants`(`slug`);
+
+CREATE TABLE IF NOT EXISTS `tenant_members` (
+    `tenant_id` integer,
+    `user_guid` text,
+    `role` text NOT NULL DEFAULT 'member',
+    `created_at` datetime,
+    PRIMARY KEY (`tenant_id`,`user_guid`),
+    CONSTRAINT `fk_tenant_members_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `tenants`(`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS `invitations` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `tenant_id` integer NOT NULL,
+    `email` text NOT NULL,
+    `role` text NOT NULL,
+    `token_id` text NOT NULL,
+    `invited_by` text,
+    `expires_at` datetime,
+    `accepted_at` datetime,
+    `accepted_by` text,
+    `created_at` datetime,
+    CONSTRAINT `fk_invitations_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `tenants`(`id`) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_invitations_token_id` ON `invitations`(`token_id`);
+CREATE INDEX IF NOT EXISTS `idx_invitations_tenant_id` ON `invitations`(`tenant_id`);
+
+CREATE TABLE IF NOT EXISTS `denied_tokens` (
+    `tenant_id` integer,
+    `user_guid` text,
+    `sig` text,
+    `expires_at` datetime,
+    PRIMARY KEY (`tenant_id`,`user_guid`,`sig`)
+);
@@ -0,0 +1,44 @@
+-- Удалённые при миграции сессии не восстанавливаются. tokens пересоздаётся первой:
+-- удаление users при действующем внешнем ключе удалило бы сессии.
+DROP INDEX IF EXISTS `idx_denied_tokens_expires_at`;
+
+CREATE TABLE `tokens_old` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `tenant_id` integer,
+    `user_guid` text,
+    `client_id` text,
+    `scopes` text,
+    `user_agent` text,
+    `ip_address` text,
+    `refresh_token` text,
+    `expires_at` datetime
+);
+INSERT INTO `tokens_old` (`id`, `created_at`, `updated_at`, `deleted_at`, `tenant_id`, `user_guid`, `client_id`, `scopes`, `user_agent`, `ip_address`, `refresh_token`, `expires_at`)
+SELECT `id`, `created_at`, `updated_at`, `deleted_at`, `tenant_id`, `user_guid`, `client_id`, `scopes`, `user_agent`, `ip_address`, `refresh_token`, `expires_at`
+FROM `tokens`;
+DROP TABLE `tokens`;
+ALTER TABLE `tokens_old` RENAME TO `tokens`;
+CREATE INDEX `idx_tokens_tenant_id` ON `tokens`(`tenant_id`);
+CREATE INDEX `idx_tokens_deleted_at` ON `tokens`(`deleted_at`);
+
+CREATE TABLE `users_old` (
+    `guid` text NOT NULL,
+    `name` text,
+    `email` text,
+    `email_verified` numeric,
+    `disabled` numeric NOT NULL DEFAULT false,
+    `permission_version` integer NOT NULL DEFAULT 1,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `uni_users_guid` UNIQUE (`guid`)
+);
+INSERT INTO `users_old` (`guid`, `name`, `email`, `email_verified`, `disabled`, `permission_version`, `created_at`, `updated_at`, `deleted_at`)
+SELECT `guid`, `name`, `email`, `email_verified`, `disabled`, `permission_version`, `created_at`, `updated_at`, `deleted_at`
+FROM `users`;
+DROP TABLE `users`;
+ALTER TABLE `users_old` RENAME TO `users`;
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
@@ -0,0 +1,55 @@
+-- SQLite не изменяет ключи существующих таблиц, поэтому users и tokens пересоздаются.
+
+-- GUID пользователя (UUID) становится первичным ключом.
+CREATE TABLE `users_new` (
+    `guid` text NOT NULL PRIMARY KEY,
+    `name` text,
+    `email` text,
+    `email_verified` numeric,
+    `disabled` numeric NOT NULL DEFAULT false,
+    `permission_version` integer NOT NULL DEFAULT 1,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+INSERT INTO `users_new` (`guid`, `name`, `email`, `email_verified`, `disabled`, `permission_version`, `created_at`, `updated_at`, `deleted_at`)
+SELECT `guid`, `name`, `email`, `email_verified`, `disabled`, `permission_version`, `created_at`, `updated_at`, `deleted_at`
+FROM `users`;
+DROP TABLE `users`;
+ALTER TABLE `users_new` RENAME TO `users`;
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
+
+-- Сессии без пользователя, refresh токена или срока действия обновить нельзя - они не переносятся.
+-- Из сессий с одинаковым refresh токеном переносится последняя.
+CREATE TABLE `tokens_new` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `tenant_id` integer,
+    `user_guid` text NOT NULL,
+    `client_id` text,
+    `scopes` text,
+    `user_agent` text,
+    `ip_address` text,
+    `refresh_token` text NOT NULL,
+    `expires_at` datetime NOT NULL,
+    CONSTRAINT `fk_tokens_user` FOREIGN KEY (`user_guid`) REFERENCES `users`(`guid`) ON DELETE CASCADE
+);
+INSERT INTO `tokens_new` (`id`, `created_at`, `updated_at`, `deleted_at`, `tenant_id`, `user_guid`, `client_id`, `scopes`, `user_agent`, `ip_address`, `refresh_token`, `expires_at`)
+SELECT `id`, `created_at`, `updated_at`, `deleted_at`, `tenant_id`, `user_guid`, `client_id`, `scopes`, `user_agent`, `ip_address`, `refresh_token`, `expires_at`
+FROM `tokens` t
+WHERE `refresh_token` IS NOT NULL
+  AND `expires_at` IS NOT NULL
+  AND `user_guid` IN (SELECT `guid` FROM `users`)
+  AND NOT EXISTS (SELECT 1 FROM `tokens` newer WHERE newer.`refresh_token` = t.`refresh_token` AND newer.`id` > t.`id`);
+DROP TABLE `tokens`;
+ALTER TABLE `tokens_new` RENAME TO `tokens`;
+CREATE INDEX `idx_tokens_tenant_id` ON `tokens`(`tenant_id`);
+CREATE INDEX `idx_tokens_deleted_at` ON `tokens`(`deleted_at`);
+
+-- поиск сессий пользователя (в арендаторе и во всех арендаторах), удаление истёкших
+CREATE INDEX `idx_tokens_user_tenant` ON `tokens`(`user_guid`, `tenant_id`);
+CREATE INDEX `idx_tokens_expires_at` ON `tokens`(`expires_at`);
+CREATE UNIQUE INDEX `idx_tokens_refresh_token` ON `tokens`(`refresh_token`);
+CREATE INDEX `idx_denied_tokens_expires_at` ON `denied_tokens`(`expires_at`);
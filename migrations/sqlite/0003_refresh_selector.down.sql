@@ -0,0 +1,4 @@
+-- Сессии с selector нельзя проверить без него - они удаляются.
+DELETE FROM `tokens` WHERE `selector` IS NOT NULL;
+DROP INDEX IF EXISTS `idx_tokens_selector`;
+ALTER TABLE `tokens` DROP COLUMN `selector`;
@@ -0,0 +1,4 @@
+-- Refresh токены selector.verifier: сессия ищется по selector. У сессий, выданных раньше,
+-- selector остаётся NULL - они проверяются прежним способом, пока не истекут.
+ALTER TABLE `tokens` ADD COLUMN `selector` text;
+CREATE UNIQUE INDEX `idx_tokens_selector` ON `tokens`(`selector`);
@@ -0,0 +1,32 @@
+-- Журнал аудита: записи только добавляются, каждая содержит хеш предыдущей (prev_hash) и свой
+-- хеш. Уникальный prev_hash не даёт цепочке ветвиться, триггеры запрещают изменение и удаление.
+CREATE TABLE IF NOT EXISTS `audit_events` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime NOT NULL,
+    `tenant_id` integer NOT NULL DEFAULT 0,
+    `action` text NOT NULL,
+    `outcome` text NOT NULL,
+    `reason` text,
+    `actor` text NOT NULL,
+    `subject` text,
+    `ip` text,
+    `user_agent` text,
+    `request_id` text,
+    `details` text,
+    `prev_hash` text NOT NULL,
+    `hash` text NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_audit_events_prev_hash` ON `audit_events`(`prev_hash`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_audit_events_hash` ON `audit_events`(`hash`);
+CREATE INDEX IF NOT EXISTS `idx_audit_events_created_at` ON `audit_events`(`created_at`);
+CREATE INDEX IF NOT EXISTS `idx_audit_events_subject` ON `audit_events`(`subject`);
+CREATE INDEX IF NOT EXISTS `idx_audit_events_action` ON `audit_events`(`action`);
+
+CREATE TRIGGER IF NOT EXISTS `audit_events_no_update` BEFORE UPDATE ON `audit_events`
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+CREATE TRIGGER IF NOT EXISTS `audit_events_no_delete` BEFORE DELETE ON `audit_events`
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
@@ -0,0 +1,21 @@
+-- Outbox webhook'ов: события записываются в той же транзакции, что и изменение сессии, и
+-- отправляются фоновыми воркерами с повторами. locked_until - срок, на который доставку занял
+-- воркер; dead - попытки исчерпаны, доставка ждёт повторной постановки в очередь.
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `created_at` datetime NOT NULL,
+    `updated_at` datetime NOT NULL,
+    `event` text NOT NULL,
+    `payload` text NOT NULL,
+    `status` text NOT NULL DEFAULT 'pending',
+    `attempts` integer NOT NULL DEFAULT 0,
+    `next_attempt_at` datetime NOT NULL,
+    `locked_until` datetime,
+    `last_error` text,
+    `last_status` integer NOT NULL DEFAULT 0,
+    `delivered_at` datetime,
+    `request_id` text,
+    `trace_parent` text
+);
+CREATE INDEX IF NOT EXISTS `idx_webhook_deliveries_due` ON `webhook_deliveries`(`status`, `next_attempt_at`);
+CREATE INDEX IF NOT EXISTS `idx_webhook_deliveries_delivered_at` ON `webhook_deliveries`(`delivered_at`);
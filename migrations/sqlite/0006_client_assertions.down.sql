@@ -0,0 +1,2 @@
+ALTER TABLE `o_auth_clients` ADD COLUMN `refresh_token_lifetime` integer;
+DROP TABLE IF EXISTS `used_client_assertions`;
@@ -0,0 +1,12 @@
+-- jti принятых client_assertion (private_key_jwt) хранятся до истечения assertion: повтор
+-- перехваченного assertion отклоняется.
+CREATE TABLE IF NOT EXISTS `used_client_assertions` (
+    `client_id` text,
+    `jti` text,
+    `expires_at` datetime NOT NULL,
+    PRIMARY KEY (`client_id`,`jti`)
+);
+CREATE INDEX IF NOT EXISTS `idx_used_client_assertions_expires_at` ON `used_client_assertions`(`expires_at`);
+
+-- refresh_token_lifetime не использовался: OAuth grant'ы не выдают refresh токены.
+ALTER TABLE `o_auth_clients` DROP COLUMN `refresh_token_lifetime`;
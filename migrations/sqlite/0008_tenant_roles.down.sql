@@ -0,0 +1,14 @@
+ALTER TABLE `tenant_members` DROP COLUMN `permission_version`;
+
+-- Роли пользователя во всех арендаторах объединяются.
+CREATE TABLE `user_roles_old` (
+    `user_guid` text,
+    `role_id` integer,
+    `created_at` datetime,
+    PRIMARY KEY (`user_guid`,`role_id`),
+    CONSTRAINT `fk_user_roles_role` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE
+);
+INSERT INTO `user_roles_old` (`user_guid`, `role_id`, `created_at`)
+SELECT `user_guid`, `role_id`, MIN(`created_at`) FROM `user_roles` GROUP BY `user_guid`, `role_id`;
+DROP TABLE `user_roles`;
+ALTER TABLE `user_roles_old` RENAME TO `user_roles`;
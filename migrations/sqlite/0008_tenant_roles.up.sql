@@ -0,0 +1,22 @@
+-- Роли назначаются пользователю в арендаторе: назначение ссылается на членство и удаляется
+-- вместе с ним. Назначения, сделанные раньше, переносятся во все арендаторы пользователя.
+-- SQLite не изменяет ключи существующих таблиц, поэтому user_roles пересоздаётся.
+CREATE TABLE `user_roles_new` (
+    `tenant_id` integer NOT NULL,
+    `user_guid` text NOT NULL,
+    `role_id` integer NOT NULL,
+    `created_at` datetime,
+    PRIMARY KEY (`tenant_id`,`user_guid`,`role_id`),
+    CONSTRAINT `fk_user_roles_member` FOREIGN KEY (`tenant_id`,`user_guid`) REFERENCES `tenant_members`(`tenant_id`,`user_guid`) ON DELETE CASCADE,
+    CONSTRAINT `fk_user_roles_role` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE
+);
+INSERT INTO `user_roles_new` (`tenant_id`, `user_guid`, `role_id`, `created_at`)
+SELECT m.`tenant_id`, r.`user_guid`, r.`role_id`, r.`created_at`
+FROM `user_roles` r
+JOIN `tenant_members` m ON m.`user_guid` = r.`user_guid`;
+DROP TABLE `user_roles`;
+ALTER TABLE `user_roles_new` RENAME TO `user_roles`;
+
+-- Версия ролей пользователя в арендаторе. Claim pv - сумма версий учётной записи и членства,
+-- поэтому с нулевой начальной версией выданные раньше токены остаются действительными.
+ALTER TABLE `tenant_members` ADD COLUMN `permission_version` integer NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE `tokens` ADD COLUMN `deleted_at` datetime;
+CREATE INDEX IF NOT EXISTS `idx_tokens_deleted_at` ON `tokens`(`deleted_at`);
@@ -0,0 +1,5 @@
+-- Завершённые сессии удаляются сразу: мягко удалённые сессии (с отозванными refresh токенами)
+-- удаляются, колонка deleted_at больше не нужна.
+DELETE FROM `tokens` WHERE `deleted_at` IS NOT NULL;
+DROP INDEX IF EXISTS `idx_tokens_deleted_at`;
+ALTER TABLE `tokens` DROP COLUMN `deleted_at`;
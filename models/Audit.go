@@ -0,0 +1,128 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Действия журнала аудита.
+const (
+	AuditTokenIssued       = "token.issued"
+	AuditTokenRefreshed    = "token.refreshed"
+	AuditLoginFailed       = "login.failed"
+	AuditRefreshFailed     = "refresh.failed"
+	AuditSessionRevoked    = "session.revoked"
+	AuditSessionIPChanged  = "session.ip_changed"
+	AuditLogout            = "logout"
+	AuditAdminActionPrefix = "admin."
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditActorAdmin - действие через административный API (X-Admin-Key), AuditActorAnonymous -
+// запрос без подтверждённой личности (например, с недействительным токеном).
+const (
+	AuditActorAdmin     = "admin"
+	AuditActorAnonymous = "anonymous"
+)
+
+func UserActor(guid string) string {
+	return "user:" + guid
+}
+
+func ClientActor(clientID string) string {
+	return "client:" + clientID
+}
+
+// AuditEvent - запись журнала аудита. Actor - кто выполнил действие (user:<guid>,
+// client:<client_id>, admin), Subject - GUID пользователя, которого оно касается.
+// Записи только добавляются: Hash - SHA-256 записи вместе с PrevHash, хешем предыдущей
+// записи, поэтому изменение или удаление записи из середины журнала обнаруживается Verify.
+type AuditEvent struct {
+	ID        uint              `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time         `json:"created_at"`
+	TenantID  uint              `json:"tenant_id,omitempty"`
+	Action    string            `json:"action"`
+	Outcome   string            `json:"outcome"`
+	Reason    string            `json:"reason,omitempty"`
+	Actor     string            `json:"actor"`
+	Subject   string            `json:"subject,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Details   map[string]string `json:"details,omitempty" gorm:"serializer:json"`
+	PrevHash  string            `json:"prev_hash"`
+	Hash      string            `json:"hash"`
+}
+
+// auditHashInput - содержимое записи, которое покрывает хеш. ID не входит: его назначает база
+// уже после вычисления хеша, а порядок записей задаёт PrevHash.
+type auditHashInput struct {
+	PrevHash  string            `json:"prev_hash"`
+	CreatedAt string            `json:"created_at"`
+	TenantID  uint              `json:"tenant_id"`
+	Action    string            `json:"action"`
+	Outcome   string            `json:"outcome"`
+	Reason    string            `json:"reason"`
+	Actor     string            `json:"actor"`
+	Subject   string            `json:"subject"`
+	IP        string            `json:"ip"`
+	UserAgent string            `json:"user_agent"`
+	RequestID string            `json:"request_id"`
+	Details   map[string]string `json:"details"`
+}
+
+// ComputeHash вычисляет хеш записи в hex. Время берётся в UTC с точностью до микросекунды -
+// так его хранит PostgreSQL.
+func (e *AuditEvent) ComputeHash() string {
+	data, _ := json.Marshal(auditHashInput{
+		PrevHash:  e.PrevHash,
+		CreatedAt: e.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		TenantID:  e.TenantID,
+		Action:    e.Action,
+		Outcome:   e.Outcome,
+		Reason:    e.Reason,
+		Actor:     e.Actor,
+		Subject:   e.Subject,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		RequestID: e.RequestID,
+		Details:   e.Details,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditSearch - фильтр журнала аудита. Пустые поля не ограничивают выборку.
+type AuditSearch struct {
+	ListRequest
+	TenantID *uint
+	Action   string
+	Outcome  string
+	Actor    string
+	Subject  string
+	IP       string
+	Since    *time.Time
+	Until    *time.Time
+}
+
+type AuditPageResponse struct {
+	Items      []AuditEvent `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// AuditVerifyResponse - результат проверки цепочки. BrokenAt - id первой записи, хеш или
+// PrevHash которой не сходится; Head - хеш последней записи: сохранённый вне сервиса, он
+// позволяет обнаружить и удаление записей с конца журнала.
+type AuditVerifyResponse struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	Head     string `json:"head,omitempty"`
+	BrokenAt uint   `json:"broken_at,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// AuthorizationCode - одноразовый код authorization_code гранта. Хранится только
+// SHA-256 хеш кода.
+type AuthorizationCode struct {
+	gorm.Model
+	CodeHash            string `gorm:"uniqueIndex;not null"`
+	ClientID            string
+	TenantID            uint
+	UserGuid            string
+	RedirectURI         string
+	Scopes              []string `gorm:"serializer:json"`
+	Nonce               string
+	AuthTime            time.Time
+	CodeChallenge       string
+	CodeChallengeMethod string
+	ExpiresAt           time.Time
+	Used                bool
+}
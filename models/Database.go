@@ -2,17 +2,51 @@ package models
 
 import (
 	"auth-service/connections"
-	"github.com/gofiber/fiber/v2/log"
+	"auth-service/migrations"
+	"auth-service/models/consts"
+	"fmt"
+	"gorm.io/gorm"
+	"log/slog"
+	"time"
 )
 
-func Migrate() {
-	log.Info("Running migrations database")
-	migrate := connections.DB.AutoMigrate(
-		&Token{},
-		&User{},
-	)
-	if migrate != nil {
-		log.Panicf("Failed to migrate database: %s", migrate)
+// Migrate применяет версионные миграции (пакет migrations) и создаёт арендатора по умолчанию.
+func Migrate() error {
+	return MigrateDB(connections.DB)
+}
+
+// MigrateDB - Migrate для базы db.
+func MigrateDB(db *gorm.DB) error {
+	slog.Info("Running database migrations")
+	applied, err := migrations.Up(db)
+	if err != nil {
+		return err
+	}
+	for _, m := range applied {
+		slog.Info("Applied migration", "version", m.Version, "name", m.Name)
 	}
+	if err := seedDefaultTenant(db); err != nil {
+		return fmt.Errorf("create default tenant: %w", err)
+	}
+	return nil
+}
 
+// seedDefaultTenant создаёт арендатора по умолчанию. При первом создании (обновление с версии
+// без арендаторов) в него переносятся все пользователи и их refresh сессии.
+func seedDefaultTenant(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		tenant := Tenant{Slug: consts.DefaultTenant, Name: "Default"}
+		result := tx.Where("slug = ?", tenant.Slug).FirstOrCreate(&tenant)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		err := tx.Exec(
+			"INSERT INTO tenant_members (tenant_id, user_guid, created_at) SELECT ?, guid, ? FROM users WHERE deleted_at IS NULL",
+			tenant.ID, time.Now(),
+		).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&Token{}).Where("tenant_id = 0").Update("tenant_id", tenant.ID).Error
+	})
 }
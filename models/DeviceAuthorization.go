@@ -0,0 +1,45 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+type DeviceVerificationRequest struct {
+	UserCode string `json:"user_code"`
+	Approve  bool   `json:"approve"`
+}
+
+type DeviceVerificationResponse struct {
+	UserCode   string   `json:"user_code"`
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name,omitempty"`
+	Scopes     []string `json:"scopes"`
+	Status     string   `json:"status"`
+}
+
+// DeviceAuthorization - запрос устройства (RFC 8628). Хранится SHA-256 хеш device_code,
+// user_code хранится в нормализованном виде (без дефиса, в верхнем регистре).
+type DeviceAuthorization struct {
+	gorm.Model
+	DeviceCodeHash string `gorm:"uniqueIndex;not null"`
+	UserCode       string `gorm:"uniqueIndex;not null"`
+	ClientID       string
+	Scopes         []string `gorm:"serializer:json"`
+	Status         string
+	TenantID       uint
+	UserGuid       string
+	AuthTime       time.Time
+	Interval       int64
+	LastPolledAt   time.Time
+	ExpiresAt      time.Time
+}
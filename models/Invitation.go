@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+type InvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type InvitationResponse struct {
+	ID        uint      `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy string    `json:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+	Name  string `json:"name"`
+}
+
+type AcceptInvitationResponse struct {
+	Guid    string `json:"guid"`
+	Tenant  string `json:"tenant"`
+	Role    string `json:"role"`
+	Created bool   `json:"created"`
+}
+
+// Invitation - приглашение в арендатора по email. Сам токен приглашения не хранится:
+// он подписан и ссылается на запись через TokenID (claim jti). Принятое приглашение
+// повторно не используется.
+type Invitation struct {
+	ID         uint   `gorm:"primarykey"`
+	TenantID   uint   `gorm:"index;not null"`
+	Tenant     Tenant `gorm:"constraint:OnDelete:CASCADE"`
+	Email      string `gorm:"not null"`
+	Role       string `gorm:"not null"`
+	TokenID    string `gorm:"uniqueIndex;not null"`
+	InvitedBy  string
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	AcceptedBy string
+	CreatedAt  time.Time
+}
+
+func NewInvitationResponse(i *Invitation) InvitationResponse {
+	return InvitationResponse{
+		ID:        i.ID,
+		Email:     i.Email,
+		Role:      i.Role,
+		InvitedBy: i.InvitedBy,
+		ExpiresAt: i.ExpiresAt,
+		CreatedAt: i.CreatedAt,
+	}
+}
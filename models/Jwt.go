@@ -2,20 +2,93 @@ package models
 
 import (
 	"github.com/golang-jwt/jwt/v5"
+	"slices"
+	"strings"
+	"time"
 )
 
 type TokenClaims struct {
-	Exp        int64  `json:"exp"`
-	Sub        string `json:"sub"`
-	Iat        int64  `json:"iat"`
-	Iss        string `json:"iss"`
-	RefreshSig string `json:"refresh_sig"`
+	Exp               int64       `json:"exp"`
+	Sub               string      `json:"sub"`
+	Iat               int64       `json:"iat"`
+	Iss               string      `json:"iss"`
+	RefreshSig        string      `json:"refresh_sig"`
+	Sid               string      `json:"sid,omitempty"`
+	ClientID          string      `json:"client_id,omitempty"`
+	Scope             string      `json:"scope,omitempty"`
+	Aud               []string    `json:"aud,omitempty"`
+	Act               *ActorClaim `json:"act,omitempty"`
+	Roles             []string    `json:"roles,omitempty"`
+	Permissions       []string    `json:"permissions,omitempty"`
+	PermissionVersion int64       `json:"pv,omitempty"`
+	Tid               string      `json:"tid,omitempty"`
+	AuthTime          int64       `json:"auth_time,omitempty"`
+}
+
+// ActorClaim - claim act (RFC 8693, 4.1): кто действует от имени sub. Вложенный act
+// описывает предыдущие звенья цепочки делегирования.
+type ActorClaim struct {
+	Sub string      `json:"sub"`
+	Act *ActorClaim `json:"act,omitempty"`
+}
+
+// AuthenticatedAt - момент аутентификации пользователя. Токены, выданные до claim auth_time,
+// считаются аутентифицированными в момент выдачи.
+func (c *TokenClaims) AuthenticatedAt() time.Time {
+	if c.AuthTime > 0 {
+		return time.Unix(c.AuthTime, 0)
+	}
+	return time.Unix(c.Iat, 0)
+}
+
+// IsClientToken - токен выдан клиенту от его имени (client_credentials), а не пользователю.
+func (c *TokenClaims) IsClientToken() bool {
+	return c.ClientID != "" && c.Sub == c.ClientID
+}
+
+func (c *TokenClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScopes - все перечисленные scope выданы токену.
+func (c *TokenClaims) HasScopes(scopes ...string) bool {
+	granted := c.Scopes()
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *TokenClaims) HasAudience(aud string) bool {
+	return slices.Contains(c.Aud, aud)
+}
+
+// HasAnyRole - токену выдана хотя бы одна из ролей.
+func (c *TokenClaims) HasAnyRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(c.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermissions - токену выданы все перечисленные разрешения.
+func (c *TokenClaims) HasPermissions(permissions ...string) bool {
+	for _, p := range permissions {
+		if !slices.Contains(c.Permissions, p) {
+			return false
+		}
+	}
+	return true
 }
 
 func GetClaims(accessToken, secretKey string) *TokenClaims {
 	token, err := jwt.Parse(accessToken, func(t *jwt.Token) (interface{}, error) {
 		return []byte(secretKey), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}))
 	if err != nil {
 		return nil
 	}
@@ -23,12 +96,57 @@ func GetClaims(accessToken, secretKey string) *TokenClaims {
 	if !ok {
 		return nil
 	}
+	// токены клиентов (client_credentials) не содержат refresh_sig, поэтому
+	// необязательные поля читаются без паники на отсутствующих ключах
 	result := &TokenClaims{
-		Exp:        int64(payload["exp"].(float64)),
-		Sub:        payload["sub"].(string),
-		Iat:        int64(payload["iat"].(float64)),
-		Iss:        payload["iss"].(string),
-		RefreshSig: payload["refresh_sig"].(string),
+		Exp:               int64(numberClaim(payload, "exp")),
+		Sub:               stringClaim(payload, "sub"),
+		Iat:               int64(numberClaim(payload, "iat")),
+		Iss:               stringClaim(payload, "iss"),
+		RefreshSig:        stringClaim(payload, "refresh_sig"),
+		Sid:               stringClaim(payload, "sid"),
+		ClientID:          stringClaim(payload, "client_id"),
+		Scope:             stringClaim(payload, "scope"),
+		Act:               actorClaim(payload["act"]),
+		Roles:             stringsClaim(payload, "roles"),
+		Permissions:       stringsClaim(payload, "permissions"),
+		PermissionVersion: int64(numberClaim(payload, "pv")),
+		Tid:               stringClaim(payload, "tid"),
+		AuthTime:          int64(numberClaim(payload, "auth_time")),
+	}
+	result.Aud, _ = payload.GetAudience()
+	if result.Sub == "" {
+		return nil
 	}
 	return result
 }
+
+func stringClaim(payload jwt.MapClaims, key string) string {
+	v, _ := payload[key].(string)
+	return v
+}
+
+func numberClaim(payload jwt.MapClaims, key string) float64 {
+	v, _ := payload[key].(float64)
+	return v
+}
+
+func stringsClaim(payload jwt.MapClaims, key string) []string {
+	values, _ := payload[key].([]interface{})
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func actorClaim(v interface{}) *ActorClaim {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	sub, _ := m["sub"].(string)
+	return &ActorClaim{Sub: sub, Act: actorClaim(m["act"])}
+}
@@ -0,0 +1,10 @@
+package models
+
+// ListRequest - общие параметры постраничных списков. Sort - поля через запятую, минус
+// перед полем - по убыванию ("-created_at,name"). Cursor - next_cursor предыдущей страницы,
+// действителен только с той же сортировкой.
+type ListRequest struct {
+	Sort   string
+	Limit  int
+	Cursor string
+}
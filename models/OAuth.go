@@ -0,0 +1,26 @@
+package models
+
+// OAuthTokenResponse - ответ token endpoint'а в формате RFC 6749.
+type OAuthTokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+// OAuthErrorResponse - ошибка token endpoint'а в формате RFC 6749 (5.2).
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// ClientCredentials - данные аутентификации клиента, извлечённые из запроса.
+type ClientCredentials struct {
+	ClientID     string
+	ClientSecret string
+	Assertion    string
+	Method       string
+}
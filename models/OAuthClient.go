@@ -0,0 +1,102 @@
+package models
+
+import (
+	"auth-service/models/consts"
+	"gorm.io/gorm"
+	"slices"
+	"time"
+)
+
+type OAuthClientRequest struct {
+	Name                    string   `json:"name"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	GrantTypes              []string `json:"grant_types"`
+	Scopes                  []string `json:"scopes"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	PublicKey               string   `json:"public_key"`
+	AccessTokenLifetime     int64    `json:"access_token_lifetime"`
+	ExchangeAudiences       []string `json:"token_exchange_audiences"`
+	AllowImpersonation      bool     `json:"token_exchange_impersonation"`
+}
+
+type OAuthClientResponse struct {
+	ClientID                string   `json:"client_id"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	Name                    string   `json:"name"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	GrantTypes              []string `json:"grant_types"`
+	Scopes                  []string `json:"scopes"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	PublicKey               string   `json:"public_key,omitempty"`
+	AccessTokenLifetime     int64    `json:"access_token_lifetime"`
+	ExchangeAudiences       []string `json:"token_exchange_audiences"`
+	AllowImpersonation      bool     `json:"token_exchange_impersonation"`
+}
+
+// OAuthClient - зарегистрированный клиент (сервис), которому разрешено получать токены.
+// Секрет хранится только в виде bcrypt-хеша, время жизни токенов - в секундах.
+// ExchangeAudiences и AllowImpersonation - политика token exchange: в какие аудитории клиент
+// может обменивать токены и может ли обменивать токены, выданные не ему.
+type OAuthClient struct {
+	gorm.Model
+	ClientID                string `gorm:"uniqueIndex;not null"`
+	Name                    string
+	SecretHash              string
+	TokenEndpointAuthMethod string
+	GrantTypes              []string `gorm:"serializer:json"`
+	Scopes                  []string `gorm:"serializer:json"`
+	RedirectURIs            []string `gorm:"serializer:json"`
+	PublicKey               string
+	AccessTokenLifetime     int64
+	ExchangeAudiences       []string `gorm:"serializer:json"`
+	AllowImpersonation      bool
+}
+
+// UsedClientAssertion - jti принятого client_assertion (private_key_jwt). Запись хранится до
+// ExpiresAt - истечения assertion, чтобы перехваченный assertion нельзя было предъявить повторно.
+type UsedClientAssertion struct {
+	ClientID  string    `gorm:"primaryKey"`
+	Jti       string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (c *OAuthClient) AllowsGrant(grant string) bool {
+	return slices.Contains(c.GrantTypes, grant)
+}
+
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+// IsPublic - клиент без учётных данных (SPA, CLI), аутентифицируется только по client_id.
+func (c *OAuthClient) IsPublic() bool {
+	return c.TokenEndpointAuthMethod == consts.AuthMethodNone
+}
+
+func (c *OAuthClient) UsesSecret() bool {
+	return c.TokenEndpointAuthMethod == consts.AuthMethodClientSecretBasic ||
+		c.TokenEndpointAuthMethod == consts.AuthMethodClientSecretPost
+}
+
+func (c *OAuthClient) AccessTokenTTL(def time.Duration) time.Duration {
+	if c.AccessTokenLifetime > 0 {
+		return time.Duration(c.AccessTokenLifetime) * time.Second
+	}
+	return def
+}
+
+func NewOAuthClientResponse(c *OAuthClient, secret string) OAuthClientResponse {
+	return OAuthClientResponse{
+		ClientID:                c.ClientID,
+		ClientSecret:            secret,
+		Name:                    c.Name,
+		TokenEndpointAuthMethod: c.TokenEndpointAuthMethod,
+		GrantTypes:              c.GrantTypes,
+		Scopes:                  c.Scopes,
+		RedirectURIs:            c.RedirectURIs,
+		PublicKey:               c.PublicKey,
+		AccessTokenLifetime:     c.AccessTokenLifetime,
+		ExchangeAudiences:       c.ExchangeAudiences,
+		AllowImpersonation:      c.AllowImpersonation,
+	}
+}
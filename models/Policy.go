@@ -0,0 +1,15 @@
+package models
+
+type AuthorizeRequest struct {
+	Subject     string                 `json:"subject,omitempty"`
+	Action      string                 `json:"action"`
+	Resource    map[string]interface{} `json:"resource"`
+	Environment map[string]interface{} `json:"environment,omitempty"`
+}
+
+type AuthorizeResponse struct {
+	Allowed bool   `json:"allowed"`
+	Effect  string `json:"effect"`
+	Policy  string `json:"policy,omitempty"`
+	Rule    string `json:"rule"`
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+type PermissionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type PermissionResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RoleRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UserRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+type UserRolesResponse struct {
+	Guid              string   `json:"guid"`
+	Roles             []string `json:"roles"`
+	Permissions       []string `json:"permissions"`
+	PermissionVersion int64    `json:"permission_version"`
+}
+
+// Permission - атомарное право, например "users:write". Проверяется RequirePermission.
+type Permission struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Role - именованный набор разрешений, назначаемый пользователям.
+type Role struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+	Permissions []Permission `gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// UserRole - назначение роли пользователю в арендаторе. У User нет числового ключа,
+// поэтому таблица связи описана явно и ссылается на членство (tenant_id, user_guid).
+type UserRole struct {
+	TenantID  uint   `gorm:"primaryKey"`
+	UserGuid  string `gorm:"primaryKey"`
+	RoleID    uint   `gorm:"primaryKey"`
+	Role      Role   `gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time
+}
+
+// UserAuthorization - роли и разрешения пользователя, которые попадают в access токен.
+// Version - сумма User.PermissionVersion и TenantMember.PermissionVersion на момент выдачи
+// токена: обе версии только растут, поэтому изменение любой из них делает токен устаревшим.
+type UserAuthorization struct {
+	Roles       []string
+	Permissions []string
+	Version     int64
+}
+
+func (r *Role) PermissionNames() []string {
+	names := make([]string, len(r.Permissions))
+	for i, p := range r.Permissions {
+		names[i] = p.Name
+	}
+	return names
+}
+
+func NewPermissionResponse(p Permission) PermissionResponse {
+	return PermissionResponse{Name: p.Name, Description: p.Description}
+}
+
+func NewRoleResponse(r *Role) RoleResponse {
+	return RoleResponse{
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: r.PermissionNames(),
+	}
+}
+
+// NewUserAuthorization собирает роли пользователя и объединение их разрешений.
+func NewUserAuthorization(roles []Role, version int64) *UserAuthorization {
+	a := &UserAuthorization{Roles: []string{}, Permissions: []string{}, Version: version}
+	for _, r := range roles {
+		a.Roles = append(a.Roles, r.Name)
+		for _, p := range r.Permissions {
+			if !slices.Contains(a.Permissions, p.Name) {
+				a.Permissions = append(a.Permissions, p.Name)
+			}
+		}
+	}
+	slices.Sort(a.Roles)
+	slices.Sort(a.Permissions)
+	return a
+}
+
+func NewUserRolesResponse(guid string, a *UserAuthorization) UserRolesResponse {
+	return UserRolesResponse{
+		Guid:              guid,
+		Roles:             a.Roles,
+		Permissions:       a.Permissions,
+		PermissionVersion: a.Version,
+	}
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+type TenantRequest struct {
+	Slug                string   `json:"slug"`
+	Name                string   `json:"name"`
+	Domains             []string `json:"domains"`
+	Issuer              string   `json:"issuer"`
+	Audience            []string `json:"audience"`
+	DefaultScopes       []string `json:"default_scopes"`
+	AccessTokenLifetime int64    `json:"access_token_lifetime"`
+	Disabled            bool     `json:"disabled"`
+}
+
+type TenantResponse struct {
+	Slug                string   `json:"slug"`
+	Name                string   `json:"name"`
+	Domains             []string `json:"domains"`
+	Issuer              string   `json:"issuer"`
+	Audience            []string `json:"audience"`
+	DefaultScopes       []string `json:"default_scopes"`
+	AccessTokenLifetime int64    `json:"access_token_lifetime"`
+	Disabled            bool     `json:"disabled"`
+}
+
+type MemberRequest struct {
+	Role string `json:"role"`
+}
+
+type MemberResponse struct {
+	Guid     string    `json:"guid"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// Tenant - арендатор (организация-клиент сервиса). Пустые Issuer, Audience, DefaultScopes
+// и нулевой AccessTokenLifetime (секунды) означают значения из секции jwt конфигурации.
+// Domains - имена хостов, по которым арендатор определяется из заголовка Host.
+type Tenant struct {
+	ID                  uint   `gorm:"primarykey"`
+	Slug                string `gorm:"uniqueIndex;not null"`
+	Name                string
+	Domains             []string `gorm:"serializer:json"`
+	Issuer              string
+	Audience            []string `gorm:"serializer:json"`
+	DefaultScopes       []string `gorm:"serializer:json"`
+	AccessTokenLifetime int64
+	Disabled            bool
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+// TenantMember - членство пользователя в арендаторе. Пользователь может состоять в нескольких
+// арендаторах, но токены и сессии выдаются в рамках одного. Role - роль в организации
+// (consts.MemberRole*), не связанная с ролями RBAC. PermissionVersion увеличивается при
+// изменении ролей RBAC пользователя в арендаторе.
+type TenantMember struct {
+	TenantID          uint   `gorm:"primaryKey"`
+	UserGuid          string `gorm:"primaryKey"`
+	Tenant            Tenant `gorm:"constraint:OnDelete:CASCADE"`
+	Role              string `gorm:"not null;default:member"`
+	PermissionVersion int64  `gorm:"not null;default:0"`
+	CreatedAt         time.Time
+}
+
+// Member - участник арендатора вместе с данными пользователя.
+type Member struct {
+	Guid     string
+	Name     string
+	Email    string
+	Role     string
+	JoinedAt time.Time
+}
+
+func (t *Tenant) HasDomain(host string) bool {
+	return slices.Contains(t.Domains, strings.ToLower(host))
+}
+
+// AccessTokenTTL возвращает время жизни access токенов арендатора или def.
+func (t *Tenant) AccessTokenTTL(def time.Duration) time.Duration {
+	if t.AccessTokenLifetime > 0 {
+		return time.Duration(t.AccessTokenLifetime) * time.Second
+	}
+	return def
+}
+
+// TokenIssuer возвращает iss токенов арендатора или def.
+func (t *Tenant) TokenIssuer(def string) string {
+	if t.Issuer != "" {
+		return t.Issuer
+	}
+	return def
+}
+
+func NewTenantResponse(t *Tenant) TenantResponse {
+	return TenantResponse{
+		Slug:                t.Slug,
+		Name:                t.Name,
+		Domains:             t.Domains,
+		Issuer:              t.Issuer,
+		Audience:            t.Audience,
+		DefaultScopes:       t.DefaultScopes,
+		AccessTokenLifetime: t.AccessTokenLifetime,
+		Disabled:            t.Disabled,
+	}
+}
+
+func NewMemberResponse(m Member) MemberResponse {
+	return MemberResponse{
+		Guid:     m.Guid,
+		Name:     m.Name,
+		Email:    m.Email,
+		Role:     m.Role,
+		JoinedAt: m.JoinedAt,
+	}
+}
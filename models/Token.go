@@ -1,31 +1,59 @@
 package models
 
 import (
-	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
 type TokenRequest struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope,omitempty"`
 }
 type TokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope,omitempty"`
 }
 
+// Token - refresh сессия пользователя UserGuid в арендаторе; схема - migrations.
+// Завершённая сессия (выход, обновление токенов, отключение пользователя) удаляется сразу:
+// мягкого удаления у сессий нет, и отозванный refresh токен не остаётся в базе. Сессия ссылается
+// на users.guid внешним ключом с ON DELETE CASCADE, но пользователи удаляются мягко, поэтому
+// при удалении пользователя его сессии удаляются явно (revokeSessions).
+// Refresh токен имеет вид selector.verifier: сессия ищется по Selector, RefreshToken хранит
+// SHA-256 verifier. У сессий, созданных до selector, Selector пуст, а RefreshToken - bcrypt
+// всего токена. AuthTime - момент аутентификации пользователя: при обновлении токенов
+// переходит в новую сессию.
 type Token struct {
-	gorm.Model
-	UserGuid     string
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	TenantID     uint   `gorm:"index;index:idx_tokens_user_tenant,priority:2"`
+	UserGuid     string `gorm:"not null;index:idx_tokens_user_tenant,priority:1"`
+	ClientID     string
+	Scopes       []string `gorm:"serializer:json"`
 	UserAgent    string
 	IpAddress    string
-	RefreshToken string    `json:"refresh_token"`
-	ExpiresAt    time.Time `json:"expires_in"`
+	Selector     string    `gorm:"uniqueIndex"`
+	RefreshToken string    `json:"refresh_token" gorm:"uniqueIndex;not null"`
+	ExpiresAt    time.Time `json:"expires_in" gorm:"index;not null"`
+	AuthTime     time.Time
 }
 
-func NewTokenResponse(access, refresh string) TokenResponse {
+func NewTokenResponse(access, refresh string, scopes []string) TokenResponse {
 	return TokenResponse{
 		AccessToken:  access,
 		RefreshToken: refresh,
+		Scope:        strings.Join(scopes, " "),
 	}
 }
+
+// DeniedToken - отозванный до истечения access токен: сессия пользователя UserGuid с подписью
+// refresh_sig завершена. Запись нужна до ExpiresAt - момента истечения самого токена.
+type DeniedToken struct {
+	TenantID  uint      `gorm:"primaryKey;autoIncrement:false"`
+	UserGuid  string    `gorm:"primaryKey"`
+	Sig       string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"index"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"gorm.io/gorm"
 	"time"
 )
 
@@ -12,11 +13,78 @@ type Logout struct {
 	Msg string `json:"msg"`
 }
 
+// UserSearch - фильтр административного поиска пользователей арендатора. Query ищет подстроку
+// в GUID, имени и email; Deleted выбирает только удалённых пользователей.
+type UserSearch struct {
+	ListRequest
+	Query         string
+	Disabled      *bool
+	Deleted       bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// SessionSearch - фильтр refresh сессий арендатора. Active выбирает действующие (true)
+// или истёкшие (false) сессии.
+type SessionSearch struct {
+	ListRequest
+	UserGuid string
+	ClientID string
+	Active   *bool
+}
+
+type AdminUserResponse struct {
+	Guid          string     `json:"guid"`
+	Name          string     `json:"name"`
+	Email         string     `json:"email"`
+	EmailVerified bool       `json:"email_verified"`
+	Disabled      bool       `json:"disabled"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+}
+
+type UserPageResponse struct {
+	Items      []AdminUserResponse `json:"items"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+type SessionResponse struct {
+	ID        uint      `json:"id"`
+	UserGuid  string    `json:"user_guid"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Scopes    []string  `json:"scopes"`
+	UserAgent string    `json:"user_agent"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type SessionPageResponse struct {
+	Items      []SessionResponse `json:"items"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+type UserDetailResponse struct {
+	AdminUserResponse
+	Role     string            `json:"role"`
+	Sessions []SessionResponse `json:"sessions"`
+}
+
+// User.PermissionVersion увеличивается при каждом изменении ролей пользователя или
+// разрешений его ролей: токены с другой версией (claim pv) считаются устаревшими.
+// Отключённый (Disabled) пользователь не может получить или обновить токены, удалённый
+// (DeletedAt) не находится обычными запросами.
 type User struct {
-	Guid      string `gorm:"unique;not null"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt time.Time `gorm:"index"`
+	Guid              string `gorm:"primaryKey"`
+	Name              string
+	Email             string
+	EmailVerified     bool
+	Disabled          bool  `gorm:"not null;default:false"`
+	PermissionVersion int64 `gorm:"not null;default:1"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
 }
 
 func NewUserResponse(guid string) UserResponse {
@@ -24,3 +92,32 @@ func NewUserResponse(guid string) UserResponse {
 		Guid: guid,
 	}
 }
+
+func NewAdminUserResponse(u *User) AdminUserResponse {
+	resp := AdminUserResponse{
+		Guid:          u.Guid,
+		Name:          u.Name,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		Disabled:      u.Disabled,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+	}
+	if u.DeletedAt.Valid {
+		resp.DeletedAt = &u.DeletedAt.Time
+	}
+	return resp
+}
+
+func NewSessionResponse(t *Token) SessionResponse {
+	return SessionResponse{
+		ID:        t.ID,
+		UserGuid:  t.UserGuid,
+		ClientID:  t.ClientID,
+		Scopes:    t.Scopes,
+		UserAgent: t.UserAgent,
+		IP:        t.IpAddress,
+		CreatedAt: t.CreatedAt,
+		ExpiresAt: t.ExpiresAt,
+	}
+}
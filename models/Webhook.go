@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// Состояния доставки webhook'а: pending - ждёт отправки или повтора, delivered - получатель
+// ответил 2xx, dead - попытки исчерпаны.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookDead      = "dead"
+)
+
+// RawJSON - JSON документ, который хранится текстом, а в ответах API выводится как есть.
+type RawJSON string
+
+func (r RawJSON) MarshalJSON() ([]byte, error) {
+	if r == "" {
+		return []byte("null"), nil
+	}
+	return []byte(r), nil
+}
+
+// WebhookDelivery - событие в outbox webhook'ов. LockedUntil - срок, до которого доставку
+// отправляет занявший её воркер; после него доставку может занять другой воркер или реплика.
+// RequestID и TraceParent - запрос, в котором возникло событие: отправка продолжает его трассу.
+type WebhookDelivery struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Event         string     `json:"event"`
+	Payload       RawJSON    `json:"payload" swaggertype:"object"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LockedUntil   *time.Time `json:"-"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastStatus    int        `json:"last_status,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	RequestID     string     `json:"request_id,omitempty"`
+	TraceParent   string     `json:"-"`
+}
+
+// WebhookSearch - фильтр доставок. Пустые поля не ограничивают выборку.
+type WebhookSearch struct {
+	ListRequest
+	Status string
+	Event  string
+}
+
+type WebhookPageResponse struct {
+	Items      []WebhookDelivery `json:"items"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// WebhookReplayRequest - повторная отправка всех недоставленных (dead) доставок события
+// Event; пустой Event - любого события.
+type WebhookReplayRequest struct {
+	Event string `json:"event"`
+}
+
+type WebhookReplayResponse struct {
+	Replayed int64 `json:"replayed"`
+}
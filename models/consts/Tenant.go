@@ -0,0 +1,13 @@
+package consts
+
+// DefaultTenant - арендатор, создаваемый при миграции. В него попадают существующие
+// пользователи, и он используется, если арендатор запроса не указан.
+const DefaultTenant = "default"
+
+// Роли участника арендатора (организации). Владельцы и администраторы управляют
+// участниками и приглашениями, назначать и снимать владельцев могут только владельцы.
+const (
+	MemberRoleOwner  = "owner"
+	MemberRoleAdmin  = "admin"
+	MemberRoleMember = "member"
+)
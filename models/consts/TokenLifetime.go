@@ -3,3 +3,12 @@ package consts
 import "time"
 
 const TokenRefreshLifeTime = time.Hour
+
+const AuthorizationCodeLifeTime = time.Minute
+
+const DeviceCodeLifeTime = 10 * time.Minute
+
+// DevicePollInterval - минимальный интервал опроса token endpoint'а клиентом (RFC 8628, 3.5).
+const DevicePollInterval = 5 * time.Second
+
+const InvitationLifeTime = 7 * 24 * time.Hour
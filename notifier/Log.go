@@ -0,0 +1,13 @@
+package notifier
+
+import (
+	"log/slog"
+)
+
+// logNotifier пишет уведомления в лог - для разработки, когда почта не настроена.
+type logNotifier struct{}
+
+func (n *logNotifier) Send(msg Message) error {
+	slog.Info("Notification", "kind", msg.Kind, "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
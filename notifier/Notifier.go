@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"auth-service/config"
+	"fmt"
+)
+
+const (
+	TypeLog     = "log"
+	TypeWebhook = "webhook"
+	TypeSMTP    = "smtp"
+)
+
+// Message - уведомление пользователю. Data содержит значения для шаблонов на стороне
+// получателя (например, ссылку и токен приглашения).
+type Message struct {
+	Kind    string            `json:"kind"`
+	To      string            `json:"to"`
+	Subject string            `json:"subject"`
+	Body    string            `json:"body"`
+	Data    map[string]string `json:"data,omitempty"`
+}
+
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// New создаёт отправителя по notifier.type. Без настройки уведомления пишутся в лог.
+func New(c config.Config) (Notifier, error) {
+	switch c.Notifier.Type {
+	case "", TypeLog:
+		return &logNotifier{}, nil
+	case TypeWebhook:
+		if c.Notifier.Url == "" {
+			return nil, fmt.Errorf("notifier.url is required for %s notifier", TypeWebhook)
+		}
+		return &webhookNotifier{url: c.Notifier.Url}, nil
+	case TypeSMTP:
+		if c.Notifier.Smtp.Host == "" || c.Notifier.Smtp.From == "" {
+			return nil, fmt.Errorf("notifier.smtp.host and notifier.smtp.from are required for %s notifier", TypeSMTP)
+		}
+		return newSMTPNotifier(c), nil
+	}
+	return nil, fmt.Errorf("unknown notifier type %q", c.Notifier.Type)
+}
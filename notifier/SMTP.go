@@ -0,0 +1,52 @@
+package notifier
+
+import (
+	"auth-service/config"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+type smtpNotifier struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	envelope string
+}
+
+func newSMTPNotifier(c config.Config) *smtpNotifier {
+	s := c.Notifier.Smtp
+	n := &smtpNotifier{
+		addr:     net.JoinHostPort(s.Host, strconv.Itoa(s.Port)),
+		from:     s.From,
+		envelope: s.From,
+	}
+	// в заголовке From допускается имя отправителя, в SMTP передаётся только адрес
+	if addr, err := mail.ParseAddress(s.From); err == nil {
+		n.envelope = addr.Address
+	}
+	if s.Username != "" {
+		n.auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	return n
+}
+
+func (n *smtpNotifier) Send(msg Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", msg.To)
+	}
+	body := strings.Join([]string{
+		"From: " + n.from,
+		"To: " + msg.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		msg.Body,
+	}, "\r\n")
+	return smtp.SendMail(n.addr, n.auth, n.envelope, []string{msg.To}, []byte(body))
+}
@@ -0,0 +1,33 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// webhookNotifier передаёт уведомление JSON-запросом внешнему сервису рассылки.
+type webhookNotifier struct {
+	url string
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func (n *webhookNotifier) Send(msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	resp, err := webhookClient.Post(n.url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notifier webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// Condition сравнивает атрибут запроса Attr (путь через точку: subject.roles, resource.owner)
+// со значением Value или с другим атрибутом Ref.
+//
+// Операторы: eq, ne, in, not_in (атрибут входит в список), contains (список-атрибут содержит значение),
+// gt, gte, lt, lte (числа или строки), cidr, not_cidr (IP в одной из сетей / вне их),
+// matches (шаблон path.Match), exists (атрибут задан; value: false - не задан).
+type Condition struct {
+	Attr  string      `yaml:"attr"`
+	Op    string      `yaml:"op"`
+	Value interface{} `yaml:"value"`
+	Ref   string      `yaml:"ref"`
+}
+
+var operators = map[string]func(attr, value interface{}) bool{
+	"eq":       equal,
+	"ne":       func(a, v interface{}) bool { return !equal(a, v) },
+	"in":       func(a, v interface{}) bool { return listContains(v, a) },
+	"not_in":   func(a, v interface{}) bool { return !listContains(v, a) },
+	"contains": listContains,
+	"gt":       func(a, v interface{}) bool { c, ok := compare(a, v); return ok && c > 0 },
+	"gte":      func(a, v interface{}) bool { c, ok := compare(a, v); return ok && c >= 0 },
+	"lt":       func(a, v interface{}) bool { c, ok := compare(a, v); return ok && c < 0 },
+	"lte":      func(a, v interface{}) bool { c, ok := compare(a, v); return ok && c <= 0 },
+	"cidr":     inNetworks,
+	"not_cidr": func(a, v interface{}) bool { return !inNetworks(a, v) },
+	"matches":  matches,
+}
+
+func (c *Condition) validate() error {
+	if c.Attr == "" {
+		return fmt.Errorf("condition without attr")
+	}
+	if _, ok := operators[c.Op]; !ok && c.Op != "exists" {
+		return fmt.Errorf("unknown operator %q", c.Op)
+	}
+	if c.Op == "cidr" || c.Op == "not_cidr" {
+		for _, network := range toList(c.Value) {
+			s, _ := network.(string)
+			if _, _, err := net.ParseCIDR(s); err != nil {
+				return fmt.Errorf("bad network %v", network)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Condition) holds(attrs map[string]interface{}) bool {
+	attr, found := lookup(attrs, c.Attr)
+	if c.Op == "exists" {
+		want, ok := c.Value.(bool)
+		return found == (want || !ok)
+	}
+	if !found {
+		return false
+	}
+
+	value := c.Value
+	if c.Ref != "" {
+		ref, ok := lookup(attrs, c.Ref)
+		if !ok {
+			return false
+		}
+		value = ref
+	}
+	return operators[c.Op](normalize(attr), normalize(value))
+}
+
+// lookup находит атрибут по пути через точку во вложенных map.
+func lookup(attrs map[string]interface{}, key string) (interface{}, bool) {
+	var current interface{} = attrs
+	for _, part := range strings.Split(key, ".") {
+		m, ok := normalize(current).(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok || current == nil {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// normalize приводит значения из JSON, YAML и Go к общему виду: числа - float64,
+// срезы - []interface{}.
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case []string:
+		result := make([]interface{}, len(x))
+		for i, s := range x {
+			result[i] = s
+		}
+		return result
+	}
+	return v
+}
+
+func toList(v interface{}) []interface{} {
+	if list, ok := normalize(v).([]interface{}); ok {
+		return list
+	}
+	return []interface{}{v}
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func listContains(list, v interface{}) bool {
+	for _, item := range toList(list) {
+		if equal(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	}
+	return 0, false
+}
+
+func inNetworks(a, networks interface{}) bool {
+	s, _ := a.(string)
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+	for _, network := range toList(networks) {
+		cidr, _ := network.(string)
+		if _, n, err := net.ParseCIDR(cidr); err == nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(a, pattern interface{}) bool {
+	s, ok1 := a.(string)
+	p, ok2 := pattern.(string)
+	if !ok1 || !ok2 {
+		return false
+	}
+	ok, _ := path.Match(p, s)
+	return ok
+}
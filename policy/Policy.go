@@ -0,0 +1,205 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// DefaultRule - имя "правила" в решении, когда ни одно правило не подошло (запрет по умолчанию).
+const DefaultRule = "default-deny"
+
+// TestFileSuffix - файлы с тестами политик, LoadDir их пропускает.
+const TestFileSuffix = "_test.yml"
+
+// File - содержимое файла политик.
+type File struct {
+	Policies []Policy `yaml:"policies"`
+}
+
+type Policy struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Rules       []Rule `yaml:"rules"`
+}
+
+// Rule срабатывает, если действие и тип ресурса подходят под шаблоны (path.Match, "*" - любой)
+// и выполнены все условия When.
+type Rule struct {
+	ID        string      `yaml:"id"`
+	Effect    string      `yaml:"effect"`
+	Actions   []string    `yaml:"actions"`
+	Resources []string    `yaml:"resources"`
+	When      []Condition `yaml:"when"`
+}
+
+// Request - запрос на решение. Атрибуты адресуются в условиях как subject.*, resource.*,
+// environment.* и action. Тип ресурса - атрибут resource.type.
+type Request struct {
+	Subject     map[string]interface{} `json:"subject" yaml:"subject"`
+	Action      string                 `json:"action" yaml:"action"`
+	Resource    map[string]interface{} `json:"resource" yaml:"resource"`
+	Environment map[string]interface{} `json:"environment" yaml:"environment"`
+}
+
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Effect  string `json:"effect"`
+	Policy  string `json:"policy,omitempty"`
+	Rule    string `json:"rule"`
+}
+
+// Engine вычисляет решения по набору политик. Запрет имеет приоритет: если подошло хотя бы
+// одно deny правило, доступ запрещён; иначе решение определяет первое подошедшее allow правило;
+// если не подошло ничего - запрет по умолчанию.
+type Engine struct {
+	policies []Policy
+}
+
+func NewEngine(policies ...Policy) (*Engine, error) {
+	for _, p := range policies {
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &Engine{policies: policies}, nil
+}
+
+// LoadDir загружает все *.yml/*.yaml файлы каталога, кроме тестов (*_test.yml), в порядке имён.
+func LoadDir(dir string) (*Engine, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasSuffix(name, TestFileSuffix) {
+			continue
+		}
+		if ext := filepath.Ext(name); ext == ".yml" || ext == ".yaml" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	policies := []Policy{}
+	for _, name := range names {
+		f, err := loadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, f.Policies...)
+	}
+	return NewEngine(policies...)
+}
+
+func loadFile(filename string) (*File, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := yaml.Unmarshal(content, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &f, nil
+}
+
+func (e *Engine) Policies() []Policy {
+	return e.policies
+}
+
+func (e *Engine) Evaluate(req Request) Decision {
+	attrs := req.attributes()
+
+	var allowed *Decision
+	for _, p := range e.policies {
+		for _, r := range p.Rules {
+			if !r.matches(req, attrs) {
+				continue
+			}
+			if r.Effect == EffectDeny {
+				return Decision{Allowed: false, Effect: EffectDeny, Policy: p.Name, Rule: r.ID}
+			}
+			if allowed == nil {
+				allowed = &Decision{Allowed: true, Effect: EffectAllow, Policy: p.Name, Rule: r.ID}
+			}
+		}
+	}
+	if allowed != nil {
+		return *allowed
+	}
+	return Decision{Allowed: false, Effect: EffectDeny, Rule: DefaultRule}
+}
+
+func (r *Rule) matches(req Request, attrs map[string]interface{}) bool {
+	resourceType, _ := req.Resource["type"].(string)
+	if !matchAny(r.Actions, req.Action) || !matchAny(r.Resources, resourceType) {
+		return false
+	}
+	for _, c := range r.When {
+		if !c.holds(attrs) {
+			return false
+		}
+	}
+	return true
+}
+
+func (req Request) attributes() map[string]interface{} {
+	return map[string]interface{}{
+		"subject":     req.Subject,
+		"action":      req.Action,
+		"resource":    req.Resource,
+		"environment": req.Environment,
+	}
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) validate() error {
+	if p.Name == "" {
+		return errors.New("policy without name")
+	}
+	ids := map[string]bool{}
+	for _, r := range p.Rules {
+		if r.ID == "" || ids[r.ID] {
+			return fmt.Errorf("policy %s: rule id is empty or duplicated: %q", p.Name, r.ID)
+		}
+		ids[r.ID] = true
+		if r.Effect != EffectAllow && r.Effect != EffectDeny {
+			return fmt.Errorf("policy %s, rule %s: effect must be allow or deny", p.Name, r.ID)
+		}
+		if len(r.Actions) == 0 || len(r.Resources) == 0 {
+			return fmt.Errorf("policy %s, rule %s: actions and resources are required", p.Name, r.ID)
+		}
+		for _, pattern := range append(append([]string{}, r.Actions...), r.Resources...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("policy %s, rule %s: bad pattern %q", p.Name, r.ID, pattern)
+			}
+		}
+		for _, c := range r.When {
+			if err := c.validate(); err != nil {
+				return fmt.Errorf("policy %s, rule %s: %w", p.Name, r.ID, err)
+			}
+		}
+	}
+	return nil
+}
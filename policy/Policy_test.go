@@ -0,0 +1,48 @@
+package policy
+
+import "testing"
+
+// TestPolicies выполняет тесты политик из policies/*_test.yml (то же, что go run . policy-test).
+func TestPolicies(t *testing.T) {
+	engine, err := LoadDir("../policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := RunTests(engine, "../policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 {
+		t.Fatal("no policy tests in ../policies")
+	}
+	for _, r := range results {
+		t.Run(r.File+"/"+r.Name, func(t *testing.T) {
+			if !r.Passed {
+				t.Errorf("%s (decision %s by %s)", r.Message, r.Decision.Effect, r.Decision.Rule)
+			}
+		})
+	}
+}
+
+func TestRunReportsMismatch(t *testing.T) {
+	engine, err := LoadDir("../policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := TestCase{
+		Name:    "anonymous update",
+		Request: Request{Action: "users:update"},
+		Expect:  EffectAllow,
+		Rule:    "admins-manage-users",
+	}
+	result := tc.Run(engine)
+	if result.Passed {
+		t.Fatalf("expected failure, decision %+v", result.Decision)
+	}
+	if result.Message != "expected allow, got deny; expected rule admins-manage-users, got default-deny" {
+		t.Errorf("message = %q", result.Message)
+	}
+	if tc.Expect = "permit"; tc.Run(engine).Passed {
+		t.Error("invalid expect accepted")
+	}
+}
@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TestFile - файл тестов политик (*_test.yml рядом с политиками).
+type TestFile struct {
+	Tests []TestCase `yaml:"tests"`
+}
+
+// TestCase описывает запрос и ожидаемое решение. Rule, если указан, проверяет,
+// каким правилом принято решение.
+type TestCase struct {
+	Name    string  `yaml:"name"`
+	Request Request `yaml:"request"`
+	Expect  string  `yaml:"expect"`
+	Rule    string  `yaml:"rule"`
+}
+
+type TestResult struct {
+	File     string
+	Name     string
+	Passed   bool
+	Decision Decision
+	Message  string
+}
+
+// RunTests выполняет все *_test.yml файлы каталога dir на движке engine.
+func RunTests(engine *Engine, dir string) ([]TestResult, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+TestFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	results := []TestResult{}
+	for _, filename := range files {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		var f TestFile
+		if err := yaml.Unmarshal(content, &f); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		for _, tc := range f.Tests {
+			result := tc.Run(engine)
+			result.File = filepath.Base(filename)
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+func (tc TestCase) Run(engine *Engine) TestResult {
+	d := engine.Evaluate(tc.Request)
+	result := TestResult{Name: tc.Name, Decision: d, Passed: true}
+
+	if tc.Expect != EffectAllow && tc.Expect != EffectDeny {
+		result.Passed = false
+		result.Message = fmt.Sprintf("expect must be allow or deny, got %q", tc.Expect)
+		return result
+	}
+	failures := []string{}
+	if d.Effect != tc.Expect {
+		failures = append(failures, fmt.Sprintf("expected %s, got %s", tc.Expect, d.Effect))
+	}
+	if tc.Rule != "" && d.Rule != tc.Rule {
+		failures = append(failures, fmt.Sprintf("expected rule %s, got %s", tc.Rule, d.Rule))
+	}
+	if len(failures) > 0 {
+		result.Passed = false
+		result.Message = strings.Join(failures, "; ")
+	}
+	return result
+}
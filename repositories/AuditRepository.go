@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+// auditLockID - ключ pg_advisory_xact_lock, под которым реплики по очереди дописывают цепочку.
+const auditLockID = 7234918244
+
+// AuditRepository - журнал аудита. Записи только добавляются.
+type AuditRepository interface {
+	// Append дописывает events в конец цепочки одной транзакцией: заполняет PrevHash, Hash и
+	// CreatedAt, если оно не задано.
+	Append(ctx context.Context, events ...*models.AuditEvent) error
+	List(ctx context.Context, q Query) (*Page[models.AuditEvent], error)
+	// Chain возвращает до limit записей с id больше afterID по возрастанию id.
+	Chain(ctx context.Context, afterID uint, limit int) ([]models.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+	// mu упорядочивает запись в пределах процесса; между репликами - advisory lock.
+	mu sync.Mutex
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Append держит блокировку цепочки на время одной транзакции: чтения последнего hash и вставки
+// пачки. Запись идёт строго по очереди, поэтому пачки нужны, чтобы блокировка бралась не
+// на каждое событие (см. AuditService.Start).
+func (r *auditRepository) Append(ctx context.Context, events ...*models.AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditLockID).Error; err != nil {
+				return err
+			}
+		}
+		var last models.AuditEvent
+		err := tx.Select("hash").Order("id DESC").Take(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		now := time.Now()
+		prev := last.Hash
+		for _, e := range events {
+			e.ID = 0
+			if e.CreatedAt.IsZero() {
+				e.CreatedAt = now
+			}
+			e.CreatedAt = e.CreatedAt.UTC().Truncate(time.Microsecond)
+			e.PrevHash = prev
+			e.Hash = e.ComputeHash()
+			prev = e.Hash
+		}
+		return tx.Create(events).Error
+	})
+}
+
+// List - страница журнала по Query.
+func (r *auditRepository) List(ctx context.Context, q Query) (*Page[models.AuditEvent], error) {
+	return list(r.db.WithContext(ctx).Model(&models.AuditEvent{}), q, auditListSpec)
+}
+
+var auditListSpec = listSpec[models.AuditEvent]{
+	fields: map[string]field[models.AuditEvent]{
+		"id":         column("id", func(e *models.AuditEvent) uint { return e.ID }),
+		"created_at": column("created_at", func(e *models.AuditEvent) time.Time { return e.CreatedAt }),
+		"tenant_id":  column("tenant_id", func(e *models.AuditEvent) uint { return e.TenantID }),
+		"action":     column("action", func(e *models.AuditEvent) string { return e.Action }),
+		"outcome":    column("outcome", func(e *models.AuditEvent) string { return e.Outcome }),
+		"actor":      column("actor", func(e *models.AuditEvent) string { return e.Actor }),
+		"subject":    column("subject", func(e *models.AuditEvent) string { return e.Subject }),
+		"ip":         column("ip", func(e *models.AuditEvent) string { return e.IP }),
+	},
+	key:         "id",
+	defaultSort: []Sort{{Field: "id", Desc: true}},
+}
+
+func (r *auditRepository) Chain(ctx context.Context, afterID uint, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	err := r.db.WithContext(ctx).Where("id > ?", afterID).Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type AuthorizationCodeRepository interface {
+	Create(c *models.AuthorizationCode) error
+	FindByHash(hash string) (*models.AuthorizationCode, error)
+	MarkUsed(id uint) (bool, error)
+	// Purge удаляет до limit кодов, истёкших до before (использованные коды истекают так же).
+	Purge(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type authorizationCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewAuthorizationCodeRepository(db *gorm.DB) AuthorizationCodeRepository {
+	return &authorizationCodeRepository{db: db}
+}
+
+func (r *authorizationCodeRepository) Create(code *models.AuthorizationCode) error {
+	return r.db.Create(code).Error
+}
+
+func (r *authorizationCodeRepository) FindByHash(hash string) (*models.AuthorizationCode, error) {
+	var code models.AuthorizationCode
+	err := r.db.Where("code_hash = ?", hash).First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// MarkUsed помечает код использованным. false означает, что код уже был погашен
+// параллельным запросом.
+func (r *authorizationCodeRepository) MarkUsed(id uint) (bool, error) {
+	res := r.db.Model(&models.AuthorizationCode{}).
+		Where("id = ? AND used = ?", id, false).
+		Update("used", true)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *authorizationCodeRepository) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	batch := r.db.Unscoped().Model(&models.AuthorizationCode{}).Select("id").
+		Where("expires_at < ?", before).Limit(limit)
+	res := r.db.WithContext(ctx).Unscoped().Where("id IN (?)", batch).Delete(&models.AuthorizationCode{})
+	return res.RowsAffected, res.Error
+}
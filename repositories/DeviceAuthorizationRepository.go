@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"auth-service/models/consts"
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+type DeviceAuthorizationRepository interface {
+	Create(d *models.DeviceAuthorization) error
+	Update(d *models.DeviceAuthorization) error
+	FindByDeviceCodeHash(hash string) (*models.DeviceAuthorization, error)
+	FindByUserCode(userCode string) (*models.DeviceAuthorization, error)
+	MarkConsumed(id uint) (bool, error)
+	// Purge удаляет до limit запросов, истёкших до before.
+	Purge(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type deviceAuthorizationRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceAuthorizationRepository(db *gorm.DB) DeviceAuthorizationRepository {
+	return &deviceAuthorizationRepository{db: db}
+}
+
+func (r *deviceAuthorizationRepository) Create(d *models.DeviceAuthorization) error {
+	return r.db.Create(d).Error
+}
+
+func (r *deviceAuthorizationRepository) Update(d *models.DeviceAuthorization) error {
+	return r.db.Save(d).Error
+}
+
+func (r *deviceAuthorizationRepository) FindByDeviceCodeHash(hash string) (*models.DeviceAuthorization, error) {
+	var d models.DeviceAuthorization
+	err := r.db.Where("device_code_hash = ?", hash).First(&d).Error
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *deviceAuthorizationRepository) FindByUserCode(userCode string) (*models.DeviceAuthorization, error) {
+	var d models.DeviceAuthorization
+	err := r.db.Where("user_code = ?", userCode).First(&d).Error
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// MarkConsumed переводит одобренный запрос в consumed, чтобы токены по нему выдавались один раз.
+func (r *deviceAuthorizationRepository) MarkConsumed(id uint) (bool, error) {
+	res := r.db.Model(&models.DeviceAuthorization{}).
+		Where("id = ? AND status = ?", id, consts.DeviceStatusApproved).
+		Update("status", consts.DeviceStatusConsumed)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *deviceAuthorizationRepository) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	batch := r.db.Unscoped().Model(&models.DeviceAuthorization{}).Select("id").
+		Where("expires_at < ?", before).Limit(limit)
+	res := r.db.WithContext(ctx).Unscoped().Where("id IN (?)", batch).Delete(&models.DeviceAuthorization{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+type InvitationRepository interface {
+	Create(i *models.Invitation) error
+	FindByID(tenantID uint, id uint) (*models.Invitation, error)
+	FindByTokenID(tokenID string) (*models.Invitation, error)
+	GetPending(tenantID uint) ([]models.Invitation, error)
+	Delete(tenantID uint, id uint) error
+	Accept(i *models.Invitation, user *models.User) (guid string, created bool, err error)
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func NewInvitationRepository(db *gorm.DB) InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+func (r *invitationRepository) Create(i *models.Invitation) error {
+	return r.db.Omit("Tenant").Create(i).Error
+}
+
+func (r *invitationRepository) FindByID(tenantID uint, id uint) (*models.Invitation, error) {
+	var i models.Invitation
+	if err := r.db.Where("tenant_id = ?", tenantID).First(&i, id).Error; err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (r *invitationRepository) FindByTokenID(tokenID string) (*models.Invitation, error) {
+	var i models.Invitation
+	if err := r.db.Where("token_id = ?", tokenID).First(&i).Error; err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// GetPending возвращает непринятые и неистёкшие приглашения арендатора.
+func (r *invitationRepository) GetPending(tenantID uint) ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := r.db.
+		Where("tenant_id = ? AND accepted_at IS NULL AND expires_at > ?", tenantID, time.Now()).
+		Order("created_at").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *invitationRepository) Delete(tenantID uint, id uint) error {
+	result := r.db.Where("tenant_id = ? AND accepted_at IS NULL", tenantID).Delete(&models.Invitation{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Accept принимает приглашение: находит пользователя по email приглашения или создаёт user,
+// добавляет его в арендатора с ролью из приглашения. Уже состоящий в арендаторе пользователь -
+// gorm.ErrDuplicatedKey, повторно принятое приглашение - gorm.ErrRecordNotFound.
+func (r *invitationRepository) Accept(i *models.Invitation, user *models.User) (string, bool, error) {
+	guid, created := "", false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.User
+		err := tx.Where("LOWER(email) = ?", strings.ToLower(i.Email)).Order("created_at").First(&existing).Error
+		switch {
+		case err == nil:
+			guid = existing.Guid
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(user).Error; err != nil {
+				return err
+			}
+			guid, created = user.Guid, true
+		default:
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.TenantMember{}).Where("tenant_id = ? AND user_guid = ?", i.TenantID, guid).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return gorm.ErrDuplicatedKey
+		}
+		member := models.TenantMember{TenantID: i.TenantID, UserGuid: guid, Role: i.Role}
+		if err := tx.Omit("Tenant").Create(&member).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL", i.ID).
+			Updates(map[string]interface{}{"accepted_at": time.Now(), "accepted_by": guid})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	return guid, created, err
+}
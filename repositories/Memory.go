@@ -0,0 +1,207 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory - хранилище пользователей, членства в арендаторах и refresh сессий в памяти процесса
+// для тестов и разработки. Общее для NewMemoryUserRepository и NewMemoryTokenRepository:
+// отключение и удаление пользователя завершают его сессии, как и в базе данных.
+// Безопасно для одновременного использования.
+type Memory struct {
+	mu          sync.RWMutex
+	users       map[string]models.User
+	members     map[uint]map[string]bool
+	tokens      map[uint]models.Token
+	lastTokenID uint
+	// denied - отозванные access токены: ключ deniedKey, значение - срок записи.
+	denied map[string]time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:   map[string]models.User{},
+		members: map[uint]map[string]bool{},
+		tokens:  map[uint]models.Token{},
+		denied:  map[string]time.Time{},
+	}
+}
+
+// revokeSessions - аналог revokeSessions для памяти. Вызывается под блокировкой на запись.
+func (m *Memory) revokeSessions(guid string) {
+	for id, t := range m.tokens {
+		if t.UserGuid == guid {
+			delete(m.tokens, id)
+		}
+	}
+	u := m.users[guid]
+	u.PermissionVersion++
+	m.users[guid] = u
+}
+
+// listMemory выполняет Query над срезом items так же, как list над таблицей: те же поля,
+// операторы, сортировка и курсоры. deleted сообщает, удалена ли запись мягко.
+func listMemory[T any](items []T, q Query, spec listSpec[T], deleted func(*T) bool) (*Page[T], error) {
+	limit, sorts, fields, err := spec.resolve(q)
+	if err != nil {
+		return nil, err
+	}
+	matchers := make([]func(*T) bool, len(q.Filters))
+	for i, f := range q.Filters {
+		m, err := memoryFilter(f, spec)
+		if err != nil {
+			return nil, err
+		}
+		matchers[i] = m
+	}
+
+	signature := sortSignature(sorts)
+	var after []interface{}
+	if q.Cursor != "" {
+		values, err := decodeCursor(q.Cursor, signature, fields)
+		if err != nil {
+			return nil, err
+		}
+		after = values
+	}
+	rowCompare := func(a *T, values []interface{}) int {
+		for i, f := range fields {
+			c, _ := compareValues(f.value(a), values[i])
+			if sorts[i].Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+
+	search := strings.ToLower(q.Search)
+	matches := func(item *T) bool {
+		if deleted(item) != q.OnlyDeleted || (after != nil && rowCompare(item, after) <= 0) {
+			return false
+		}
+		for _, m := range matchers {
+			if !m(item) {
+				return false
+			}
+		}
+		if search == "" {
+			return true
+		}
+		for _, name := range spec.search {
+			s, _ := spec.fields[name].value(item).(string)
+			if strings.Contains(strings.ToLower(s), search) {
+				return true
+			}
+		}
+		return false
+	}
+	var result []T
+	for i := range items {
+		if matches(&items[i]) {
+			result = append(result, items[i])
+		}
+	}
+	slices.SortFunc(result, func(a, b T) int {
+		values := make([]interface{}, len(fields))
+		for i, f := range fields {
+			values[i] = f.value(&b)
+		}
+		return rowCompare(&a, values)
+	})
+
+	page := &Page[T]{Items: result}
+	if len(result) > limit {
+		page.Items = result[:limit]
+		next, err := encodeCursor(signature, fields, &page.Items[limit-1])
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+	return page, nil
+}
+
+func memoryFilter[T any](f Filter, spec listSpec[T]) (func(*T) bool, error) {
+	fd, ok := spec.fields[f.Field]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown filter field %q", ErrInvalidQuery, f.Field)
+	}
+	compare := func(item *T, accept func(int) bool) bool {
+		c, ok := compareValues(fd.value(item), f.Value)
+		return ok && accept(c)
+	}
+	switch f.Op {
+	case OpEq:
+		return func(t *T) bool { return compare(t, func(c int) bool { return c == 0 }) }, nil
+	case OpNe:
+		return func(t *T) bool { return compare(t, func(c int) bool { return c != 0 }) }, nil
+	case OpLt:
+		return func(t *T) bool { return compare(t, func(c int) bool { return c < 0 }) }, nil
+	case OpLte:
+		return func(t *T) bool { return compare(t, func(c int) bool { return c <= 0 }) }, nil
+	case OpGt:
+		return func(t *T) bool { return compare(t, func(c int) bool { return c > 0 }) }, nil
+	case OpGte:
+		return func(t *T) bool { return compare(t, func(c int) bool { return c >= 0 }) }, nil
+	case OpIn:
+		values := reflect.ValueOf(f.Value)
+		if values.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("%w: %s expects a list", ErrInvalidQuery, OpIn)
+		}
+		return func(t *T) bool {
+			v := fd.value(t)
+			for i := 0; i < values.Len(); i++ {
+				if c, ok := compareValues(v, values.Index(i).Interface()); ok && c == 0 {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case OpContains:
+		sub, _ := f.Value.(string)
+		sub = strings.ToLower(sub)
+		return func(t *T) bool {
+			s, _ := fd.value(t).(string)
+			return strings.Contains(strings.ToLower(s), sub)
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidQuery, f.Op)
+}
+
+// compareValues сравнивает значения полей одного типа; false - типы несравнимы.
+func compareValues(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		return strings.Compare(x, y), ok
+	case bool:
+		y, ok := b.(bool)
+		switch {
+		case x == y:
+			return 0, ok
+		case !x:
+			return -1, ok
+		}
+		return 1, ok
+	case uint:
+		y, ok := b.(uint)
+		return cmp.Compare(x, y), ok
+	case int64:
+		y, ok := b.(int64)
+		return cmp.Compare(x, y), ok
+	case time.Time:
+		y, ok := b.(time.Time)
+		return x.Compare(y), ok
+	}
+	return 0, false
+}
@@ -0,0 +1,211 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"cmp"
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"slices"
+	"time"
+)
+
+type memoryTokenRepository struct {
+	m *Memory
+}
+
+// NewMemoryTokenRepository - TokenRepository поверх Memory.
+func NewMemoryTokenRepository(m *Memory) TokenRepository {
+	return &memoryTokenRepository{m: m}
+}
+
+func (r *memoryTokenRepository) Create(_ context.Context, t *models.Token) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.create(t)
+	return nil
+}
+
+// create сохраняет копию t под новым ID. Вызывается под блокировкой на запись.
+func (r *memoryTokenRepository) create(t *models.Token) {
+	r.m.lastTokenID++
+	now := time.Now()
+	t.ID = r.m.lastTokenID
+	t.CreatedAt, t.UpdatedAt = now, now
+	stored := *t
+	stored.Scopes = slices.Clone(t.Scopes)
+	r.m.tokens[t.ID] = stored
+}
+
+func (r *memoryTokenRepository) DeleteByID(_ context.Context, tenantID uint, id uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.delete(tenantID, id)
+	return nil
+}
+
+// delete удаляет сессию арендатора; false - сессии нет или она уже удалена.
+// Вызывается под блокировкой на запись.
+func (r *memoryTokenRepository) delete(tenantID uint, id uint) bool {
+	t, ok := r.m.tokens[id]
+	if !ok || t.TenantID != tenantID {
+		return false
+	}
+	delete(r.m.tokens, id)
+	return true
+}
+
+func (r *memoryTokenRepository) Rotate(_ context.Context, tenantID uint, oldID uint, t *models.Token) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if !r.delete(tenantID, oldID) {
+		return gorm.ErrRecordNotFound
+	}
+	r.create(t)
+	return nil
+}
+
+func (r *memoryTokenRepository) DeleteByUserGUID(_ context.Context, guid string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for id, t := range r.m.tokens {
+		if t.UserGuid == guid {
+			r.delete(t.TenantID, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) DeleteByTenantUser(_ context.Context, tenantID uint, guid string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for id, t := range r.m.tokens {
+		if t.UserGuid == guid {
+			r.delete(tenantID, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) DeleteByTenant(_ context.Context, tenantID uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for id := range r.m.tokens {
+		r.delete(tenantID, id)
+	}
+	return nil
+}
+
+func (r *memoryTokenRepository) Deny(_ context.Context, tenantID uint, guid, sig string, until time.Time) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	r.m.denied[deniedKey(tenantID, guid, sig)] = until
+	return nil
+}
+
+func (r *memoryTokenRepository) IsDenied(_ context.Context, tenantID uint, guid, sig string) (bool, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+
+	until, ok := r.m.denied[deniedKey(tenantID, guid, sig)]
+	return ok && until.After(time.Now()), nil
+}
+
+func (r *memoryTokenRepository) PurgeSessions(_ context.Context, before time.Time, limit int) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var purged int64
+	for id, t := range r.m.tokens {
+		if purged == int64(limit) {
+			break
+		}
+		if t.ExpiresAt.Before(before) {
+			delete(r.m.tokens, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *memoryTokenRepository) PurgeDenied(_ context.Context, before time.Time, limit int) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var purged int64
+	for key, until := range r.m.denied {
+		if purged == int64(limit) {
+			break
+		}
+		if until.Before(before) {
+			delete(r.m.denied, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *memoryTokenRepository) FindByUserGUID(_ context.Context, tenantID uint, guid string) (*models.Token, error) {
+	tokens := r.userTokens(tenantID, guid, func(a, b models.Token) int { return cmp.Compare(a.ID, b.ID) })
+	if len(tokens) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &tokens[0], nil
+}
+
+func (r *memoryTokenRepository) FindBySelector(_ context.Context, tenantID uint, selector string) (*models.Token, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+
+	for _, t := range r.m.tokens {
+		if t.TenantID == tenantID && t.Selector == selector {
+			t.Scopes = slices.Clone(t.Scopes)
+			return &t, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryTokenRepository) GetByUserGUID(_ context.Context, tenantID uint, guid string) ([]models.Token, error) {
+	return r.userTokens(tenantID, guid, func(a, b models.Token) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	}), nil
+}
+
+func (r *memoryTokenRepository) List(_ context.Context, tenantID uint, q Query) (*Page[models.Token], error) {
+	return listMemory(r.tenantTokens(tenantID), q, tokenListSpec, func(*models.Token) bool { return false })
+}
+
+func (r *memoryTokenRepository) userTokens(tenantID uint, guid string, order func(a, b models.Token) int) []models.Token {
+	tokens := slices.DeleteFunc(r.tenantTokens(tenantID), func(t models.Token) bool {
+		return t.UserGuid != guid
+	})
+	slices.SortFunc(tokens, order)
+	return tokens
+}
+
+// tenantTokens - копии всех сессий арендатора, включая удалённые.
+func (r *memoryTokenRepository) tenantTokens(tenantID uint) []models.Token {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+
+	var tokens []models.Token
+	for _, t := range r.m.tokens {
+		if t.TenantID == tenantID {
+			t.Scopes = slices.Clone(t.Scopes)
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+func deniedKey(tenantID uint, guid, sig string) string {
+	return fmt.Sprintf("%d:%s:%s", tenantID, guid, sig)
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"gorm.io/gorm"
+	"time"
+)
+
+type memoryUserRepository struct {
+	m *Memory
+}
+
+// NewMemoryUserRepository - UserRepository поверх Memory.
+func NewMemoryUserRepository(m *Memory) UserRepository {
+	return &memoryUserRepository{m: m}
+}
+
+func (r *memoryUserRepository) Create(tenantID uint, u *models.User) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[u.Guid]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	now := time.Now()
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = now
+	}
+	if u.UpdatedAt.IsZero() {
+		u.UpdatedAt = now
+	}
+	if u.PermissionVersion == 0 {
+		u.PermissionVersion = 1
+	}
+	r.m.users[u.Guid] = *u
+	if r.m.members[tenantID] == nil {
+		r.m.members[tenantID] = map[string]bool{}
+	}
+	r.m.members[tenantID][u.Guid] = true
+	return nil
+}
+
+func (r *memoryUserRepository) IsExist(tenantID uint, guid string) bool {
+	_, err := r.FindByGUID(tenantID, guid)
+	return err == nil
+}
+
+func (r *memoryUserRepository) FindByGUID(tenantID uint, guid string) (*models.User, error) {
+	r.m.mu.RLock()
+	defer r.m.mu.RUnlock()
+
+	u, ok := r.m.users[guid]
+	if !ok || u.DeletedAt.Valid || !r.m.members[tenantID][guid] {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &u, nil
+}
+
+func (r *memoryUserRepository) List(tenantID uint, q Query) (*Page[models.User], error) {
+	r.m.mu.RLock()
+	users := make([]models.User, 0, len(r.m.members[tenantID]))
+	for guid := range r.m.members[tenantID] {
+		if u, ok := r.m.users[guid]; ok {
+			users = append(users, u)
+		}
+	}
+	r.m.mu.RUnlock()
+
+	return listMemory(users, q, userListSpec, func(u *models.User) bool { return u.DeletedAt.Valid })
+}
+
+func (r *memoryUserRepository) SetDisabled(guid string, disabled bool) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	u, ok := r.m.users[guid]
+	if !ok || u.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	u.Disabled = disabled
+	u.UpdatedAt = time.Now()
+	r.m.users[guid] = u
+	if disabled {
+		r.m.revokeSessions(guid)
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) Delete(guid string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	u, ok := r.m.users[guid]
+	if !ok || u.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	r.m.revokeSessions(guid)
+	u = r.m.users[guid]
+	u.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.m.users[guid] = u
+	return nil
+}
+
+func (r *memoryUserRepository) RevokeSessions(guid string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	u, ok := r.m.users[guid]
+	if !ok || u.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+	r.m.revokeSessions(guid)
+	return nil
+}
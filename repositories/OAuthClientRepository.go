@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type OAuthClientRepository interface {
+	Create(c *models.OAuthClient) error
+	Update(c *models.OAuthClient) error
+	DeleteByClientID(clientID string) error
+	FindByClientID(clientID string) (*models.OAuthClient, error)
+	GetClients() ([]models.OAuthClient, error)
+	// UseAssertion запоминает jti client_assertion клиента до expiresAt. false означает, что
+	// assertion с этим jti уже предъявлялся.
+	UseAssertion(clientID, jti string, expiresAt time.Time) (bool, error)
+	// PurgeAssertions удаляет до limit записей об assertion, истёкших до before.
+	PurgeAssertions(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+type oauthClientRepository struct {
+	db *gorm.DB
+}
+
+func NewOAuthClientRepository(db *gorm.DB) OAuthClientRepository {
+	return &oauthClientRepository{db: db}
+}
+
+func (r *oauthClientRepository) Create(client *models.OAuthClient) error {
+	return r.db.Create(client).Error
+}
+
+func (r *oauthClientRepository) Update(client *models.OAuthClient) error {
+	return r.db.Save(client).Error
+}
+
+func (r *oauthClientRepository) DeleteByClientID(clientID string) error {
+	return r.db.Where("client_id = ?", clientID).Delete(&models.OAuthClient{}).Error
+}
+
+func (r *oauthClientRepository) FindByClientID(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := r.db.Where("client_id = ?", clientID).First(&client).Error
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *oauthClientRepository) GetClients() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := r.db.Order("id").Find(&clients).Error
+	return clients, err
+}
+
+func (r *oauthClientRepository) UseAssertion(clientID, jti string, expiresAt time.Time) (bool, error) {
+	used := models.UsedClientAssertion{ClientID: clientID, Jti: jti, ExpiresAt: expiresAt}
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&used)
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *oauthClientRepository) PurgeAssertions(ctx context.Context, before time.Time, limit int) (int64, error) {
+	batch := r.db.Model(&models.UsedClientAssertion{}).Select("client_id", "jti").
+		Where("expires_at < ?", before).Limit(limit)
+	res := r.db.WithContext(ctx).Where("(client_id, jti) IN (?)", batch).Delete(&models.UsedClientAssertion{})
+	return res.RowsAffected, res.Error
+}
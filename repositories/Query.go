@@ -0,0 +1,307 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+var (
+	ErrInvalidQuery  = errors.New("invalid query")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Операторы фильтров Query.
+const (
+	OpEq       = "eq"
+	OpNe       = "ne"
+	OpLt       = "lt"
+	OpLte      = "lte"
+	OpGt       = "gt"
+	OpGte      = "gte"
+	OpIn       = "in"
+	OpContains = "contains"
+)
+
+// Query - спецификация выборки списка: фильтры (объединяются через AND), текстовый поиск
+// по полям, которые определяет репозиторий, сортировка, размер страницы и курсор.
+// Страницы выбираются по ключу (keyset), а не смещением, поэтому их стоимость
+// не зависит от номера страницы.
+type Query struct {
+	Filters []Filter
+	Search  string
+	Sort    []Sort
+	Limit   int
+	// Cursor - непрозрачное значение Page.NextCursor предыдущей страницы с той же сортировкой.
+	Cursor string
+	// OnlyDeleted выбирает только мягко удалённые записи.
+	OnlyDeleted bool
+}
+
+type Filter struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
+// ParseSort разбирает сортировку вида "-created_at,name" (минус - по убыванию).
+func ParseSort(s string) []Sort {
+	var sorts []Sort
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		field, desc := strings.CutPrefix(part, "-")
+		sorts = append(sorts, Sort{Field: field, Desc: desc})
+	}
+	return sorts
+}
+
+// field - поле сущности T, доступное для фильтров и сортировки: колонка и чтение значения
+// для курсора. decode восстанавливает значение из курсора с исходным типом.
+type field[T any] struct {
+	column string
+	value  func(*T) interface{}
+	decode func(json.RawMessage) (interface{}, error)
+}
+
+func column[T any, V any](name string, get func(*T) V) field[T] {
+	return field[T]{
+		column: name,
+		value:  func(t *T) interface{} { return get(t) },
+		decode: func(raw json.RawMessage) (interface{}, error) {
+			var v V
+			err := json.Unmarshal(raw, &v)
+			return v, err
+		},
+	}
+}
+
+// listSpec описывает, как репозиторий выполняет Query для сущности T.
+type listSpec[T any] struct {
+	fields map[string]field[T]
+	// key - уникальное поле, которое добавляется в конец сортировки для однозначного порядка.
+	key string
+	// search - поля текстового поиска Query.Search.
+	search []string
+	// defaultSort применяется, если Query.Sort пуст.
+	defaultSort []Sort
+	// deletedAt - колонка мягкого удаления для Query.OnlyDeleted.
+	deletedAt string
+}
+
+type cursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// resolve проверяет сортировку Query и дополняет её ключом spec.key.
+func (spec listSpec[T]) resolve(q Query) (int, []Sort, []field[T], error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	sorts := q.Sort
+	if len(sorts) == 0 {
+		sorts = spec.defaultSort
+	}
+	if !containsField(sorts, spec.key) {
+		sorts = append(sorts[:len(sorts):len(sorts)], Sort{Field: spec.key})
+	}
+	fields := make([]field[T], len(sorts))
+	for i, s := range sorts {
+		f, ok := spec.fields[s.Field]
+		if !ok {
+			return 0, nil, nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidQuery, s.Field)
+		}
+		fields[i] = f
+	}
+	if q.OnlyDeleted && spec.deletedAt == "" {
+		return 0, nil, nil, fmt.Errorf("%w: soft delete is not supported", ErrInvalidQuery)
+	}
+	return limit, sorts, fields, nil
+}
+
+// list выполняет Query поверх базового запроса db и возвращает страницу.
+func list[T any](db *gorm.DB, q Query, spec listSpec[T]) (*Page[T], error) {
+	limit, sorts, fields, err := spec.resolve(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.OnlyDeleted {
+		db = db.Unscoped().Where(spec.deletedAt + " IS NOT NULL")
+	}
+	for _, f := range q.Filters {
+		if db, err = applyFilter(db, f, spec); err != nil {
+			return nil, err
+		}
+	}
+	if q.Search != "" {
+		like := "%" + likeEscaper.Replace(strings.ToLower(q.Search)) + "%"
+		conditions := make([]string, len(spec.search))
+		args := make([]interface{}, len(spec.search))
+		for i, name := range spec.search {
+			conditions[i] = "LOWER(" + spec.fields[name].column + `) LIKE ? ESCAPE '\'`
+			args[i] = like
+		}
+		db = db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	signature := sortSignature(sorts)
+	if q.Cursor != "" {
+		values, err := decodeCursor(q.Cursor, signature, fields)
+		if err != nil {
+			return nil, err
+		}
+		condition, args := keyset(sorts, fields, values)
+		db = db.Where(condition, args...)
+	}
+	for i, s := range sorts {
+		order := fields[i].column
+		if s.Desc {
+			order += " DESC"
+		}
+		db = db.Order(order)
+	}
+
+	var items []T
+	if err := db.Limit(limit + 1).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	page := &Page[T]{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		next, err := encodeCursor(signature, fields, &page.Items[limit-1])
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+	return page, nil
+}
+
+func applyFilter[T any](db *gorm.DB, f Filter, spec listSpec[T]) (*gorm.DB, error) {
+	fd, ok := spec.fields[f.Field]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown filter field %q", ErrInvalidQuery, f.Field)
+	}
+	c := fd.column
+	switch f.Op {
+	case OpEq:
+		return db.Where(c+" = ?", f.Value), nil
+	case OpNe:
+		return db.Where(c+" <> ?", f.Value), nil
+	case OpLt:
+		return db.Where(c+" < ?", f.Value), nil
+	case OpLte:
+		return db.Where(c+" <= ?", f.Value), nil
+	case OpGt:
+		return db.Where(c+" > ?", f.Value), nil
+	case OpGte:
+		return db.Where(c+" >= ?", f.Value), nil
+	case OpIn:
+		return db.Where(c+" IN ?", f.Value), nil
+	case OpContains:
+		s, _ := f.Value.(string)
+		return db.Where("LOWER("+c+`) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(s))+"%"), nil
+	}
+	return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidQuery, f.Op)
+}
+
+// keyset строит условие "строка после курсора" для сортировки по нескольким полям:
+// (a > x) OR (a = x AND b > y) OR ... с учётом направления каждого поля.
+func keyset[T any](sorts []Sort, fields []field[T], values []interface{}) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for i, s := range sorts {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fields[j].column+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if s.Desc {
+			op = " < ?"
+		}
+		parts = append(parts, fields[i].column+op)
+		args = append(args, values[i])
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+func encodeCursor[T any](signature string, fields []field[T], last *T) (string, error) {
+	c := cursor{Sort: signature, Values: make([]json.RawMessage, len(fields))}
+	for i, f := range fields {
+		raw, err := json.Marshal(f.value(last))
+		if err != nil {
+			return "", err
+		}
+		c.Values[i] = raw
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor[T any](s, signature string, fields []field[T]) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != signature || len(c.Values) != len(fields) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]interface{}, len(fields))
+	for i, f := range fields {
+		if values[i], err = f.decode(c.Values[i]); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+func sortSignature(sorts []Sort) string {
+	parts := make([]string, len(sorts))
+	for i, s := range sorts {
+		parts[i] = s.Field
+		if s.Desc {
+			parts[i] = "-" + s.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func containsField(sorts []Sort, name string) bool {
+	for _, s := range sorts {
+		if s.Field == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,510 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ключи хранилища сессий (prefix - общий префикс без фигурных скобок, например "auth:"):
+//
+//	session:{<tenant>}:<id>        hash {user, selector, data}, истекает в Token.ExpiresAt
+//	selector:{<tenant>}:<selector> ID сессии по selector refresh токена, истекает вместе с ней
+//	sessions:{<tenant>}:<user>     zset ID сессий пользователя в арендаторе (score - ID)
+//	sessions:{<tenant>}            zset "<id>:<user>" - сессии арендатора (score - ID)
+//	user-tenants:<user>            set арендаторов, в которых у пользователя были сессии
+//	session-seq                    счётчик ID
+//	denied:{<tenant>}:<user>:<sig> отозванный access токен, истекает вместе с ним
+//
+// Фигурные скобки - хэш-тег Redis Cluster: все ключи сессий арендатора лежат в одном слоте,
+// и скрипты получают их через KEYS. Ключи, которые скрипт не может вычислить сам (selector
+// и пользователь сессии), читаются до его запуска: у сессии с данным ID они не меняются.
+// Индексы не знают об истечении сессий: записи истёкших сессий удаляются при чтении,
+// а сами индексы истекают вместе с самой поздней сессией.
+const redisLuaHelpers = `
+local function unindex(userIndex, tenantIndex, id, user)
+	redis.call('ZREM', userIndex, id)
+	redis.call('ZREM', tenantIndex, id .. ':' .. user)
+end
+
+local function remove(session, selector, userIndex, tenantIndex, id, user)
+	if redis.call('DEL', session) == 0 then
+		return 0
+	end
+	redis.call('DEL', selector)
+	unindex(userIndex, tenantIndex, id, user)
+	return 1
+end
+
+local function extend(key, expires)
+	local now = redis.call('TIME')
+	local left = expires - (tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000))
+	local ttl = redis.call('PTTL', key)
+	if ttl == -1 or ttl < left then
+		redis.call('PEXPIREAT', key, expires)
+	end
+end
+
+local function add(session, selector, userIndex, tenantIndex, id, user, selectorValue, data, expires)
+	redis.call('HSET', session, 'user', user, 'selector', selectorValue, 'data', data)
+	redis.call('PEXPIREAT', session, expires)
+	if selectorValue ~= '' then
+		redis.call('SET', selector, id)
+		redis.call('PEXPIREAT', selector, expires)
+	end
+	redis.call('ZADD', userIndex, id, id)
+	redis.call('ZADD', tenantIndex, id, id .. ':' .. user)
+	extend(userIndex, expires)
+	extend(tenantIndex, expires)
+end
+`
+
+var (
+	// KEYS: user-tenants; ARGV: tenant, expires (мс Unix)
+	redisTrackScript = redis.NewScript(redisLuaHelpers + `
+redis.call('SADD', KEYS[1], ARGV[1])
+extend(KEYS[1], tonumber(ARGV[2]))
+return 1
+`)
+	// KEYS: session, selector, user index, tenant index; ARGV: id, user, selector, data, expires
+	redisCreateScript = redis.NewScript(redisLuaHelpers + `
+add(KEYS[1], KEYS[2], KEYS[3], KEYS[4], ARGV[1], ARGV[2], ARGV[3], ARGV[4], tonumber(ARGV[5]))
+return 1
+`)
+	// KEYS: old session, old selector, old user index, session, selector, user index, tenant index
+	// ARGV: old id, old user, id, user, selector, data, expires
+	redisRotateScript = redis.NewScript(redisLuaHelpers + `
+if remove(KEYS[1], KEYS[2], KEYS[3], KEYS[7], ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+add(KEYS[4], KEYS[5], KEYS[6], KEYS[7], ARGV[3], ARGV[4], ARGV[5], ARGV[6], tonumber(ARGV[7]))
+return 1
+`)
+	// KEYS: session, selector, user index, tenant index; ARGV: id, user
+	redisDeleteScript = redis.NewScript(redisLuaHelpers + `
+return remove(KEYS[1], KEYS[2], KEYS[3], KEYS[4], ARGV[1], ARGV[2])
+`)
+	// KEYS: session, user index, tenant index; ARGV: id, user
+	redisPruneScript = redis.NewScript(redisLuaHelpers + `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	unindex(KEYS[2], KEYS[3], ARGV[1], ARGV[2])
+end
+return 1
+`)
+	// KEYS: user index, tenant index, затем пары session, selector; ARGV: user, ID сессий.
+	// Возвращает число сессий, оставшихся в индексе (созданных после чтения ID).
+	redisDeleteUserScript = redis.NewScript(redisLuaHelpers + `
+for i = 2, #ARGV do
+	local session, selector = KEYS[2 * i - 1], KEYS[2 * i]
+	if remove(session, selector, KEYS[1], KEYS[2], ARGV[i], ARGV[1]) == 0 then
+		unindex(KEYS[1], KEYS[2], ARGV[i], ARGV[1])
+	end
+end
+return redis.call('ZCARD', KEYS[1])
+`)
+)
+
+type redisTokenRepository struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisTokenRepository - TokenRepository поверх Redis (или совместимого сервера, в том числе
+// Redis Cluster): сессии истекают средствами Redis в Token.ExpiresAt, изменения индексов
+// атомарны (Lua скрипты). Удалённые сессии не хранятся, поэтому List не поддерживает
+// Query.OnlyDeleted и выбирает страницы по индексу ID: сортировка только по created_at или id.
+func NewRedisTokenRepository(client redis.UniversalClient, prefix string) TokenRepository {
+	return &redisTokenRepository{client: client, prefix: prefix}
+}
+
+func (r *redisTokenRepository) Create(ctx context.Context, t *models.Token) error {
+	if err := r.prepare(ctx, t); err != nil {
+		return err
+	}
+	keys := []string{
+		r.sessionKey(t.TenantID, t.ID), r.selectorKey(t.TenantID, t.Selector),
+		r.userIndex(t.TenantID, t.UserGuid), r.tenantIndex(t.TenantID),
+	}
+	return redisCreateScript.Run(ctx, r.client, keys, r.sessionArgs(t)...).Err()
+}
+
+func (r *redisTokenRepository) Rotate(ctx context.Context, tenantID uint, oldID uint, t *models.Token) error {
+	old, err := r.client.HMGet(ctx, r.sessionKey(tenantID, oldID), "user", "selector").Result()
+	if err != nil {
+		return err
+	}
+	oldUser, _ := old[0].(string)
+	oldSelector, _ := old[1].(string)
+	if oldUser == "" {
+		return gorm.ErrRecordNotFound
+	}
+	if err := r.prepare(ctx, t); err != nil {
+		return err
+	}
+	keys := []string{
+		r.sessionKey(tenantID, oldID), r.selectorKey(tenantID, oldSelector), r.userIndex(tenantID, oldUser),
+		r.sessionKey(tenantID, t.ID), r.selectorKey(tenantID, t.Selector), r.userIndex(tenantID, t.UserGuid),
+		r.tenantIndex(tenantID),
+	}
+	args := append([]interface{}{oldID, oldUser}, r.sessionArgs(t)...)
+	rotated, err := redisRotateScript.Run(ctx, r.client, keys, args...).Int()
+	if err != nil {
+		return err
+	}
+	if rotated == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// prepare выдаёт сессии ID и отмечает арендатора сессии у пользователя для DeleteByUserGUID.
+func (r *redisTokenRepository) prepare(ctx context.Context, t *models.Token) error {
+	id, err := r.client.Incr(ctx, r.prefix+"session-seq").Uint64()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	t.ID = uint(id)
+	t.CreatedAt, t.UpdatedAt = now, now
+	keys := []string{r.userTenants(t.UserGuid)}
+	return redisTrackScript.Run(ctx, r.client, keys, t.TenantID, t.ExpiresAt.UnixMilli()).Err()
+}
+
+// sessionArgs - аргументы add: id, user, selector, data, expires.
+func (r *redisTokenRepository) sessionArgs(t *models.Token) []interface{} {
+	data, _ := json.Marshal(t)
+	return []interface{}{t.ID, t.UserGuid, t.Selector, data, t.ExpiresAt.UnixMilli()}
+}
+
+func (r *redisTokenRepository) DeleteByID(ctx context.Context, tenantID uint, id uint) error {
+	fields, err := r.client.HMGet(ctx, r.sessionKey(tenantID, id), "user", "selector").Result()
+	if err != nil {
+		return err
+	}
+	user, _ := fields[0].(string)
+	selector, _ := fields[1].(string)
+	if user == "" {
+		return nil
+	}
+	keys := []string{
+		r.sessionKey(tenantID, id), r.selectorKey(tenantID, selector),
+		r.userIndex(tenantID, user), r.tenantIndex(tenantID),
+	}
+	return redisDeleteScript.Run(ctx, r.client, keys, id, user).Err()
+}
+
+// DeleteByUserGUID удаляет сессии пользователя по арендаторам: в каждом атомарно, пока индекс
+// пользователя не опустеет (сессии, созданные во время удаления, тоже удаляются).
+func (r *redisTokenRepository) DeleteByUserGUID(ctx context.Context, guid string) error {
+	tenants, err := r.client.SMembers(ctx, r.userTenants(guid)).Result()
+	if err != nil {
+		return err
+	}
+	for _, tenant := range tenants {
+		tenantID, err := strconv.ParseUint(tenant, 10, 0)
+		if err != nil {
+			continue
+		}
+		if err := r.deleteUserSessions(ctx, uint(tenantID), guid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *redisTokenRepository) DeleteByTenantUser(ctx context.Context, tenantID uint, guid string) error {
+	return r.deleteUserSessions(ctx, tenantID, guid)
+}
+
+// DeleteByTenant удаляет сессии арендатора порциями по индексу арендатора, пока он не опустеет.
+// Каждая порция удаляется по пользователям: индексы пользователя и арендатора меняются атомарно.
+func (r *redisTokenRepository) DeleteByTenant(ctx context.Context, tenantID uint) error {
+	for {
+		members, err := r.client.ZRange(ctx, r.tenantIndex(tenantID), 0, redisBatch-1).Result()
+		if err != nil || len(members) == 0 {
+			return err
+		}
+		var users []string
+		ids := map[string][]string{}
+		for _, member := range members {
+			id, user, _ := strings.Cut(member, ":")
+			if _, ok := ids[user]; !ok {
+				users = append(users, user)
+			}
+			ids[user] = append(ids[user], id)
+		}
+		for _, user := range users {
+			if _, err := r.deleteSessions(ctx, tenantID, user, ids[user]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *redisTokenRepository) deleteUserSessions(ctx context.Context, tenantID uint, guid string) error {
+	for {
+		ids, err := r.client.ZRange(ctx, r.userIndex(tenantID, guid), 0, redisBatch-1).Result()
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		left, err := r.deleteSessions(ctx, tenantID, guid, ids)
+		if err != nil || left == 0 {
+			return err
+		}
+	}
+}
+
+// deleteSessions удаляет сессии ids пользователя guid и их записи в индексах, даже если сами
+// сессии уже истекли. Возвращает число сессий, оставшихся в индексе пользователя.
+func (r *redisTokenRepository) deleteSessions(ctx context.Context, tenantID uint, guid string, ids []string) (int, error) {
+	pipe := r.client.Pipeline()
+	selectors := make([]*redis.StringCmd, len(ids))
+	for i, id := range ids {
+		selectors[i] = pipe.HGet(ctx, r.sessionKey(tenantID, id), "selector")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	keys := []string{r.userIndex(tenantID, guid), r.tenantIndex(tenantID)}
+	args := []interface{}{guid}
+	for i, id := range ids {
+		keys = append(keys, r.sessionKey(tenantID, id), r.selectorKey(tenantID, selectors[i].Val()))
+		args = append(args, id)
+	}
+	return redisDeleteUserScript.Run(ctx, r.client, keys, args...).Int()
+}
+
+func (r *redisTokenRepository) FindByUserGUID(ctx context.Context, tenantID uint, guid string) (*models.Token, error) {
+	tokens, err := r.load(ctx, tenantID, guid)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &tokens[0], nil
+}
+
+func (r *redisTokenRepository) FindBySelector(ctx context.Context, tenantID uint, selector string) (*models.Token, error) {
+	id, err := r.client.Get(ctx, r.selectorKey(tenantID, selector)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.client.HGet(ctx, r.sessionKey(tenantID, id), "data").Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t models.Token
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *redisTokenRepository) GetByUserGUID(ctx context.Context, tenantID uint, guid string) ([]models.Token, error) {
+	tokens, err := r.load(ctx, tenantID, guid)
+	slices.SortFunc(tokens, func(a, b models.Token) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return tokens, err
+}
+
+// List выбирает страницу сессий по индексу арендатора (или пользователя для фильтра
+// user_guid eq) порциями ZRANGEBYSCORE ... LIMIT от ID курсора: остальные фильтры применяются
+// к прочитанным порциям, поэтому стоимость страницы не зависит от числа сессий в арендаторе.
+// ID выдаются по возрастанию при создании, поэтому порядок created_at совпадает с порядком ID.
+func (r *redisTokenRepository) List(ctx context.Context, tenantID uint, q Query) (*Page[models.Token], error) {
+	limit, sorts, fields, err := tokenListSpec.resolve(q)
+	if err != nil {
+		return nil, err
+	}
+	// порядок задаёт первое поле: при совпадении created_at сессии идут в том же направлении по ID
+	desc := sorts[0].Desc
+	for _, s := range sorts {
+		if s.Field != "id" && s.Field != "created_at" {
+			return nil, fmt.Errorf("%w: sessions in redis are sorted only by created_at or id", ErrInvalidQuery)
+		}
+	}
+
+	index, guid := r.tenantIndex(tenantID), ""
+	var matchers []func(*models.Token) bool
+	for _, f := range q.Filters {
+		if value, ok := f.Value.(string); ok && f.Field == "user_guid" && f.Op == OpEq && guid == "" {
+			index, guid = r.userIndex(tenantID, value), value
+			continue
+		}
+		m, err := memoryFilter(f, tokenListSpec)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+
+	signature := sortSignature(sorts)
+	bound := ""
+	if q.Cursor != "" {
+		values, err := decodeCursor(q.Cursor, signature, fields)
+		if err != nil {
+			return nil, err
+		}
+		id, _ := values[slices.IndexFunc(sorts, func(s Sort) bool { return s.Field == "id" })].(uint)
+		bound = fmt.Sprintf("(%d", id)
+	}
+
+	page := &Page[models.Token]{}
+	for len(page.Items) <= limit {
+		by := &redis.ZRangeBy{Min: cmp.Or(bound, "-inf"), Max: "+inf", Count: int64(limit + 1)}
+		var members []string
+		if desc {
+			by.Min, by.Max = "-inf", cmp.Or(bound, "+inf")
+			members, err = r.client.ZRevRangeByScore(ctx, index, by).Result()
+		} else {
+			members, err = r.client.ZRangeByScore(ctx, index, by).Result()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(members) == 0 {
+			break
+		}
+		tokens, err := r.fetch(ctx, tenantID, guid, members)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tokens {
+			if !slices.ContainsFunc(matchers, func(m func(*models.Token) bool) bool { return !m(&t) }) {
+				page.Items = append(page.Items, t)
+			}
+		}
+		last, _, _ := strings.Cut(members[len(members)-1], ":")
+		bound = "(" + last
+		if len(members) <= limit {
+			break
+		}
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		next, err := encodeCursor(signature, fields, &page.Items[limit-1])
+		if err != nil {
+			return nil, err
+		}
+		page.NextCursor = next
+	}
+	return page, nil
+}
+
+func (r *redisTokenRepository) Deny(ctx context.Context, tenantID uint, guid, sig string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	return r.client.Set(ctx, r.deniedKey(tenantID, guid, sig), 1, ttl).Err()
+}
+
+func (r *redisTokenRepository) IsDenied(ctx context.Context, tenantID uint, guid, sig string) (bool, error) {
+	n, err := r.client.Exists(ctx, r.deniedKey(tenantID, guid, sig)).Result()
+	return n > 0, err
+}
+
+// PurgeSessions ничего не делает: сессии истекают средствами Redis, удалённые не хранятся.
+func (r *redisTokenRepository) PurgeSessions(context.Context, time.Time, int) (int64, error) {
+	return 0, nil
+}
+
+// PurgeDenied ничего не делает: записи об отозванных токенах истекают средствами Redis.
+func (r *redisTokenRepository) PurgeDenied(context.Context, time.Time, int) (int64, error) {
+	return 0, nil
+}
+
+// load читает все сессии пользователя guid в арендаторе по возрастанию ID.
+func (r *redisTokenRepository) load(ctx context.Context, tenantID uint, guid string) ([]models.Token, error) {
+	members, err := r.client.ZRange(ctx, r.userIndex(tenantID, guid), 0, -1).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	return r.fetch(ctx, tenantID, guid, members)
+}
+
+// fetch читает сессии по записям индекса: ID в индексе пользователя guid или "<id>:<user>"
+// в индексе арендатора (пустой guid). Записи истёкших сессий удаляются из индексов.
+func (r *redisTokenRepository) fetch(ctx context.Context, tenantID uint, guid string, members []string) ([]models.Token, error) {
+	pipe := r.client.Pipeline()
+	ids := make([]string, len(members))
+	users := make([]string, len(members))
+	values := make([]*redis.StringCmd, len(members))
+	for i, member := range members {
+		ids[i], users[i] = member, guid
+		if guid == "" {
+			ids[i], users[i], _ = strings.Cut(member, ":")
+		}
+		values[i] = pipe.HGet(ctx, r.sessionKey(tenantID, ids[i]), "data")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	tokens := make([]models.Token, 0, len(members))
+	for i, value := range values {
+		data, err := value.Bytes()
+		if errors.Is(err, redis.Nil) {
+			// сессия истекла - убираем её из индексов
+			keys := []string{r.sessionKey(tenantID, ids[i]), r.userIndex(tenantID, users[i]), r.tenantIndex(tenantID)}
+			if err := redisPruneScript.Run(ctx, r.client, keys, ids[i], users[i]).Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var t models.Token
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// redisBatch - сколько сессий пользователя удаляет один вызов скрипта.
+const redisBatch = 100
+
+func (r *redisTokenRepository) sessionKey(tenantID uint, id interface{}) string {
+	return fmt.Sprintf("%ssession:{%d}:%v", r.prefix, tenantID, id)
+}
+
+func (r *redisTokenRepository) selectorKey(tenantID uint, selector string) string {
+	return fmt.Sprintf("%sselector:{%d}:%s", r.prefix, tenantID, selector)
+}
+
+func (r *redisTokenRepository) userIndex(tenantID uint, guid string) string {
+	return fmt.Sprintf("%ssessions:{%d}:%s", r.prefix, tenantID, guid)
+}
+
+func (r *redisTokenRepository) tenantIndex(tenantID uint) string {
+	return fmt.Sprintf("%ssessions:{%d}", r.prefix, tenantID)
+}
+
+func (r *redisTokenRepository) userTenants(guid string) string {
+	return r.prefix + "user-tenants:" + guid
+}
+
+func (r *redisTokenRepository) deniedKey(tenantID uint, guid, sig string) string {
+	return fmt.Sprintf("%sdenied:{%d}:%s:%s", r.prefix, tenantID, guid, sig)
+}
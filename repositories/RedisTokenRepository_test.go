@@ -0,0 +1,81 @@
+package repositories_test
+
+import (
+	"auth-service/models"
+	"auth-service/repositories"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRedisKeysShareTenantSlot проверяет, что ключи сессий арендатора несут его хэш-тег
+// и в Redis Cluster попадают в один слот со скриптами, которые их изменяют.
+func TestRedisKeysShareTenantSlot(t *testing.T) {
+	server := miniredis.RunT(t)
+	tokens := repositories.NewRedisTokenRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), "auth:")
+	guid := newUser("ann").Guid
+
+	first := newToken(7, guid, "web", time.Hour)
+	if err := tokens.Create(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.Rotate(ctx, 7, first.ID, newToken(7, guid, "web", time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.Deny(ctx, 7, guid, "sig", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range server.Keys() {
+		switch key {
+		case "auth:session-seq", "auth:user-tenants:" + guid:
+			continue
+		}
+		if !strings.Contains(key, ":{7}") {
+			t.Errorf("key %q has no tenant hash tag", key)
+		}
+	}
+
+	if err := tokens.DeleteByUserGUID(ctx, guid); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range server.Keys() {
+		if strings.HasPrefix(key, "auth:session") && key != "auth:session-seq" {
+			t.Errorf("key %q left after DeleteByUserGUID", key)
+		}
+	}
+}
+
+func TestRedisDeleteByTenantRemovesKeys(t *testing.T) {
+	server := miniredis.RunT(t)
+	tokens := repositories.NewRedisTokenRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), "auth:")
+	ann, bob := newUser("ann").Guid, newUser("bob").Guid
+	for _, token := range []*models.Token{
+		newToken(7, ann, "web", time.Hour),
+		newToken(7, bob, "", time.Hour),
+		newToken(8, ann, "", time.Hour),
+	} {
+		if err := tokens.Create(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tokens.DeleteByTenant(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	left := 0
+	for _, key := range server.Keys() {
+		if strings.Contains(key, ":{7}") {
+			t.Errorf("key %q left after DeleteByTenant", key)
+		}
+		if strings.Contains(key, ":{8}") {
+			left++
+		}
+	}
+	// сессия, selector и оба индекса другого арендатора
+	if left != 4 {
+		t.Errorf("%d keys of another tenant left, want 4", left)
+	}
+}
@@ -0,0 +1,194 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"gorm.io/gorm"
+)
+
+type RoleRepository interface {
+	CreatePermission(p *models.Permission) error
+	DeletePermission(name string) error
+	FindPermissionsByNames(names []string) ([]models.Permission, error)
+	GetPermissions() ([]models.Permission, error)
+
+	CreateRole(r *models.Role) error
+	UpdateRole(r *models.Role) error
+	DeleteRole(name string) error
+	FindRoleByName(name string) (*models.Role, error)
+	FindRolesByNames(names []string) ([]models.Role, error)
+	GetRoles() ([]models.Role, error)
+
+	GetUserRoles(tenantID uint, guid string) ([]models.Role, error)
+	SetUserRoles(tenantID uint, guid string, roles []models.Role) error
+	GetPermissionVersion(tenantID uint, guid string) (int64, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) CreatePermission(p *models.Permission) error {
+	return r.db.Create(p).Error
+}
+
+// DeletePermission удаляет разрешение и инвалидирует токены пользователей, чьи роли его содержали.
+func (r *roleRepository) DeletePermission(name string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var p models.Permission
+		if err := tx.Where("name = ?", name).First(&p).Error; err != nil {
+			return err
+		}
+		roles := tx.Table("role_permissions").Select("role_id").Where("permission_id = ?", p.ID)
+		if err := bumpMembers(tx, tx.Model(&models.UserRole{}).Select("tenant_id, user_guid").Where("role_id IN (?)", roles)); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM role_permissions WHERE permission_id = ?", p.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&p).Error
+	})
+}
+
+func (r *roleRepository) FindPermissionsByNames(names []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Where("name IN ?", names).Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *roleRepository) GetPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+func (r *roleRepository) CreateRole(role *models.Role) error {
+	return r.db.Create(role).Error
+}
+
+// UpdateRole сохраняет роль вместе с новым набором разрешений и увеличивает
+// версию разрешений пользователей с этой ролью в арендаторах, где она назначена.
+func (r *roleRepository) UpdateRole(role *models.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Permissions").Save(role).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(role).Association("Permissions").Replace(role.Permissions); err != nil {
+			return err
+		}
+		return bumpMembers(tx, tx.Model(&models.UserRole{}).Select("tenant_id, user_guid").Where("role_id = ?", role.ID))
+	})
+}
+
+func (r *roleRepository) DeleteRole(name string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.Where("name = ?", name).First(&role).Error; err != nil {
+			return err
+		}
+		if err := bumpMembers(tx, tx.Model(&models.UserRole{}).Select("tenant_id, user_guid").Where("role_id = ?", role.ID)); err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+}
+
+func (r *roleRepository) FindRoleByName(name string) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) FindRolesByNames(names []string) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Where("name IN ?", names).Order("name").Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) GetRoles() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) GetUserRoles(tenantID uint, guid string) ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Preload("Permissions").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.tenant_id = ? AND user_roles.user_guid = ?", tenantID, guid).
+		Order("roles.name").
+		Find(&roles).Error
+	return roles, err
+}
+
+// SetUserRoles заменяет роли участника арендатора и увеличивает версию разрешений его членства.
+// Каталог ролей общий, назначения - свои в каждом арендаторе.
+func (r *roleRepository) SetUserRoles(tenantID uint, guid string, roles []models.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&models.TenantMember{}).
+			Joins("JOIN users ON users.guid = tenant_members.user_guid AND users.deleted_at IS NULL").
+			Where("tenant_members.tenant_id = ? AND tenant_members.user_guid = ?", tenantID, guid).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("tenant_id = ? AND user_guid = ?", tenantID, guid).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if err := tx.Omit("Role").Create(&models.UserRole{TenantID: tenantID, UserGuid: guid, RoleID: role.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return bumpMembers(tx, [][]interface{}{{tenantID, guid}})
+	})
+}
+
+// GetPermissionVersion возвращает версию разрешений пользователя в арендаторе: сумму версий
+// учётной записи и членства. Без членства возвращается gorm.ErrRecordNotFound.
+func (r *roleRepository) GetPermissionVersion(tenantID uint, guid string) (int64, error) {
+	var versions []int64
+	err := r.db.Model(&models.TenantMember{}).
+		Joins("JOIN users ON users.guid = tenant_members.user_guid AND users.deleted_at IS NULL").
+		Where("tenant_members.tenant_id = ? AND tenant_members.user_guid = ?", tenantID, guid).
+		Pluck("users.permission_version + tenant_members.permission_version", &versions).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return versions[0], nil
+}
+
+// bumpUsers увеличивает версию разрешений учётных записей во всех арендаторах;
+// guids - список или подзапрос.
+func bumpUsers(tx *gorm.DB, guids interface{}) error {
+	return tx.Model(&models.User{}).
+		Where("guid IN (?)", guids).
+		UpdateColumn("permission_version", gorm.Expr("permission_version + 1")).Error
+}
+
+// bumpMembers увеличивает версию разрешений членств; members - список пар (tenant_id, user_guid)
+// или подзапрос.
+func bumpMembers(tx *gorm.DB, members interface{}) error {
+	return tx.Model(&models.TenantMember{}).
+		Where("(tenant_id, user_guid) IN (?)", members).
+		UpdateColumn("permission_version", gorm.Expr("permission_version + 1")).Error
+}
@@ -0,0 +1,249 @@
+package repositories_test
+
+// Общий набор проверок соответствия реализаций UserRepository и TokenRepository: база данных,
+// память и Redis должны вести себя одинаково. Память, SQLite и miniredis проверяются всегда;
+// PostgreSQL и внешний Redis - если заданы AUTH_TEST_POSTGRES_DSN и AUTH_TEST_REDIS_ADDR:
+//
+//	AUTH_TEST_POSTGRES_DSN="host=localhost user=postgres dbname=auth_test sslmode=disable" \
+//	AUTH_TEST_REDIS_ADDR=localhost:6379 go test ./repositories/...
+
+import (
+	"auth-service/models"
+	"auth-service/repositories"
+	"auth-service/testdb"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"os"
+	"testing"
+	"time"
+)
+
+// Backend - проверяемое хранилище. Tenants - два арендатора без пользователей и сессий.
+// SeparateSessions - сессии хранятся отдельно от пользователей, и Users не завершает их
+// при отключении пользователя: это делает вызывающий код через Tokens.DeleteByUserGUID.
+type Backend struct {
+	Users            repositories.UserRepository
+	Tokens           repositories.TokenRepository
+	Tenants          [2]uint
+	SeparateSessions bool
+	// IDOrderOnly - List сортирует сессии только по created_at и id, другие сортировки - ErrInvalidQuery.
+	IDOrderOnly bool
+}
+
+// ctx - контекст вызовов TokenRepository в проверках.
+var ctx = context.Background()
+
+// Factory готовит Backend для одной проверки; cleanup освобождает его данные.
+type Factory func() (b Backend, cleanup func(), err error)
+
+type check struct {
+	name string
+	run  func(b Backend) error
+}
+
+var checks = []check{
+	{"users/create", usersCreate},
+	{"users/list", usersList},
+	{"users/query-errors", usersQueryErrors},
+	{"users/lifecycle", usersLifecycle},
+	{"users/concurrent", usersConcurrent},
+	{"tokens/create-find", tokensCreateFind},
+	{"tokens/delete", tokensDelete},
+	{"tokens/selector", tokensSelector},
+	{"tokens/list", tokensList},
+	{"tokens/rotate", tokensRotate},
+	{"tokens/delete-user", tokensDeleteUser},
+	{"tokens/delete-tenant-user", tokensDeleteTenantUser},
+	{"tokens/delete-tenant", tokensDeleteTenant},
+	{"tokens/denylist", tokensDenylist},
+	{"tokens/purge", tokensPurge},
+	{"tokens/time-zones", tokensTimeZones},
+}
+
+func TestMemoryStorage(t *testing.T) {
+	runSuite(t, memoryStorage())
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	runSuite(t, databaseStorage(testdb.SQLite(t)))
+}
+
+func TestPostgresStorage(t *testing.T) {
+	dsn := os.Getenv("AUTH_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("AUTH_TEST_POSTGRES_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.MigrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	runSuite(t, databaseStorage(db))
+}
+
+// TestMiniredisStorage проверяет хранилище сессий на совместимом сервере внутри процесса.
+func TestMiniredisStorage(t *testing.T) {
+	runSuite(t, redisStorage(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})))
+}
+
+func TestRedisStorage(t *testing.T) {
+	addr := os.Getenv("AUTH_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("AUTH_TEST_REDIS_ADDR is not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { _ = client.Close() })
+	runSuite(t, redisStorage(client))
+}
+
+// runSuite выполняет все проверки, каждую на отдельном Backend.
+func runSuite(t *testing.T, newBackend Factory) {
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			b, cleanup, err := newBackend()
+			if err != nil {
+				t.Fatalf("backend: %v", err)
+			}
+			defer cleanup()
+			if err := c.run(b); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// memoryStorage - Factory хранилища в памяти.
+func memoryStorage() Factory {
+	return func() (Backend, func(), error) {
+		m := repositories.NewMemory()
+		b := Backend{
+			Users:   repositories.NewMemoryUserRepository(m),
+			Tokens:  repositories.NewMemoryTokenRepository(m),
+			Tenants: [2]uint{1, 2},
+		}
+		return b, func() {}, nil
+	}
+}
+
+// databaseStorage - Factory хранилища в базе данных db со схемой models.Migrate. Для каждой проверки
+// создаются два временных арендатора; после проверки удаляются они, их пользователи, сессии
+// и отозванные токены.
+func databaseStorage(db *gorm.DB) Factory {
+	return func() (Backend, func(), error) {
+		var tenants [2]uint
+		var ids []uint
+		cleanup := func() {
+			db.Where("tenant_id IN ?", ids).Delete(&models.Token{})
+			db.Where("tenant_id IN ?", ids).Delete(&models.DeniedToken{})
+			db.Unscoped().Where("guid IN (?)", db.Model(&models.TenantMember{}).Select("user_guid").Where("tenant_id IN ?", ids)).
+				Delete(&models.User{})
+			db.Where("tenant_id IN ?", ids).Delete(&models.TenantMember{})
+			db.Where("id IN ?", ids).Delete(&models.Tenant{})
+		}
+		for i := range tenants {
+			t := models.Tenant{Slug: "storage-test-" + uuid.NewString()[:8]}
+			if err := db.Create(&t).Error; err != nil {
+				cleanup()
+				return Backend{}, nil, err
+			}
+			tenants[i] = t.ID
+			ids = append(ids, t.ID)
+		}
+		b := Backend{
+			Users:   repositories.NewUserRepository(db),
+			Tokens:  repositories.NewTokenRepository(db),
+			Tenants: tenants,
+		}
+		return b, cleanup, nil
+	}
+}
+
+// redisStorage - Factory хранилища сессий в Redis client (пользователи - в памяти). Каждая проверка
+// работает под своим префиксом ключей, которые удаляются после неё.
+func redisStorage(client redis.UniversalClient) Factory {
+	return func() (Backend, func(), error) {
+		if err := client.Ping(ctx).Err(); err != nil {
+			return Backend{}, nil, err
+		}
+		prefix := "storage-test:" + uuid.NewString()[:8] + ":"
+		cleanup := func() {
+			keys := client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+			for keys.Next(ctx) {
+				client.Del(ctx, keys.Val())
+			}
+		}
+		b := Backend{
+			Users:            repositories.NewMemoryUserRepository(repositories.NewMemory()),
+			Tokens:           repositories.NewRedisTokenRepository(client, prefix),
+			Tenants:          [2]uint{1, 2},
+			SeparateSessions: true,
+			IDOrderOnly:      true,
+		}
+		return b, cleanup, nil
+	}
+}
+
+func failf(format string, args ...interface{}) error {
+	return fmt.Errorf(format, args...)
+}
+
+func expectNotFound(op string, err error) error {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return failf("%s: want gorm.ErrRecordNotFound, got %v", op, err)
+	}
+	return nil
+}
+
+func newUser(name string) *models.User {
+	return &models.User{Guid: uuid.NewString(), Name: name, Email: name + "@example.com"}
+}
+
+// createUser создаёт пользователя в арендаторе и возвращает его GUID: сессия может ссылаться
+// только на существующего пользователя.
+func createUser(b Backend, tenantID uint, name string) (string, error) {
+	u := newUser(name)
+	if err := b.Users.Create(tenantID, u); err != nil {
+		return "", failf("create user: %v", err)
+	}
+	return u.Guid, nil
+}
+
+func newToken(tenantID uint, guid, clientID string, expiresIn time.Duration) *models.Token {
+	return &models.Token{
+		TenantID:     tenantID,
+		UserGuid:     guid,
+		ClientID:     clientID,
+		Scopes:       []string{"openid", "profile"},
+		UserAgent:    "storage-test",
+		IpAddress:    "127.0.0.1",
+		Selector:     uuid.NewString(),
+		RefreshToken: uuid.NewString(),
+		ExpiresAt:    time.Now().Add(expiresIn),
+	}
+}
+
+// walk обходит все страницы list и возвращает элементы в порядке выдачи.
+func walk[T any](list func(cursor string) (*repositories.Page[T], error)) ([]T, error) {
+	var items []T
+	cursor := ""
+	for pages := 0; pages < 1000; pages++ {
+		page, err := list(cursor)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if page.NextCursor == "" {
+			return items, nil
+		}
+		cursor = page.NextCursor
+	}
+	return nil, failf("pagination does not terminate")
+}
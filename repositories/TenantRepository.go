@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+)
+
+type TenantRepository interface {
+	Create(t *models.Tenant) error
+	Update(t *models.Tenant) error
+	DeleteBySlug(slug string) error
+	FindByID(id uint) (*models.Tenant, error)
+	FindBySlug(slug string) (*models.Tenant, error)
+	FindByDomain(host string) (*models.Tenant, error)
+	GetTenants() ([]models.Tenant, error)
+
+	AddMember(tenantID uint, guid, role string) error
+	RemoveMember(tenantID uint, guid string) error
+	FindMember(tenantID uint, guid string) (*models.TenantMember, error)
+	GetMembers(tenantID uint) ([]models.Member, error)
+	SetMemberRole(tenantID uint, guid, role string) error
+	CountMembersWithRole(tenantID uint, role string) (int64, error)
+}
+
+type tenantRepository struct {
+	db *gorm.DB
+}
+
+func NewTenantRepository(db *gorm.DB) TenantRepository {
+	return &tenantRepository{db: db}
+}
+
+func (r *tenantRepository) Create(t *models.Tenant) error {
+	return r.db.Create(t).Error
+}
+
+func (r *tenantRepository) Update(t *models.Tenant) error {
+	return r.db.Save(t).Error
+}
+
+// DeleteBySlug удаляет арендатора вместе с членством, приглашениями и refresh сессиями его пользователей.
+func (r *tenantRepository) DeleteBySlug(slug string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var t models.Tenant
+		if err := tx.Where("slug = ?", slug).First(&t).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tenant_id = ?", t.ID).Delete(&models.TenantMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tenant_id = ?", t.ID).Delete(&models.Invitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tenant_id = ?", t.ID).Delete(&models.Token{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&t).Error
+	})
+}
+
+func (r *tenantRepository) FindByID(id uint) (*models.Tenant, error) {
+	var t models.Tenant
+	if err := r.db.First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *tenantRepository) FindBySlug(slug string) (*models.Tenant, error) {
+	var t models.Tenant
+	if err := r.db.Where("slug = ?", slug).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// FindByDomain ищет арендатора по имени хоста. Domains хранится в JSON, поэтому
+// кандидаты отбираются по подстроке и проверяются точным сравнением.
+func (r *tenantRepository) FindByDomain(host string) (*models.Tenant, error) {
+	var candidates []models.Tenant
+	err := r.db.Where("domains LIKE ?", "%"+strconv.Quote(host)+"%").Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		if candidates[i].HasDomain(host) {
+			return &candidates[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *tenantRepository) GetTenants() ([]models.Tenant, error) {
+	var tenants []models.Tenant
+	err := r.db.Order("id").Find(&tenants).Error
+	return tenants, err
+}
+
+// AddMember добавляет пользователя в арендатора или меняет роль уже состоящего в нём.
+func (r *tenantRepository) AddMember(tenantID uint, guid, role string) error {
+	var count int64
+	if err := r.db.Model(&models.User{}).Where("guid = ?", guid).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "user_guid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role"}),
+	}).
+		Omit("Tenant").
+		Create(&models.TenantMember{TenantID: tenantID, UserGuid: guid, Role: role}).Error
+}
+
+// RemoveMember исключает пользователя из арендатора и завершает его сессии в нём. Роли в
+// арендаторе удаляются вместе с членством; версия учётной записи увеличивается, чтобы после
+// повторного вступления (версия членства снова 0) старые access токены не стали действительными.
+func (r *tenantRepository) RemoveMember(tenantID uint, guid string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("tenant_id = ? AND user_guid = ?", tenantID, guid).Delete(&models.TenantMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := bumpUsers(tx, []string{guid}); err != nil {
+			return err
+		}
+		return tx.Where("tenant_id = ? AND user_guid = ?", tenantID, guid).Delete(&models.Token{}).Error
+	})
+}
+
+func (r *tenantRepository) FindMember(tenantID uint, guid string) (*models.TenantMember, error) {
+	var m models.TenantMember
+	if err := r.db.Where("tenant_id = ? AND user_guid = ?", tenantID, guid).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *tenantRepository) GetMembers(tenantID uint) ([]models.Member, error) {
+	var members []models.Member
+	err := r.db.Model(&models.TenantMember{}).
+		Select("users.guid, users.name, users.email, tenant_members.role, tenant_members.created_at AS joined_at").
+		Joins("JOIN users ON users.guid = tenant_members.user_guid").
+		Where("tenant_members.tenant_id = ? AND users.deleted_at IS NULL", tenantID).
+		Order("tenant_members.created_at").
+		Scan(&members).Error
+	return members, err
+}
+
+func (r *tenantRepository) SetMemberRole(tenantID uint, guid, role string) error {
+	result := r.db.Model(&models.TenantMember{}).
+		Where("tenant_id = ? AND user_guid = ?", tenantID, guid).
+		Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *tenantRepository) CountMembersWithRole(tenantID uint, role string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TenantMember{}).Where("tenant_id = ? AND role = ?", tenantID, role).Count(&count).Error
+	return count, err
+}
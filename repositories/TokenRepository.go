@@ -1,35 +1,158 @@
 package repositories
 
 import (
-	"auth-service/connections"
 	"auth-service/models"
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
+// TokenRepository - refresh сессии; все запросы ограничены арендатором tenantID.
 type TokenRepository interface {
-	Create(t *models.Token) error
-	DeleteByID(id uint) error
-	FindByUserGUID(guid string) (*models.Token, error)
+	Create(ctx context.Context, t *models.Token) error
+	DeleteByID(ctx context.Context, tenantID uint, id uint) error
+	FindByUserGUID(ctx context.Context, tenantID uint, guid string) (*models.Token, error)
+	// FindBySelector находит сессию по selector её refresh токена.
+	FindBySelector(ctx context.Context, tenantID uint, selector string) (*models.Token, error)
+	GetByUserGUID(ctx context.Context, tenantID uint, guid string) ([]models.Token, error)
+	List(ctx context.Context, tenantID uint, q Query) (*Page[models.Token], error)
+	// Rotate атомарно заменяет сессию oldID новой сессией t. Если oldID уже удалена
+	// (например, параллельным обновлением), возвращает gorm.ErrRecordNotFound и t не создаёт.
+	Rotate(ctx context.Context, tenantID uint, oldID uint, t *models.Token) error
+	// DeleteByUserGUID удаляет сессии пользователя во всех арендаторах.
+	DeleteByUserGUID(ctx context.Context, guid string) error
+	// DeleteByTenantUser удаляет сессии пользователя в арендаторе tenantID.
+	DeleteByTenantUser(ctx context.Context, tenantID uint, guid string) error
+	// DeleteByTenant удаляет все сессии арендатора.
+	DeleteByTenant(ctx context.Context, tenantID uint) error
+	// Deny добавляет access токен сессии с подписью sig в список отозванных до момента until.
+	Deny(ctx context.Context, tenantID uint, guid, sig string, until time.Time) error
+	IsDenied(ctx context.Context, tenantID uint, guid, sig string) (bool, error)
+	// PurgeSessions удаляет до limit сессий, истёкших до before, во всех арендаторах.
+	PurgeSessions(ctx context.Context, before time.Time, limit int) (int64, error)
+	// PurgeDenied удаляет до limit записей об отозванных токенах, истёкших до before.
+	PurgeDenied(ctx context.Context, before time.Time, limit int) (int64, error)
 }
 
-type tokenRepository struct{}
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+	return &tokenRepository{db: db}
+}
 
-func NewTokenRepository() TokenRepository {
-	return &tokenRepository{}
+func (r *tokenRepository) Create(ctx context.Context, token *models.Token) error {
+	return r.db.WithContext(ctx).Create(token).Error
 }
 
-func (r *tokenRepository) Create(token *models.Token) error {
-	return connections.DB.Create(token).Error
+func (r *tokenRepository) DeleteByID(ctx context.Context, tenantID uint, id uint) error {
+	return r.db.WithContext(ctx).Where("tenant_id = ?", tenantID).Delete(&models.Token{}, id).Error
 }
 
-func (r *tokenRepository) DeleteByID(id uint) error {
-	return connections.DB.Delete(&models.Token{}, id).Error
+func (r *tokenRepository) FindByUserGUID(ctx context.Context, tenantID uint, guid string) (*models.Token, error) {
+	var token models.Token
+	err := r.db.WithContext(ctx).Where("tenant_id = ? AND user_guid = ?", tenantID, guid).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
 }
 
-func (r *tokenRepository) FindByUserGUID(guid string) (*models.Token, error) {
+func (r *tokenRepository) FindBySelector(ctx context.Context, tenantID uint, selector string) (*models.Token, error) {
 	var token models.Token
-	err := connections.DB.Where("user_guid = ?", guid).First(&token).Error
+	err := r.db.WithContext(ctx).Where("tenant_id = ? AND selector = ?", tenantID, selector).First(&token).Error
 	if err != nil {
 		return nil, err
 	}
 	return &token, nil
 }
+
+func (r *tokenRepository) GetByUserGUID(ctx context.Context, tenantID uint, guid string) ([]models.Token, error) {
+	var tokens []models.Token
+	err := r.db.WithContext(ctx).Where("tenant_id = ? AND user_guid = ?", tenantID, guid).Order("created_at").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *tokenRepository) Rotate(ctx context.Context, tenantID uint, oldID uint, t *models.Token) error {
+	return r.RotateWithDeliveries(ctx, tenantID, oldID, t, nil)
+}
+
+func (r *tokenRepository) RotateWithDeliveries(ctx context.Context, tenantID uint, oldID uint, t *models.Token, deliveries []models.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		deleted := tx.Where("tenant_id = ?", tenantID).Delete(&models.Token{}, oldID)
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		if deleted.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+		return tx.Create(&deliveries).Error
+	})
+}
+
+func (r *tokenRepository) DeleteByUserGUID(ctx context.Context, guid string) error {
+	return r.db.WithContext(ctx).Where("user_guid = ?", guid).Delete(&models.Token{}).Error
+}
+
+func (r *tokenRepository) DeleteByTenantUser(ctx context.Context, tenantID uint, guid string) error {
+	return r.db.WithContext(ctx).Where("tenant_id = ? AND user_guid = ?", tenantID, guid).Delete(&models.Token{}).Error
+}
+
+func (r *tokenRepository) DeleteByTenant(ctx context.Context, tenantID uint) error {
+	return r.db.WithContext(ctx).Where("tenant_id = ?", tenantID).Delete(&models.Token{}).Error
+}
+
+func (r *tokenRepository) Deny(ctx context.Context, tenantID uint, guid, sig string, until time.Time) error {
+	denied := models.DeniedToken{TenantID: tenantID, UserGuid: guid, Sig: sig, ExpiresAt: until}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "user_guid"}, {Name: "sig"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(&denied).Error
+}
+
+func (r *tokenRepository) IsDenied(ctx context.Context, tenantID uint, guid, sig string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.DeniedToken{}).
+		Where("tenant_id = ? AND user_guid = ? AND sig = ? AND expires_at > ?", tenantID, guid, sig, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// List - страница refresh сессий арендатора по Query.
+func (r *tokenRepository) List(ctx context.Context, tenantID uint, q Query) (*Page[models.Token], error) {
+	return list(r.db.WithContext(ctx).Where("tenant_id = ?", tenantID), q, tokenListSpec)
+}
+
+var tokenListSpec = listSpec[models.Token]{
+	fields: map[string]field[models.Token]{
+		"id":         column("id", func(t *models.Token) uint { return t.ID }),
+		"user_guid":  column("user_guid", func(t *models.Token) string { return t.UserGuid }),
+		"client_id":  column("client_id", func(t *models.Token) string { return t.ClientID }),
+		"created_at": column("created_at", func(t *models.Token) time.Time { return t.CreatedAt }),
+		"expires_at": column("expires_at", func(t *models.Token) time.Time { return t.ExpiresAt }),
+	},
+	key:         "id",
+	defaultSort: []Sort{{Field: "created_at"}},
+}
+
+func (r *tokenRepository) PurgeSessions(ctx context.Context, before time.Time, limit int) (int64, error) {
+	batch := r.db.WithContext(ctx).Model(&models.Token{}).Select("id").
+		Where("expires_at < ?", before).Limit(limit)
+	res := r.db.WithContext(ctx).Where("id IN (?)", batch).Delete(&models.Token{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *tokenRepository) PurgeDenied(ctx context.Context, before time.Time, limit int) (int64, error) {
+	batch := r.db.WithContext(ctx).Model(&models.DeniedToken{}).Select("tenant_id", "user_guid", "sig").
+		Where("expires_at < ?", before).Limit(limit)
+	res := r.db.WithContext(ctx).Where("(tenant_id, user_guid, sig) IN (?)", batch).Delete(&models.DeniedToken{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,573 @@
+package repositories_test
+
+import (
+	"auth-service/models"
+	"auth-service/repositories"
+	"auth-service/testdb"
+	"errors"
+	"gorm.io/gorm"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tokensCreateFind(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	guid, err := createUser(b, a, "carol")
+	if err != nil {
+		return err
+	}
+	first := newToken(a, guid, "web", time.Hour)
+	second := newToken(a, guid, "", time.Hour)
+	elsewhere := newToken(other, guid, "", time.Hour)
+	for _, t := range []*models.Token{first, second, elsewhere} {
+		if err := b.Tokens.Create(ctx, t); err != nil {
+			return failf("create: %v", err)
+		}
+	}
+	if first.ID == 0 || first.ID == second.ID || first.CreatedAt.IsZero() {
+		return failf("create: want distinct ids and created_at, got %d and %d", first.ID, second.ID)
+	}
+
+	found, err := b.Tokens.FindByUserGUID(ctx, a, guid)
+	if err != nil || found.ID != first.ID {
+		return failf("find: want the first session %d, got %+v, %v", first.ID, found, err)
+	}
+	if found.RefreshToken != first.RefreshToken || found.ClientID != "web" || !slices.Equal(found.Scopes, first.Scopes) {
+		return failf("find: fields do not round-trip: %+v", found)
+	}
+	if found, err = b.Tokens.FindByUserGUID(ctx, other, guid); err != nil || found.ID != elsewhere.ID {
+		return failf("find in other tenant: want %d, got %v", elsewhere.ID, err)
+	}
+	_, err = b.Tokens.FindByUserGUID(ctx, a, newUser("missing").Guid)
+	if err := expectNotFound("find missing", err); err != nil {
+		return err
+	}
+
+	tokens, err := b.Tokens.GetByUserGUID(ctx, a, guid)
+	if err != nil || len(tokens) != 2 || tokens[0].ID != first.ID || tokens[1].ID != second.ID {
+		return failf("get by user: want sessions %d and %d, got %d, %v", first.ID, second.ID, len(tokens), err)
+	}
+	return nil
+}
+
+func tokensDelete(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	guid, err := createUser(b, a, "dave")
+	if err != nil {
+		return err
+	}
+	first := newToken(a, guid, "", time.Hour)
+	second := newToken(a, guid, "", time.Hour)
+	for _, t := range []*models.Token{first, second} {
+		if err := b.Tokens.Create(ctx, t); err != nil {
+			return failf("create: %v", err)
+		}
+	}
+
+	if err := b.Tokens.DeleteByID(ctx, other, first.ID); err != nil {
+		return failf("delete in other tenant: %v", err)
+	}
+	if found, err := b.Tokens.FindByUserGUID(ctx, a, guid); err != nil || found.ID != first.ID {
+		return failf("delete in other tenant must not delete session %d", first.ID)
+	}
+	if err := b.Tokens.DeleteByID(ctx, a, first.ID); err != nil {
+		return failf("delete: %v", err)
+	}
+	if found, err := b.Tokens.FindByUserGUID(ctx, a, guid); err != nil || found.ID != second.ID {
+		return failf("delete: want remaining session %d, got %v", second.ID, err)
+	}
+	if tokens, err := b.Tokens.GetByUserGUID(ctx, a, guid); err != nil || len(tokens) != 1 {
+		return failf("delete: want 1 session, got %d, %v", len(tokens), err)
+	}
+	return nil
+}
+
+func tokensSelector(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	guid, err := createUser(b, a, "olga")
+	if err != nil {
+		return err
+	}
+	first := newToken(a, guid, "web", time.Hour)
+	second := newToken(a, guid, "", time.Hour)
+	for _, t := range []*models.Token{first, second} {
+		if err := b.Tokens.Create(ctx, t); err != nil {
+			return failf("create: %v", err)
+		}
+	}
+
+	found, err := b.Tokens.FindBySelector(ctx, a, second.Selector)
+	if err != nil || found.ID != second.ID || found.RefreshToken != second.RefreshToken || found.UserGuid != guid {
+		return failf("find: want session %d, got %+v, %v", second.ID, found, err)
+	}
+	_, err = b.Tokens.FindBySelector(ctx, other, second.Selector)
+	if err := expectNotFound("find in other tenant", err); err != nil {
+		return err
+	}
+	_, err = b.Tokens.FindBySelector(ctx, a, "missing")
+	if err := expectNotFound("find missing", err); err != nil {
+		return err
+	}
+
+	next := newToken(a, guid, "web", time.Hour)
+	if err := b.Tokens.Rotate(ctx, a, first.ID, next); err != nil {
+		return failf("rotate: %v", err)
+	}
+	_, err = b.Tokens.FindBySelector(ctx, a, first.Selector)
+	if err := expectNotFound("find rotated", err); err != nil {
+		return err
+	}
+	if found, err := b.Tokens.FindBySelector(ctx, a, next.Selector); err != nil || found.ID != next.ID {
+		return failf("find after rotate: want session %d, got %v", next.ID, err)
+	}
+	if err := b.Tokens.DeleteByID(ctx, a, second.ID); err != nil {
+		return failf("delete: %v", err)
+	}
+	_, err = b.Tokens.FindBySelector(ctx, a, second.Selector)
+	return expectNotFound("find deleted", err)
+}
+
+func tokensList(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	ann, err := createUser(b, a, "ann")
+	if err != nil {
+		return err
+	}
+	bob, err := createUser(b, a, "bob")
+	if err != nil {
+		return err
+	}
+	var ids []uint
+	for i := 0; i < 7; i++ {
+		guid, client, expires := ann, "web", time.Hour
+		if i%2 == 1 {
+			guid, client = bob, "cli"
+		}
+		if i < 2 {
+			expires = 30 * time.Minute
+		}
+		t := newToken(a, guid, client, expires)
+		if err := b.Tokens.Create(ctx, t); err != nil {
+			return failf("create: %v", err)
+		}
+		ids = append(ids, t.ID)
+	}
+	if err := b.Tokens.Create(ctx, newToken(other, ann, "web", time.Hour)); err != nil {
+		return failf("create: %v", err)
+	}
+
+	all, err := walkTokens(b, a, repositories.Query{Limit: 3, Sort: repositories.ParseSort("-id")})
+	if err != nil {
+		return err
+	}
+	got := make([]uint, len(all))
+	for i := range all {
+		got[i] = all[i].ID
+	}
+	want := slices.Clone(ids)
+	slices.Reverse(want)
+	if !slices.Equal(got, want) {
+		return failf("sort -id: want %v, got %v", want, got)
+	}
+
+	// хранилища могут удалять истёкшие сессии сами, поэтому "истекающие" сессии - те,
+	// что истекают раньше bound
+	bound := time.Now().Add(45 * time.Minute)
+	counts := []struct {
+		name  string
+		query repositories.Query
+		want  int
+	}{
+		{"filter user", repositories.Query{Filters: []repositories.Filter{{Field: "user_guid", Op: repositories.OpEq, Value: bob}}}, 3},
+		{"filter user and client", repositories.Query{Limit: 1, Sort: repositories.ParseSort("-created_at"), Filters: []repositories.Filter{
+			{Field: "user_guid", Op: repositories.OpEq, Value: ann},
+			{Field: "client_id", Op: repositories.OpEq, Value: "web"},
+		}}, 4},
+		{"filter client", repositories.Query{Filters: []repositories.Filter{{Field: "client_id", Op: repositories.OpEq, Value: "web"}}}, 4},
+		{"filter expires after", repositories.Query{Filters: []repositories.Filter{{Field: "expires_at", Op: repositories.OpGt, Value: bound}}}, 5},
+		{"filter expires before", repositories.Query{Limit: 1, Filters: []repositories.Filter{{Field: "expires_at", Op: repositories.OpLte, Value: bound}}}, 2},
+		{"sort expires_at", repositories.Query{Limit: 2, Sort: repositories.ParseSort("expires_at,-created_at")}, 7},
+	}
+	for _, c := range counts {
+		if b.IDOrderOnly && len(c.query.Sort) > 0 && c.query.Sort[0].Field != "created_at" {
+			if _, err := b.Tokens.List(ctx, a, c.query); !errors.Is(err, repositories.ErrInvalidQuery) {
+				return failf("%s: want ErrInvalidQuery, got %v", c.name, err)
+			}
+			continue
+		}
+		tokens, err := walkTokens(b, a, c.query)
+		if err != nil {
+			return failf("%s: %v", c.name, err)
+		}
+		if len(tokens) != c.want {
+			return failf("%s: want %d sessions, got %d", c.name, c.want, len(tokens))
+		}
+	}
+
+	if err := b.Tokens.DeleteByID(ctx, a, ids[6]); err != nil {
+		return failf("delete: %v", err)
+	}
+	if rest, err := walkTokens(b, a, repositories.Query{}); err != nil || len(rest) != 6 {
+		return failf("list after delete: want 6 sessions, got %d, %v", len(rest), err)
+	}
+	return nil
+}
+
+func tokensRotate(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	guid, err := createUser(b, a, "frank")
+	if err != nil {
+		return err
+	}
+	old := newToken(a, guid, "web", time.Hour)
+	if err := b.Tokens.Create(ctx, old); err != nil {
+		return failf("create: %v", err)
+	}
+
+	err = b.Tokens.Rotate(ctx, other, old.ID, newToken(other, guid, "web", time.Hour))
+	if err := expectNotFound("rotate in other tenant", err); err != nil {
+		return err
+	}
+	next := newToken(a, guid, "web", time.Hour)
+	if err := b.Tokens.Rotate(ctx, a, old.ID, next); err != nil {
+		return failf("rotate: %v", err)
+	}
+	if next.ID == 0 || next.ID == old.ID {
+		return failf("rotate: want a new session id, got %d", next.ID)
+	}
+	if tokens, err := b.Tokens.GetByUserGUID(ctx, a, guid); err != nil || len(tokens) != 1 || tokens[0].ID != next.ID {
+		return failf("rotate: want only session %d, got %d sessions, %v", next.ID, len(tokens), err)
+	}
+	err = b.Tokens.Rotate(ctx, a, old.ID, newToken(a, guid, "web", time.Hour))
+	if err := expectNotFound("rotate rotated session", err); err != nil {
+		return err
+	}
+
+	// из параллельных обновлений одной сессии успешно только одно
+	const n = 16
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- b.Tokens.Rotate(ctx, a, next.ID, newToken(a, guid, "web", time.Hour))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	rotated := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			rotated++
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return failf("concurrent rotate: %v", err)
+		}
+	}
+	if tokens, err := b.Tokens.GetByUserGUID(ctx, a, guid); rotated != 1 || err != nil || len(tokens) != 1 {
+		return failf("concurrent rotate: want 1 winner and 1 session, got %d and %d, %v", rotated, len(tokens), err)
+	}
+	return nil
+}
+
+func tokensDeleteUser(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	guid, err := createUser(b, a, "gina")
+	if err != nil {
+		return err
+	}
+	keep, err := createUser(b, a, "hank")
+	if err != nil {
+		return err
+	}
+	for _, t := range []*models.Token{
+		newToken(a, guid, "", time.Hour),
+		newToken(a, guid, "web", time.Hour),
+		newToken(other, guid, "", time.Hour),
+		newToken(a, keep, "", time.Hour),
+	} {
+		if err := b.Tokens.Create(ctx, t); err != nil {
+			return failf("create: %v", err)
+		}
+	}
+
+	if err := b.Tokens.DeleteByUserGUID(ctx, guid); err != nil {
+		return failf("delete by user: %v", err)
+	}
+	for _, tenantID := range b.Tenants {
+		_, err := b.Tokens.FindByUserGUID(ctx, tenantID, guid)
+		if err := expectNotFound("sessions after delete by user", err); err != nil {
+			return err
+		}
+	}
+	if _, err := b.Tokens.FindByUserGUID(ctx, a, keep); err != nil {
+		return failf("delete by user removed a session of another user: %v", err)
+	}
+	if rest, err := walkTokens(b, a, repositories.Query{}); err != nil || len(rest) != 1 {
+		return failf("list after delete by user: want 1 session, got %d, %v", len(rest), err)
+	}
+	if err := b.Tokens.DeleteByUserGUID(ctx, newUser("missing").Guid); err != nil {
+		return failf("delete by missing user: %v", err)
+	}
+	return nil
+}
+
+func tokensDeleteTenantUser(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	guid, err := createUser(b, a, "gwen")
+	if err != nil {
+		return err
+	}
+	keep, err := createUser(b, a, "hugo")
+	if err != nil {
+		return err
+	}
+	for _, t := range []*models.Token{
+		newToken(a, guid, "", time.Hour),
+		newToken(a, guid, "web", time.Hour),
+		newToken(other, guid, "", time.Hour),
+		newToken(a, keep, "", time.Hour),
+	} {
+		if err := b.Tokens.Create(ctx, t); err != nil {
+			return failf("create: %v", err)
+		}
+	}
+
+	if err := b.Tokens.DeleteByTenantUser(ctx, a, guid); err != nil {
+		return failf("delete by tenant user: %v", err)
+	}
+	_, err = b.Tokens.FindByUserGUID(ctx, a, guid)
+	if err := expectNotFound("sessions after delete by tenant user", err); err != nil {
+		return err
+	}
+	if _, err := b.Tokens.FindByUserGUID(ctx, other, guid); err != nil {
+		return failf("delete by tenant user removed a session in another tenant: %v", err)
+	}
+	if _, err := b.Tokens.FindByUserGUID(ctx, a, keep); err != nil {
+		return failf("delete by tenant user removed a session of another user: %v", err)
+	}
+	if rest, err := walkTokens(b, a, repositories.Query{}); err != nil || len(rest) != 1 {
+		return failf("list after delete by tenant user: want 1 session, got %d, %v", len(rest), err)
+	}
+	if err := b.Tokens.DeleteByTenantUser(ctx, a, newUser("missing").Guid); err != nil {
+		return failf("delete by tenant user, missing user: %v", err)
+	}
+	return nil
+}
+
+func tokensDeleteTenant(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	var users []string
+	for _, name := range []string{"iris", "jack"} {
+		guid, err := createUser(b, a, name)
+		if err != nil {
+			return err
+		}
+		users = append(users, guid)
+	}
+	// больше сессий, чем удаляется за одну порцию
+	for i := 0; i < 120; i++ {
+		if err := b.Tokens.Create(ctx, newToken(a, users[i%2], "", time.Hour)); err != nil {
+			return failf("create: %v", err)
+		}
+	}
+	if err := b.Tokens.Create(ctx, newToken(other, users[0], "", time.Hour)); err != nil {
+		return failf("create: %v", err)
+	}
+
+	if err := b.Tokens.DeleteByTenant(ctx, a); err != nil {
+		return failf("delete by tenant: %v", err)
+	}
+	for _, guid := range users {
+		_, err := b.Tokens.FindByUserGUID(ctx, a, guid)
+		if err := expectNotFound("sessions after delete by tenant", err); err != nil {
+			return err
+		}
+	}
+	if rest, err := walkTokens(b, a, repositories.Query{}); err != nil || len(rest) != 0 {
+		return failf("list after delete by tenant: want no sessions, got %d, %v", len(rest), err)
+	}
+	if rest, err := walkTokens(b, other, repositories.Query{}); err != nil || len(rest) != 1 {
+		return failf("delete by tenant removed sessions of another tenant: want 1, got %d, %v", len(rest), err)
+	}
+	if err := b.Tokens.DeleteByTenant(ctx, a); err != nil {
+		return failf("delete by empty tenant: %v", err)
+	}
+	return nil
+}
+
+func tokensDenylist(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	guid := newUser("ivan").Guid
+	if err := b.Tokens.Deny(ctx, a, guid, "0a1b2c3d", time.Now().Add(time.Hour)); err != nil {
+		return failf("deny: %v", err)
+	}
+	if err := b.Tokens.Deny(ctx, a, guid, "expired0", time.Now().Add(-time.Minute)); err != nil {
+		return failf("deny expired: %v", err)
+	}
+	// повторный отзыв продлевает запись
+	if err := b.Tokens.Deny(ctx, a, guid, "0a1b2c3d", time.Now().Add(2*time.Hour)); err != nil {
+		return failf("deny again: %v", err)
+	}
+
+	cases := []struct {
+		tenantID  uint
+		guid, sig string
+		want      bool
+	}{
+		{a, guid, "0a1b2c3d", true},
+		{other, guid, "0a1b2c3d", false},
+		{a, newUser("other").Guid, "0a1b2c3d", false},
+		{a, guid, "ffffffff", false},
+		{a, guid, "expired0", false},
+	}
+	for _, c := range cases {
+		denied, err := b.Tokens.IsDenied(ctx, c.tenantID, c.guid, c.sig)
+		if err != nil || denied != c.want {
+			return failf("is denied %d/%s: want %v, got %v, %v", c.tenantID, c.sig, c.want, denied, err)
+		}
+	}
+	return nil
+}
+
+// tokensPurge удаляет сессии, истёкшие задолго до запуска проверки: очистка работает во всех
+// арендаторах, и более поздний cutoff затронул бы чужие данные в общей базе.
+func tokensPurge(b Backend) error {
+	a := b.Tenants[0]
+	guid, err := createUser(b, a, "petr")
+	if err != nil {
+		return err
+	}
+	cutoff := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	live := newToken(a, guid, "", time.Hour)
+	if err := b.Tokens.Create(ctx, live); err != nil {
+		return failf("create: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		t := newToken(a, guid, "", 0)
+		t.ExpiresAt = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		if err := b.Tokens.Create(ctx, t); err != nil {
+			return failf("create expired: %v", err)
+		}
+	}
+
+	// хранилища с собственным истечением записей могут ничего не удалять
+	var purged int64
+	for {
+		n, err := b.Tokens.PurgeSessions(ctx, cutoff, 2)
+		if err != nil {
+			return failf("purge sessions: %v", err)
+		}
+		if n > 2 {
+			return failf("purge sessions: want at most 2 per batch, got %d", n)
+		}
+		purged += n
+		if n < 2 {
+			break
+		}
+	}
+	if purged > 3 {
+		return failf("purge sessions: want at most 3 purged, got %d", purged)
+	}
+	if rest, err := walkTokens(b, a, repositories.Query{}); err != nil || len(rest) != 1 || rest[0].ID != live.ID {
+		return failf("purge sessions: want only session %d, got %d sessions, %v", live.ID, len(rest), err)
+	}
+
+	if err := b.Tokens.Deny(ctx, a, guid, "0a1b2c3d", time.Now().Add(time.Hour)); err != nil {
+		return failf("deny: %v", err)
+	}
+	if n, err := b.Tokens.PurgeDenied(ctx, cutoff, 10); err != nil || n != 0 {
+		return failf("purge denied: want nothing purged, got %d, %v", n, err)
+	}
+	if denied, err := b.Tokens.IsDenied(ctx, a, guid, "0a1b2c3d"); err != nil || !denied {
+		return failf("purge denied removed an active entry: %v", err)
+	}
+	return nil
+}
+
+// tokensTimeZones проверяет, что время сравнивается как момент, а не как запись
+// в часовом поясе: хранилища, хранящие время текстом, должны приводить его к одному поясу.
+func tokensTimeZones(b Backend) error {
+	a := b.Tenants[0]
+	guid, err := createUser(b, a, "erin")
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	earlier := newToken(a, guid, "", 0)
+	earlier.ExpiresAt = now.Add(time.Hour).In(time.FixedZone("UTC+14", 14*3600))
+	later := newToken(a, guid, "", 0)
+	later.ExpiresAt = now.Add(2 * time.Hour).In(time.FixedZone("UTC-12", -12*3600))
+	for _, t := range []*models.Token{later, earlier} {
+		if err := b.Tokens.Create(ctx, t); err != nil {
+			return failf("create: %v", err)
+		}
+	}
+
+	if !b.IDOrderOnly {
+		sorted, err := walkTokens(b, a, repositories.Query{Limit: 1, Sort: repositories.ParseSort("expires_at")})
+		if err != nil {
+			return err
+		}
+		if len(sorted) != 2 || sorted[0].ID != earlier.ID || !sorted[0].ExpiresAt.Equal(earlier.ExpiresAt) {
+			return failf("sort expires_at: want session %d first", earlier.ID)
+		}
+	}
+	bound := now.Add(90 * time.Minute).In(time.FixedZone("UTC+5", 5*3600))
+	after, err := walkTokens(b, a, repositories.Query{Filters: []repositories.Filter{{Field: "expires_at", Op: repositories.OpGt, Value: bound}}})
+	if err != nil {
+		return err
+	}
+	if len(after) != 1 || after[0].ID != later.ID {
+		return failf("filter expires_at: want only session %d, got %d sessions", later.ID, len(after))
+	}
+	return nil
+}
+
+// TestDeletedSessionsAreRemoved проверяет, что завершённые сессии удаляются из базы, а не
+// остаются мягко удалёнными вместе с отозванными refresh токенами.
+func TestDeletedSessionsAreRemoved(t *testing.T) {
+	db := testdb.SQLite(t)
+	b, cleanup, err := databaseStorage(db)()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	a := b.Tenants[0]
+	guid, err := createUser(b, a, "ivan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := []*models.Token{newToken(a, guid, "", time.Hour), newToken(a, guid, "", time.Hour), newToken(a, guid, "", time.Hour)}
+	for _, token := range tokens {
+		if err := b.Tokens.Create(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Tokens.DeleteByID(ctx, a, tokens[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Tokens.Rotate(ctx, a, tokens[1].ID, newToken(a, guid, "", time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Users.Delete(guid); err != nil {
+		t.Fatal(err)
+	}
+	var rows int64
+	if err := db.Table("tokens").Where("user_guid = ?", guid).Count(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rows != 0 {
+		t.Errorf("want no session rows after logout, rotation and user deletion, got %d", rows)
+	}
+}
+
+func walkTokens(b Backend, tenantID uint, q repositories.Query) ([]models.Token, error) {
+	return walk(func(cursor string) (*repositories.Page[models.Token], error) {
+		q.Cursor = cursor
+		return b.Tokens.List(ctx, tenantID, q)
+	})
+}
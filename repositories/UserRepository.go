@@ -1,37 +1,143 @@
 package repositories
 
 import (
-	"auth-service/connections"
 	"auth-service/models"
+	"gorm.io/gorm"
+	"strings"
+	"time"
 )
 
+// UserRepository - пользователи в рамках арендатора: все запросы ограничены членством
+// в арендаторе tenantID. SetDisabled, Delete и RevokeSessions меняют учётную запись
+// целиком и завершают её сессии во всех арендаторах.
 type UserRepository interface {
-	Create(u *models.User) error
-	IsExist(guid string) bool
-	GetUsers() ([]models.User, error)
+	Create(tenantID uint, u *models.User) error
+	IsExist(tenantID uint, guid string) bool
+	FindByGUID(tenantID uint, guid string) (*models.User, error)
+	List(tenantID uint, q Query) (*Page[models.User], error)
+
+	SetDisabled(guid string, disabled bool) error
+	Delete(guid string) error
+	RevokeSessions(guid string) error
 }
 
-type userRepository struct{}
+type userRepository struct {
+	db *gorm.DB
+}
 
-func NewUserRepository() UserRepository {
-	return &userRepository{}
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
 }
 
-func (r *userRepository) Create(user *models.User) error {
-	return connections.DB.Create(user).Error
+// Create создаёт пользователя и делает его членом арендатора.
+func (r *userRepository) Create(tenantID uint, user *models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Omit("Tenant").Create(&models.TenantMember{TenantID: tenantID, UserGuid: user.Guid}).Error
+	})
 }
 
-func (r *userRepository) IsExist(guid string) bool {
+func (r *userRepository) IsExist(tenantID uint, guid string) bool {
 	var exists bool
-	connections.DB.Model(&models.User{}).
+	r.inTenant(tenantID).
 		Select("count(*) > 0").
-		Where("guid = ?", guid).
+		Where("users.guid = ?", guid).
 		Find(&exists)
 	return exists
 }
 
-func (r *userRepository) GetUsers() ([]models.User, error) {
-	var users []models.User
-	err := connections.DB.Find(&users).Error
-	return users, err
+func (r *userRepository) FindByGUID(tenantID uint, guid string) (*models.User, error) {
+	var user models.User
+	err := r.inTenant(tenantID).Where("users.guid = ?", guid).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// List - страница пользователей арендатора по Query. Поиск - подстрока GUID, имени или email.
+func (r *userRepository) List(tenantID uint, q Query) (*Page[models.User], error) {
+	return list(r.inTenant(tenantID), q, userListSpec)
+}
+
+var userListSpec = listSpec[models.User]{
+	fields: map[string]field[models.User]{
+		"guid":       column("users.guid", func(u *models.User) string { return u.Guid }),
+		"name":       column("users.name", func(u *models.User) string { return u.Name }),
+		"email":      column("users.email", func(u *models.User) string { return u.Email }),
+		"disabled":   column("users.disabled", func(u *models.User) bool { return u.Disabled }),
+		"created_at": column("users.created_at", func(u *models.User) time.Time { return u.CreatedAt }),
+		"updated_at": column("users.updated_at", func(u *models.User) time.Time { return u.UpdatedAt }),
+	},
+	key:         "guid",
+	search:      []string{"guid", "name", "email"},
+	defaultSort: []Sort{{Field: "created_at"}},
+	deletedAt:   "users.deleted_at",
+}
+
+// SetDisabled отключает или включает пользователя. Отключение завершает все его сессии
+// и делает выданные access токены устаревшими (версия разрешений увеличивается).
+func (r *userRepository) SetDisabled(guid string, disabled bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("guid = ?", guid).Update("disabled", disabled)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if !disabled {
+			return nil
+		}
+		return revokeSessions(tx, guid)
+	})
+}
+
+// Delete мягко удаляет пользователя (deleted_at) и завершает его сессии.
+func (r *userRepository) Delete(guid string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := revokeSessions(tx, guid); err != nil {
+			return err
+		}
+		result := tx.Where("guid = ?", guid).Delete(&models.User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// RevokeSessions завершает все сессии пользователя (принудительный выход).
+func (r *userRepository) RevokeSessions(guid string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("guid = ?", guid).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return revokeSessions(tx, guid)
+	})
+}
+
+// revokeSessions удаляет refresh токены пользователя во всех арендаторах и увеличивает
+// версию его разрешений, чтобы access токены отклонялись проверкой pv.
+func revokeSessions(tx *gorm.DB, guid string) error {
+	if err := tx.Where("user_guid = ?", guid).Delete(&models.Token{}).Error; err != nil {
+		return err
+	}
+	return bumpUsers(tx, []string{guid})
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (r *userRepository) inTenant(tenantID uint) *gorm.DB {
+	return r.db.Model(&models.User{}).
+		Joins("JOIN tenant_members ON tenant_members.user_guid = users.guid AND tenant_members.tenant_id = ?", tenantID)
 }
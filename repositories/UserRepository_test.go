@@ -0,0 +1,337 @@
+package repositories_test
+
+import (
+	"auth-service/models"
+	"auth-service/repositories"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+func usersCreate(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	u := newUser("ann")
+	if err := b.Users.Create(a, u); err != nil {
+		return failf("create: %v", err)
+	}
+
+	found, err := b.Users.FindByGUID(a, u.Guid)
+	if err != nil {
+		return failf("find: %v", err)
+	}
+	if found.Name != u.Name || found.Email != u.Email || found.Disabled || found.DeletedAt.Valid {
+		return failf("find: got %+v", found)
+	}
+	if found.PermissionVersion != 1 || found.CreatedAt.IsZero() {
+		return failf("find: defaults not applied: pv %d, created_at %v", found.PermissionVersion, found.CreatedAt)
+	}
+	if !b.Users.IsExist(a, u.Guid) || b.Users.IsExist(other, u.Guid) {
+		return failf("is exist: user must belong to its tenant only")
+	}
+	_, err = b.Users.FindByGUID(other, u.Guid)
+	if err := expectNotFound("find in other tenant", err); err != nil {
+		return err
+	}
+	if err := b.Users.Create(a, &models.User{Guid: u.Guid}); err == nil {
+		return failf("create duplicate guid: want error")
+	}
+	return nil
+}
+
+func usersList(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	names := []string{"alpha", "beta", "gamma"}
+	var guids []string
+	for i := 0; i < 12; i++ {
+		u := newUser(fmt.Sprintf("%s-%02d", names[i%3], i))
+		if err := b.Users.Create(a, u); err != nil {
+			return failf("create: %v", err)
+		}
+		guids = append(guids, u.Guid)
+	}
+	for _, name := range []string{"a_c", "abc"} {
+		u := newUser(name)
+		if err := b.Users.Create(a, u); err != nil {
+			return failf("create: %v", err)
+		}
+		guids = append(guids, u.Guid)
+	}
+	if err := b.Users.Create(other, newUser("alpha-other")); err != nil {
+		return failf("create: %v", err)
+	}
+
+	all, err := walkUsers(b, a, repositories.Query{Limit: 5})
+	if err != nil {
+		return err
+	}
+	if err := sameGUIDs(all, guids); err != nil {
+		return failf("default sort: %v", err)
+	}
+	if !slices.IsSortedFunc(all, func(x, y models.User) int { return x.CreatedAt.Compare(y.CreatedAt) }) {
+		return failf("default sort: not ordered by created_at")
+	}
+
+	byName, err := walkUsers(b, a, repositories.Query{Limit: 4, Sort: repositories.ParseSort("-name")})
+	if err != nil {
+		return err
+	}
+	if err := sameGUIDs(byName, guids); err != nil {
+		return failf("sort -name: %v", err)
+	}
+	if !slices.IsSortedFunc(byName, func(x, y models.User) int { return strings.Compare(y.Name, x.Name) }) {
+		return failf("sort -name: not ordered")
+	}
+
+	counts := []struct {
+		name  string
+		query repositories.Query
+		want  int
+	}{
+		{"search is case-insensitive", repositories.Query{Search: "BETA"}, 4},
+		{"search escapes wildcards", repositories.Query{Search: "_"}, 1},
+		{"search matches email", repositories.Query{Search: "gamma-02@"}, 1},
+		{"filter in", repositories.Query{Filters: []repositories.Filter{{Field: "guid", Op: repositories.OpIn, Value: guids[:3]}}}, 3},
+		{"filter contains", repositories.Query{Filters: []repositories.Filter{{Field: "name", Op: repositories.OpContains, Value: "ALPHA"}}}, 4},
+		{"filter created_at", repositories.Query{Filters: []repositories.Filter{{Field: "created_at", Op: repositories.OpLt, Value: time.Now().Add(time.Hour)}}}, 14},
+	}
+	for _, c := range counts {
+		users, err := walkUsers(b, a, c.query)
+		if err != nil {
+			return failf("%s: %v", c.name, err)
+		}
+		if len(users) != c.want {
+			return failf("%s: want %d users, got %d", c.name, c.want, len(users))
+		}
+	}
+
+	if err := b.Users.SetDisabled(guids[0], true); err != nil {
+		return failf("disable: %v", err)
+	}
+	disabled, err := walkUsers(b, a, repositories.Query{Filters: []repositories.Filter{{Field: "disabled", Op: repositories.OpEq, Value: true}}})
+	if err != nil {
+		return err
+	}
+	if len(disabled) != 1 || disabled[0].Guid != guids[0] {
+		return failf("filter disabled: got %d users", len(disabled))
+	}
+
+	if err := b.Users.Delete(guids[1]); err != nil {
+		return failf("delete: %v", err)
+	}
+	deleted, err := walkUsers(b, a, repositories.Query{OnlyDeleted: true})
+	if err != nil {
+		return err
+	}
+	if len(deleted) != 1 || deleted[0].Guid != guids[1] || !deleted[0].DeletedAt.Valid {
+		return failf("only deleted: got %d users", len(deleted))
+	}
+	if active, err := walkUsers(b, a, repositories.Query{}); err != nil || len(active) != 13 {
+		return failf("list without deleted: got %d users, %v", len(active), err)
+	}
+	return nil
+}
+
+func usersQueryErrors(b Backend) error {
+	a := b.Tenants[0]
+	for i := 0; i < 3; i++ {
+		if err := b.Users.Create(a, newUser(fmt.Sprintf("user-%d", i))); err != nil {
+			return failf("create: %v", err)
+		}
+	}
+
+	invalid := []struct {
+		name  string
+		query repositories.Query
+		want  error
+	}{
+		{"unknown sort field", repositories.Query{Sort: repositories.ParseSort("password")}, repositories.ErrInvalidQuery},
+		{"unknown filter field", repositories.Query{Filters: []repositories.Filter{{Field: "password", Op: repositories.OpEq, Value: "x"}}}, repositories.ErrInvalidQuery},
+		{"unknown operator", repositories.Query{Filters: []repositories.Filter{{Field: "name", Op: "like", Value: "x"}}}, repositories.ErrInvalidQuery},
+		{"malformed cursor", repositories.Query{Cursor: "not a cursor"}, repositories.ErrInvalidCursor},
+	}
+	for _, c := range invalid {
+		if _, err := b.Users.List(a, c.query); !errors.Is(err, c.want) {
+			return failf("%s: want %v, got %v", c.name, c.want, err)
+		}
+	}
+
+	page, err := b.Users.List(a, repositories.Query{Limit: 1, Sort: repositories.ParseSort("name")})
+	if err != nil || page.NextCursor == "" {
+		return failf("first page: want next cursor, got %v", err)
+	}
+	_, err = b.Users.List(a, repositories.Query{Limit: 1, Sort: repositories.ParseSort("-name"), Cursor: page.NextCursor})
+	if !errors.Is(err, repositories.ErrInvalidCursor) {
+		return failf("cursor with other sort: want %v, got %v", repositories.ErrInvalidCursor, err)
+	}
+
+	last, err := b.Users.List(a, repositories.Query{Limit: 3})
+	if err != nil || len(last.Items) != 3 || last.NextCursor != "" {
+		return failf("exact last page: want 3 users without next cursor, got %v", err)
+	}
+	return nil
+}
+
+func usersLifecycle(b Backend) error {
+	a, other := b.Tenants[0], b.Tenants[1]
+	u := newUser("bob")
+	if err := b.Users.Create(a, u); err != nil {
+		return failf("create: %v", err)
+	}
+	for _, tenantID := range b.Tenants {
+		if err := b.Tokens.Create(ctx, newToken(tenantID, u.Guid, "", time.Hour)); err != nil {
+			return failf("create token: %v", err)
+		}
+	}
+
+	if err := b.Users.SetDisabled(u.Guid, true); err != nil {
+		return failf("disable: %v", err)
+	}
+	if err := endSessions(b, u.Guid); err != nil {
+		return err
+	}
+	found, err := b.Users.FindByGUID(a, u.Guid)
+	if err != nil || !found.Disabled || found.PermissionVersion <= 1 {
+		return failf("disable: want disabled user with bumped pv, got %+v, %v", found, err)
+	}
+	for _, tenantID := range b.Tenants {
+		_, err := b.Tokens.FindByUserGUID(ctx, tenantID, u.Guid)
+		if err := expectNotFound("sessions after disable", err); err != nil {
+			return err
+		}
+	}
+	if err := b.Users.SetDisabled(u.Guid, false); err != nil {
+		return failf("enable: %v", err)
+	}
+	if found, err = b.Users.FindByGUID(a, u.Guid); err != nil || found.Disabled {
+		return failf("enable: user is still disabled, %v", err)
+	}
+
+	if err := b.Tokens.Create(ctx, newToken(other, u.Guid, "", time.Hour)); err != nil {
+		return failf("create token: %v", err)
+	}
+	pv := found.PermissionVersion
+	if err := b.Users.RevokeSessions(u.Guid); err != nil {
+		return failf("revoke sessions: %v", err)
+	}
+	if err := endSessions(b, u.Guid); err != nil {
+		return err
+	}
+	_, err = b.Tokens.FindByUserGUID(ctx, other, u.Guid)
+	if err := expectNotFound("sessions after revoke", err); err != nil {
+		return err
+	}
+	if found, err = b.Users.FindByGUID(a, u.Guid); err != nil || found.PermissionVersion <= pv {
+		return failf("revoke sessions: pv must grow, %v", err)
+	}
+
+	if err := b.Users.Delete(u.Guid); err != nil {
+		return failf("delete: %v", err)
+	}
+	_, err = b.Users.FindByGUID(a, u.Guid)
+	if err := expectNotFound("find deleted", err); err != nil {
+		return err
+	}
+	if b.Users.IsExist(a, u.Guid) {
+		return failf("is exist: deleted user exists")
+	}
+
+	ops := map[string]func(guid string) error{
+		"delete":          b.Users.Delete,
+		"disable":         func(guid string) error { return b.Users.SetDisabled(guid, true) },
+		"revoke sessions": b.Users.RevokeSessions,
+	}
+	for name, op := range ops {
+		for _, guid := range []string{u.Guid, newUser("missing").Guid} {
+			if err := expectNotFound(name, op(guid)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// endSessions завершает сессии пользователя в отдельном хранилище сессий, как UserService.
+func endSessions(b Backend, guid string) error {
+	if !b.SeparateSessions {
+		return nil
+	}
+	if err := b.Tokens.DeleteByUserGUID(ctx, guid); err != nil {
+		return failf("delete sessions: %v", err)
+	}
+	return nil
+}
+
+func usersConcurrent(b Backend) error {
+	a := b.Tenants[0]
+	const n = 32
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u := newUser(fmt.Sprintf("worker-%02d", i))
+			if err := b.Users.Create(a, u); err != nil {
+				errs <- err
+				return
+			}
+			if err := b.Tokens.Create(ctx, newToken(a, u.Guid, "", time.Hour)); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := b.Users.FindByGUID(a, u.Guid); err != nil {
+				errs <- err
+				return
+			}
+			if _, err := b.Users.List(a, repositories.Query{Limit: 5}); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return failf("concurrent access: %v", err)
+	}
+
+	users, err := walkUsers(b, a, repositories.Query{Limit: 10})
+	if err != nil {
+		return err
+	}
+	sessions, err := walkTokens(b, a, repositories.Query{Limit: 10})
+	if err != nil {
+		return err
+	}
+	if len(users) != n || len(sessions) != n {
+		return failf("concurrent access: want %d users and sessions, got %d and %d", n, len(users), len(sessions))
+	}
+	return nil
+}
+
+func walkUsers(b Backend, tenantID uint, q repositories.Query) ([]models.User, error) {
+	return walk(func(cursor string) (*repositories.Page[models.User], error) {
+		q.Cursor = cursor
+		return b.Users.List(tenantID, q)
+	})
+}
+
+// sameGUIDs проверяет, что users - это ровно guids без повторов.
+func sameGUIDs(users []models.User, guids []string) error {
+	seen := map[string]bool{}
+	for _, u := range users {
+		if seen[u.Guid] {
+			return fmt.Errorf("user %s listed twice", u.Guid)
+		}
+		if !slices.Contains(guids, u.Guid) {
+			return fmt.Errorf("unexpected user %s", u.Guid)
+		}
+		seen[u.Guid] = true
+	}
+	if len(seen) != len(guids) {
+		return fmt.Errorf("want %d users, got %d", len(guids), len(seen))
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"auth-service/models"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ErrLeaseConflict - доставка уже не занята этим воркером: аренда истекла и доставку заняла
+// другая реплика, или её изменили через административный API.
+var ErrLeaseConflict = errors.New("webhook delivery lease is no longer held")
+
+// WebhookRepository - outbox webhook'ов: очередь доставок, которую разбирают воркеры.
+type WebhookRepository interface {
+	Enqueue(ctx context.Context, deliveries []models.WebhookDelivery) error
+	// Claim занимает до момента until до limit ожидающих доставок, время отправки которых
+	// наступило к now. Занятые доставки не достаются другим воркерам и репликам, пока срок
+	// не истечёт или результат не будет записан через Save.
+	Claim(ctx context.Context, now, until time.Time, limit int) ([]models.WebhookDelivery, error)
+	// Save записывает результат попытки и освобождает доставку, если она всё ещё занята
+	// арендой d.LockedUntil, полученной из Claim; иначе ничего не меняет и возвращает
+	// ErrLeaseConflict.
+	Save(ctx context.Context, d *models.WebhookDelivery) error
+	Find(ctx context.Context, id uint) (*models.WebhookDelivery, error)
+	List(ctx context.Context, q Query) (*Page[models.WebhookDelivery], error)
+	// Replay возвращает доставку id в очередь с обнулённым счётчиком попыток. Ожидающую
+	// доставку не меняет и возвращает false; нет доставки - gorm.ErrRecordNotFound.
+	Replay(ctx context.Context, id uint, now time.Time) (bool, error)
+	// ReplayDead возвращает в очередь все недоставленные доставки события event (пусто - любого).
+	ReplayDead(ctx context.Context, event string, now time.Time) (int64, error)
+	// Purge удаляет до limit доставок, доставленных до before.
+	Purge(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// OutboxTokenRepository - хранилище сессий в той же базе данных, что и outbox: замена сессии
+// и постановка webhook'ов в очередь выполняются одной транзакцией.
+type OutboxTokenRepository interface {
+	// RotateWithDeliveries - Rotate, который вместе с новой сессией записывает deliveries.
+	RotateWithDeliveries(ctx context.Context, tenantID uint, oldID uint, t *models.Token, deliveries []models.WebhookDelivery) error
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) Enqueue(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&deliveries).Error
+}
+
+func (r *webhookRepository) Claim(ctx context.Context, now, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	db := r.db.WithContext(ctx)
+	due := db.Model(&models.WebhookDelivery{}).Select("id").
+		Where("status = ? AND next_attempt_at <= ?", models.WebhookPending, now).
+		Where("locked_until IS NULL OR locked_until < ?", now).
+		Order("next_attempt_at").Limit(limit)
+	if db.Dialector.Name() == "postgres" {
+		// реплики занимают разные доставки, не дожидаясь друг друга
+		due = due.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked})
+	}
+	var claimed []models.WebhookDelivery
+	err := db.Model(&claimed).Clauses(clause.Returning{}).
+		Where("id IN (?)", due).
+		Update("locked_until", until).Error
+	return claimed, err
+}
+
+func (r *webhookRepository) Save(ctx context.Context, d *models.WebhookDelivery) error {
+	lease := d.LockedUntil
+	if lease == nil {
+		return ErrLeaseConflict
+	}
+	d.LockedUntil = nil
+	res := r.db.WithContext(ctx).Model(d).Where("locked_until = ?", *lease).Select("*").Omit("id", "created_at").Updates(d)
+	if res.Error == nil && res.RowsAffected == 0 {
+		res.Error = ErrLeaseConflict
+	}
+	if res.Error != nil {
+		d.LockedUntil = lease
+	}
+	return res.Error
+}
+
+func (r *webhookRepository) Find(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	if err := r.db.WithContext(ctx).First(&d, id).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *webhookRepository) List(ctx context.Context, q Query) (*Page[models.WebhookDelivery], error) {
+	return list(r.db.WithContext(ctx).Model(&models.WebhookDelivery{}), q, webhookListSpec)
+}
+
+var webhookListSpec = listSpec[models.WebhookDelivery]{
+	fields: map[string]field[models.WebhookDelivery]{
+		"id":              column("id", func(d *models.WebhookDelivery) uint { return d.ID }),
+		"created_at":      column("created_at", func(d *models.WebhookDelivery) time.Time { return d.CreatedAt }),
+		"event":           column("event", func(d *models.WebhookDelivery) string { return d.Event }),
+		"status":          column("status", func(d *models.WebhookDelivery) string { return d.Status }),
+		"attempts":        column("attempts", func(d *models.WebhookDelivery) int { return d.Attempts }),
+		"next_attempt_at": column("next_attempt_at", func(d *models.WebhookDelivery) time.Time { return d.NextAttemptAt }),
+	},
+	key:         "id",
+	defaultSort: []Sort{{Field: "id", Desc: true}},
+}
+
+func (r *webhookRepository) Replay(ctx context.Context, id uint, now time.Time) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status <> ?", id, models.WebhookPending).
+		Updates(replayed(now))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		if _, err := r.Find(ctx, id); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func (r *webhookRepository) ReplayDead(ctx context.Context, event string, now time.Time) (int64, error) {
+	db := r.db.WithContext(ctx).Model(&models.WebhookDelivery{}).Where("status = ?", models.WebhookDead)
+	if event != "" {
+		db = db.Where("event = ?", event)
+	}
+	res := db.Updates(replayed(now))
+	return res.RowsAffected, res.Error
+}
+
+// replayed - поля доставки, возвращённой в очередь: полный набор попыток с момента now.
+func replayed(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"status":          models.WebhookPending,
+		"attempts":        0,
+		"next_attempt_at": now,
+		"locked_until":    nil,
+	}
+}
+
+func (r *webhookRepository) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	batch := r.db.WithContext(ctx).Model(&models.WebhookDelivery{}).Select("id").
+		Where("status = ? AND delivered_at < ?", models.WebhookDelivered, before).Limit(limit)
+	res := r.db.WithContext(ctx).Where("id IN (?)", batch).Delete(&models.WebhookDelivery{})
+	return res.RowsAffected, res.Error
+}
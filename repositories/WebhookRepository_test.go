@@ -0,0 +1,71 @@
+package repositories_test
+
+import (
+	"auth-service/models"
+	"auth-service/repositories"
+	"auth-service/testdb"
+	"errors"
+	"testing"
+	"time"
+)
+
+// claimOne занимает единственную доставку, срок которой наступил к now.
+func claimOne(t *testing.T, repo repositories.WebhookRepository, now time.Time) models.WebhookDelivery {
+	t.Helper()
+	claimed, err := repo.Claim(ctx, now, now.Add(time.Minute), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 {
+		t.Fatalf("claimed %d deliveries, want 1", len(claimed))
+	}
+	return claimed[0]
+}
+
+func TestWebhookSaveRequiresLease(t *testing.T) {
+	repo := repositories.NewWebhookRepository(testdb.SQLite(t))
+	now := time.Now()
+	err := repo.Enqueue(ctx, []models.WebhookDelivery{{
+		Event:         "token.issued",
+		Payload:       models.RawJSON(`{}`),
+		Status:        models.WebhookPending,
+		NextAttemptAt: now,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// аренда первого воркера истекла, доставку занял второй
+	first := claimOne(t, repo, now)
+	second := claimOne(t, repo, now.Add(2*time.Minute))
+
+	first.Attempts++
+	first.LastError = "stale"
+	if err := repo.Save(ctx, &first); !errors.Is(err, repositories.ErrLeaseConflict) {
+		t.Fatalf("save with expired lease: err = %v, want ErrLeaseConflict", err)
+	}
+	stored, err := repo.Find(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Attempts != 0 || stored.LastError != "" || stored.LockedUntil == nil {
+		t.Errorf("delivery changed by stale save: %+v", stored)
+	}
+
+	second.Attempts++
+	second.Status = models.WebhookDelivered
+	if err := repo.Save(ctx, &second); err != nil {
+		t.Fatalf("save with current lease: %v", err)
+	}
+	stored, err = repo.Find(ctx, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.WebhookDelivered || stored.Attempts != 1 || stored.LockedUntil != nil {
+		t.Errorf("saved delivery = %+v", stored)
+	}
+	// результат уже записан, повторная запись той же попытки не проходит
+	if err := repo.Save(ctx, &second); !errors.Is(err, repositories.ErrLeaseConflict) {
+		t.Errorf("second save: err = %v, want ErrLeaseConflict", err)
+	}
+}
@@ -0,0 +1,208 @@
+package routers
+
+import (
+	"auth-service/models"
+	"auth-service/services"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type AuditH struct {
+	audit         *services.AuditService
+	tenantService *services.TenantService
+}
+
+func NewAuditHandler(audit *services.AuditService, tenantService *services.TenantService) *AuditH {
+	return &AuditH{audit: audit, tenantService: tenantService}
+}
+
+// GetAuditEvents godoc
+// @Summary Журнал аудита
+// @Description Записи журнала аудита с фильтрами и постраничной выборкой по курсору, по умолчанию от новых к старым
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param tenant query string false "Арендатор; по умолчанию записи всех арендаторов"
+// @Param action query string false "Действие: token.issued, token.refreshed, login.failed, refresh.failed, session.revoked, session.ip_changed, logout, admin.*"
+// @Param outcome query string false "Результат: success или failure"
+// @Param actor query string false "Кто выполнил действие: user:{guid}, client:{client_id}, admin, anonymous"
+// @Param subject query string false "GUID пользователя, которого касается действие"
+// @Param ip query string false "IP адрес клиента"
+// @Param since query string false "Записи не раньше момента (RFC 3339)"
+// @Param until query string false "Записи раньше момента (RFC 3339)"
+// @Param sort query string false "Сортировка: id, created_at, tenant_id, action, outcome, actor, subject, ip; минус - по убыванию. По умолчанию -id"
+// @Param limit query int false "Размер страницы, по умолчанию 20, не больше 100"
+// @Param cursor query string false "next_cursor предыдущей страницы"
+// @Success 200 {object} models.AuditPageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/audit [get]
+func (h *AuditH) GetAuditEvents(ctx *fiber.Ctx) error {
+	filter, invalid := h.auditSearch(ctx)
+	if invalid != nil {
+		return ErrorResponse(ctx, invalid.Message, invalid.Code)
+	}
+	page, err := h.audit.Search(ctx.UserContext(), filter)
+	if errors.Is(err, services.ErrInvalidList) {
+		return ErrorResponse(ctx, err.Error(), 400)
+	}
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(page)
+}
+
+// ExportAuditEvents godoc
+// @Summary Выгрузка журнала аудита
+// @Description Все записи журнала по фильтрам в формате JSON Lines в порядке цепочки (по возрастанию id)
+// @Tags Администрирование
+// @Produce application/x-ndjson
+// @Security AdminKeyAuth
+// @Param tenant query string false "Арендатор; по умолчанию записи всех арендаторов"
+// @Param action query string false "Действие"
+// @Param outcome query string false "Результат: success или failure"
+// @Param actor query string false "Кто выполнил действие"
+// @Param subject query string false "GUID пользователя, которого касается действие"
+// @Param ip query string false "IP адрес клиента"
+// @Param since query string false "Записи не раньше момента (RFC 3339)"
+// @Param until query string false "Записи раньше момента (RFC 3339)"
+// @Success 200 {string} string "По записи models.AuditEvent в строке"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/admin/audit/export [get]
+func (h *AuditH) ExportAuditEvents(ctx *fiber.Ctx) error {
+	filter, invalid := h.auditSearch(ctx)
+	if invalid != nil {
+		return ErrorResponse(ctx, invalid.Message, invalid.Code)
+	}
+	// выгрузка идёт после выхода из хендлера, когда запрос уже не отменит её
+	exportCtx := context.WithoutCancel(ctx.UserContext())
+	ctx.Set(fiber.HeaderContentType, "application/x-ndjson")
+	ctx.Set(fiber.HeaderContentDisposition, `attachment; filename="audit.jsonl"`)
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		enc := json.NewEncoder(w)
+		err := h.audit.Export(exportCtx, filter, func(e *models.AuditEvent) error {
+			return enc.Encode(e)
+		})
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			slog.ErrorContext(exportCtx, "Audit export interrupted", "error", err)
+		}
+	})
+	return nil
+}
+
+// VerifyAuditChain godoc
+// @Summary Проверка журнала аудита
+// @Description Пересчитывает хеши всей цепочки журнала. valid false и broken_at - первая запись, изменённая или следующая за удалённой. head - хеш последней записи; сохранённый вне сервиса, он позволяет обнаружить удаление записей с конца журнала
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Success 200 {object} models.AuditVerifyResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/audit/verify [get]
+func (h *AuditH) VerifyAuditChain(ctx *fiber.Ctx) error {
+	res, err := h.audit.Verify(ctx.UserContext())
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(res)
+}
+
+// auditSearch читает фильтр журнала из параметров запроса.
+func (h *AuditH) auditSearch(ctx *fiber.Ctx) (models.AuditSearch, *fiber.Error) {
+	filter := models.AuditSearch{
+		ListRequest: listRequest(ctx),
+		Action:      ctx.Query("action"),
+		Outcome:     ctx.Query("outcome"),
+		Actor:       ctx.Query("actor"),
+		Subject:     ctx.Query("subject"),
+		IP:          ctx.Query("ip"),
+	}
+	if slug := ctx.Query("tenant"); slug != "" {
+		tenant, err := h.tenantService.GetTenant(slug)
+		if err != nil {
+			return filter, fiber.NewError(404, "Tenant not found")
+		}
+		filter.TenantID = &tenant.ID
+	}
+	var err error
+	if filter.Since, err = queryTime(ctx, "since"); err != nil {
+		return filter, fiber.NewError(400, "since must be an RFC 3339 timestamp")
+	}
+	if filter.Until, err = queryTime(ctx, "until"); err != nil {
+		return filter, fiber.NewError(400, "until must be an RFC 3339 timestamp")
+	}
+	return filter, nil
+}
+
+const adminPrefix = "/api/admin"
+
+// AuditAdmin записывает в журнал аудита изменения через административный API и все запросы
+// к нему с неверным ключом. Регистрируется перед AdminAuth.
+func AuditAdmin(audit *services.AuditService) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		err := ctx.Next()
+
+		status := responseStatus(ctx, err)
+		read := ctx.Method() == fiber.MethodGet || ctx.Method() == fiber.MethodHead
+		if read && status != fiber.StatusUnauthorized {
+			return err
+		}
+		// шаблон маршрута, если запрос до него дошёл, иначе (неверный ключ, нет маршрута) - путь
+		route := strings.TrimPrefix(ctx.Route().Path, adminPrefix)
+		if route == "" {
+			route = strings.TrimPrefix(ctx.Path(), adminPrefix)
+		}
+		e := models.AuditEvent{
+			Action:  models.AuditAdminActionPrefix + ctx.Method() + " " + route,
+			Outcome: models.AuditOutcomeSuccess,
+			Actor:   models.AuditActorAdmin,
+			Subject: ctx.Params("guid"),
+			Details: map[string]string{"path": ctx.Path()},
+		}
+		if status >= fiber.StatusBadRequest {
+			e.Outcome = models.AuditOutcomeFailure
+			e.Reason = strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+		}
+		if status == fiber.StatusUnauthorized {
+			e.Actor = models.AuditActorAnonymous
+		}
+		for _, param := range []string{"client_id", "slug", "name", "id"} {
+			if v := ctx.Params(param); v != "" {
+				e.Details[param] = v
+			}
+		}
+		if tenant := ctx.Query("tenant"); tenant != "" {
+			e.Details["tenant"] = tenant
+		}
+		audit.Record(ctx.UserContext(), e)
+		return err
+	}
+}
+
+// RequestInfo передаёт адрес и User-Agent клиента в ctx.UserContext() для записей аудита,
+// которые делают сервисы.
+func RequestInfo() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		ctx.SetUserContext(services.WithRequestInfo(ctx.UserContext(), services.RequestInfo{
+			IP:        ctx.IP(),
+			UserAgent: utils.CopyString(ctx.Get(fiber.HeaderUserAgent)),
+		}))
+		return ctx.Next()
+	}
+}
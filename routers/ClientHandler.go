@@ -0,0 +1,155 @@
+package routers
+
+import (
+	"auth-service/models"
+	"auth-service/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type ClientH struct {
+	clientService *services.OAuthClientService
+}
+
+func NewClientHandler(clientService *services.OAuthClientService) *ClientH {
+	return &ClientH{clientService: clientService}
+}
+
+// CreateClient godoc
+// @Summary Зарегистрировать OAuth клиента
+// @Description Создаёт клиента и возвращает client_id и client_secret. Секрет показывается только один раз
+// @Tags Администрирование
+// @Accept json
+// @Produce json
+// @Security AdminKeyAuth
+// @Param request body models.OAuthClientRequest true "Параметры клиента"
+// @Success 201 {object} models.OAuthClientResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/clients [post]
+func (h *ClientH) CreateClient(ctx *fiber.Ctx) error {
+	var req models.OAuthClientRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	client, secret, err := h.clientService.Register(req)
+	if err != nil {
+		return clientErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusCreated).JSON(models.NewOAuthClientResponse(client, secret))
+}
+
+// GetClients godoc
+// @Summary Список OAuth клиентов
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Success 200 {array} models.OAuthClientResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/clients [get]
+func (h *ClientH) GetClients(ctx *fiber.Ctx) error {
+	clients, err := h.clientService.GetClients()
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(clients)
+}
+
+// GetClient godoc
+// @Summary Получить OAuth клиента
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param client_id path string true "Идентификатор клиента"
+// @Success 200 {object} models.OAuthClientResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/clients/{client_id} [get]
+func (h *ClientH) GetClient(ctx *fiber.Ctx) error {
+	client, err := h.clientService.GetClient(ctx.Params("client_id"))
+	if err != nil {
+		return clientErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.NewOAuthClientResponse(client, ""))
+}
+
+// UpdateClient godoc
+// @Summary Изменить OAuth клиента
+// @Description Полностью заменяет параметры клиента. Секрет возвращается только если он был создан заново
+// @Tags Администрирование
+// @Accept json
+// @Produce json
+// @Security AdminKeyAuth
+// @Param client_id path string true "Идентификатор клиента"
+// @Param request body models.OAuthClientRequest true "Параметры клиента"
+// @Success 200 {object} models.OAuthClientResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/clients/{client_id} [put]
+func (h *ClientH) UpdateClient(ctx *fiber.Ctx) error {
+	var req models.OAuthClientRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	client, secret, err := h.clientService.Update(ctx.Params("client_id"), req)
+	if err != nil {
+		return clientErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.NewOAuthClientResponse(client, secret))
+}
+
+// RotateClientSecret godoc
+// @Summary Перевыпустить секрет OAuth клиента
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param client_id path string true "Идентификатор клиента"
+// @Success 200 {object} models.OAuthClientResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/clients/{client_id}/secret [post]
+func (h *ClientH) RotateClientSecret(ctx *fiber.Ctx) error {
+	client, secret, err := h.clientService.RotateSecret(ctx.Params("client_id"))
+	if err != nil {
+		return clientErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.NewOAuthClientResponse(client, secret))
+}
+
+// DeleteClient godoc
+// @Summary Удалить OAuth клиента
+// @Tags Администрирование
+// @Security AdminKeyAuth
+// @Param client_id path string true "Идентификатор клиента"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/clients/{client_id} [delete]
+func (h *ClientH) DeleteClient(ctx *fiber.Ctx) error {
+	if err := h.clientService.Delete(ctx.Params("client_id")); err != nil {
+		return clientErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+func clientErrorResponse(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrorResponse(ctx, "Client not found", 404)
+	case errors.Is(err, services.ErrInvalidClientMetadata):
+		return ErrorResponse(ctx, err.Error(), 400)
+	}
+	return ErrorResponse(ctx, "Internal Server Error", 500)
+}
@@ -10,3 +10,11 @@ func ErrorResponse(ctx *fiber.Ctx, err string, code int) error {
 		Error: err,
 	})
 }
+
+func OAuthErrorResponse(ctx *fiber.Ctx, err, description string, code int) error {
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	return ctx.Status(code).JSON(models.OAuthErrorResponse{
+		Error:            err,
+		ErrorDescription: description,
+	})
+}
@@ -2,94 +2,116 @@ package routers
 
 import (
 	"auth-service/config"
+	"auth-service/metrics"
 	"auth-service/models"
 	"auth-service/services"
+	"auth-service/tracing"
 	"auth-service/webhook"
 	"errors"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/log"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"log/slog"
 	"net/http"
 	"time"
 )
 
 type TokenH struct {
-	tokenService *services.TokenService
-	userService  *services.UserService
+	tokenService  *services.TokenService
+	userService   *services.UserService
+	clientService *services.OAuthClientService
+	audit         *services.AuditService
+	webhooks      *services.WebhookService
 }
 
-func NewTokenHandler(tokenService *services.TokenService, userService *services.UserService) *TokenH {
+func NewTokenHandler(
+	tokenService *services.TokenService,
+	userService *services.UserService,
+	clientService *services.OAuthClientService,
+	audit *services.AuditService,
+	webhooks *services.WebhookService,
+) *TokenH {
 	return &TokenH{
-		tokenService: tokenService,
-		userService:  userService,
+		tokenService:  tokenService,
+		userService:   userService,
+		clientService: clientService,
+		audit:         audit,
+		webhooks:      webhooks,
 	}
 }
 
-// GetAllUsers godoc
-// @Summary Получить список всех пользователей. Этот маршрут добавлен для удобства проверяющего!
-// @Description Возвращает список GUID всех пользователей в системе
-// @Tags Пользователь
-// @Accept json
-// @Produce json
-// @Success 200 {array} models.UserResponse
-// @Failure 500 {object} models.ErrorResponse
-// @Router /api/get-users-GUID [get]
-func (h *TokenH) GetAllUsers(ctx *fiber.Ctx) error {
-	u, _ := h.userService.GetUsers()
-	if len(u) == 0 {
-		_, err := h.userService.NewUsers(config.GetConfig().Usr.Count)
-		if err != nil {
-			log.Fatalf("Error while create simple users: %s", err)
-		}
-	}
-	users, err := h.userService.GetUsers()
-	if err != nil {
-		return ErrorResponse(ctx, "Internal Server Error", 500)
-	}
-	return ctx.Status(http.StatusOK).JSON(users)
-}
-
 // TokenHandler godoc
 // @Summary Получить токены
-// @Description Генерирует пару access/refresh токенов для пользователя
+// @Description Генерирует пару access/refresh токенов для пользователя. scope токена - запрошенные scope из jwt.default_scopes (и scope клиента, если указан client_id)
 // @Tags Аутентификация
 // @Accept json
 // @Produce json
 // @Param guid query string true "GUID пользователя"
+// @Param scope query string false "Запрашиваемые scope через пробел"
+// @Param client_id query string false "Клиент, для которого выдаются токены"
 // @Success 200 {object} models.TokenResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/tokens [get]
 func (h *TokenH) TokenHandler(ctx *fiber.Ctx) error {
+	tenant := Tenant(ctx)
 	guid := ctx.Query("guid")
 	userAgent := ctx.Get("User-Agent")
 	ip := ctx.IP()
 
 	if guid == "" {
-		return ErrorResponse(ctx, "Bad Request", 400)
+		return h.loginFailed(ctx, guid, metrics.ReasonInvalidRequest, "Bad Request", 400)
+	}
+	if !h.userService.IsExist(tenant.ID, guid) {
+		return h.loginFailed(ctx, guid, metrics.ReasonNotFound, "User not found", 404)
+	}
+	if !h.userService.IsActive(tenant.ID, guid) {
+		return h.loginFailed(ctx, guid, metrics.ReasonUserDisabled, "User is disabled", 403)
+	}
+
+	var client *models.OAuthClient
+	if clientID := ctx.Query("client_id"); clientID != "" {
+		c, err := h.clientService.GetClient(clientID)
+		if err != nil {
+			return h.loginFailed(ctx, guid, metrics.ReasonClientNotFound, "Client not found", 404)
+		}
+		client = c
 	}
-	if !h.userService.IsExist(guid) {
-		return ErrorResponse(ctx, "User not found", 404)
+	scopes, err := h.tokenService.ResolveUserScopes(tenant, ctx.Query("scope"), client)
+	if err != nil {
+		return h.loginFailed(ctx, guid, metrics.ReasonInvalidScope, err.Error(), 400)
 	}
 
-	refreshToken, err := h.tokenService.FindTokenByUserGUID(guid)
+	refreshToken, err := h.tokenService.FindTokenByUserGUID(ctx.UserContext(), tenant.ID, guid)
 	if err != nil || refreshToken.ExpiresAt.Before(time.Now()) {
 		if err == nil {
-			_ = h.tokenService.DeleteTokenByID(refreshToken.ID)
+			_ = h.tokenService.DeleteTokenByID(ctx.UserContext(), tenant.ID, refreshToken.ID)
+		}
+		session := services.Session{
+			Tenant:    tenant,
+			UserGuid:  guid,
+			UserAgent: userAgent,
+			IP:        ip,
+			Scopes:    scopes,
 		}
-		access, refresh, err := h.tokenService.GenerateTokens(guid, userAgent, ip)
+		if client != nil {
+			session.ClientID = client.ClientID
+		}
+		access, refresh, err := h.tokenService.GenerateTokens(ctx.UserContext(), session)
 		if err != nil {
 			return ErrorResponse(ctx, "Internal Server Error", 500)
 		}
-		return ctx.Status(http.StatusOK).JSON(models.NewTokenResponse(access, refresh))
+		return ctx.Status(http.StatusOK).JSON(models.NewTokenResponse(access, refresh, scopes))
 	}
 
-	return ErrorResponse(ctx, "Your refresh token is valid and time not expired", 403)
+	return h.loginFailed(ctx, guid, metrics.ReasonSessionActive, "Your refresh token is valid and time not expired", 403)
 }
 
 // RefreshTokenHandler godoc
 // @Summary Обновить токены
-// @Description Обновляет пару access/refresh токенов по валидному refresh токену
+// @Description Обновляет пару access/refresh токенов по валидному refresh токену. Необязательный scope сужает scope сессии, расширить его нельзя
 // @Tags Аутентификация
 // @Accept json
 // @Produce json
@@ -103,57 +125,101 @@ func (h *TokenH) TokenHandler(ctx *fiber.Ctx) error {
 func (h *TokenH) RefreshTokenHandler(ctx *fiber.Ctx) error {
 	req, err := h.parseTokenRequest(ctx)
 	if err != nil {
-		return ErrorResponse(ctx, err.Error(), 400)
+		return h.refreshFailed(ctx, "", metrics.ReasonInvalidRequest, err.Error(), 400)
 	}
 
-	claims, err := h.parseAccessToken(req.AccessToken)
+	claims, err := h.parseAccessToken(ctx, req.AccessToken)
 	if err != nil {
-		return ErrorResponse(ctx, "Invalid access token", 400)
+		return h.refreshFailed(ctx, "", metrics.ReasonInvalidToken, "Invalid access token", 400)
 	}
 
-	stored, err := h.getStoredRefreshToken(claims.Sub)
+	stored, err := h.tokenService.FindSession(ctx.UserContext(), Tenant(ctx).ID, claims.Sub, req.RefreshToken)
 	if err != nil {
-		return ErrorResponse(ctx, "Not Found!", 404)
+		return h.refreshFailed(ctx, claims.Sub, metrics.ReasonNotFound, "Not Found!", 404)
 	}
 
-	if !h.isRefreshTokenValid(stored, req.RefreshToken, claims) {
-		return ErrorResponse(ctx, "Invalid refresh/access token pair", 400)
+	if !h.isRefreshTokenValid(ctx, stored, req.RefreshToken, claims) {
+		return h.refreshFailed(ctx, claims.Sub, metrics.ReasonPairMismatch, "Invalid refresh/access token pair", 400)
+	}
+	if !h.userService.IsActive(stored.TenantID, stored.UserGuid) {
+		return h.refreshFailed(ctx, claims.Sub, metrics.ReasonUserDisabled, "User is disabled", 403)
+	}
+
+	scopes, err := h.tokenService.DownscopeSession(stored, req.Scope)
+	if err != nil {
+		return h.refreshFailed(ctx, claims.Sub, metrics.ReasonInvalidScope, err.Error(), 400)
 	}
 
 	userAgent := ctx.Get("User-Agent")
 	ip := ctx.IP()
 
 	if stored.UserAgent != userAgent {
-		_ = h.tokenService.DeleteTokenByID(stored.ID)
-		return ErrorResponse(ctx, "Your User-Agent is edited, logout", 403)
+		_ = h.tokenService.DeleteTokenByID(ctx.UserContext(), stored.TenantID, stored.ID)
+		if h.tokenService.RevokeAccessToken(ctx.UserContext(), stored.TenantID, claims) == nil {
+			metrics.TokensRevoked.WithLabelValues(metrics.ReasonUserAgentChanged).Inc()
+		}
+		h.audit.Record(ctx.UserContext(), models.AuditEvent{
+			TenantID: stored.TenantID,
+			Action:   models.AuditSessionRevoked,
+			Reason:   metrics.ReasonUserAgentChanged,
+			Actor:    models.UserActor(stored.UserGuid),
+			Subject:  stored.UserGuid,
+			Details:  map[string]string{"session_user_agent": stored.UserAgent},
+		})
+		return h.refreshFailed(ctx, claims.Sub, metrics.ReasonUserAgentChanged, "Your User-Agent is edited, logout", 403)
 	}
 
+	var deliveries []models.WebhookDelivery
 	if stored.IpAddress != ip {
-		attempt := webhook.LoginAttempt{
-			UserGUID: userAgent,
+		h.audit.Record(ctx.UserContext(), models.AuditEvent{
+			TenantID: stored.TenantID,
+			Action:   models.AuditSessionIPChanged,
+			Actor:    models.UserActor(stored.UserGuid),
+			Subject:  stored.UserGuid,
+			Details:  map[string]string{"previous_ip": stored.IpAddress},
+		})
+		delivery, err := h.webhooks.NewDelivery(ctx.UserContext(), webhook.EventNewIP, webhook.LoginAttempt{
+			UserGUID: stored.UserGuid,
 			IP:       ip,
-			Event:    "new_ip",
+			Event:    webhook.EventNewIP,
+		})
+		if err != nil {
+			return ErrorResponse(ctx, "Internal Server Error", 500)
+		}
+		if delivery != nil {
+			deliveries = append(deliveries, *delivery)
 		}
-		go func() {
-			err = webhook.EditIpWebhook(config.GetConfig().Webhook.Url, attempt)
-			if err != nil {
-				log.Errorf("Failed to send webhook: %s", err.Error())
-			}
-		}()
 	}
 
-	_ = h.tokenService.DeleteTokenByID(stored.ID)
-	access, refresh, err := h.tokenService.GenerateTokens(stored.UserGuid, userAgent, ip)
+	access, refresh, err := h.tokenService.RotateTokens(ctx.UserContext(), stored, services.Session{
+		Tenant:    Tenant(ctx),
+		UserGuid:  stored.UserGuid,
+		UserAgent: userAgent,
+		IP:        ip,
+		ClientID:  stored.ClientID,
+		Scopes:    scopes,
+		AuthTime:  stored.AuthTime,
+		Webhooks:  deliveries,
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// refresh токен уже использован параллельным запросом
+		return h.refreshFailed(ctx, claims.Sub, metrics.ReasonAlreadyRotated, "Not Found!", 404)
+	}
 	if err != nil {
 		return ErrorResponse(ctx, "Internal Server Error", 500)
 	}
+	if err := h.tokenService.RevokeAccessToken(ctx.UserContext(), stored.TenantID, claims); err != nil {
+		slog.ErrorContext(ctx.UserContext(), "Failed to revoke access token", "error", err)
+	} else {
+		metrics.TokensRevoked.WithLabelValues(metrics.ReasonRotated).Inc()
+	}
 
-	return ctx.Status(http.StatusOK).JSON(models.NewTokenResponse(access, refresh))
+	return ctx.Status(http.StatusOK).JSON(models.NewTokenResponse(access, refresh, scopes))
 }
 
 // GetUser godoc
 // @Summary Получить информацию о пользователе
-// @Description Возвращает GUID пользователя по валидному access токену
+// @Description Возвращает GUID пользователя по валидному access токену, если сессия токена (claim sid) действует
 // @Tags Пользователь
 // @Accept json
 // @Produce json
@@ -169,12 +235,12 @@ func (h *TokenH) GetUser(ctx *fiber.Ctx) error {
 		return ErrorResponse(ctx, err.Error(), 400)
 	}
 
-	claims, err := h.parseAccessToken(req.AccessToken)
+	claims, err := h.parseAccessToken(ctx, req.AccessToken)
 	if err != nil {
 		return ErrorResponse(ctx, "Invalid token", 400)
 	}
 
-	stored, err := h.getStoredRefreshToken(claims.Sub)
+	stored, err := h.getStoredRefreshToken(ctx, claims, req.RefreshToken)
 	if err != nil {
 		return ErrorResponse(ctx, "Not Found!", 404)
 	}
@@ -184,7 +250,7 @@ func (h *TokenH) GetUser(ctx *fiber.Ctx) error {
 
 // Logout godoc
 // @Summary Выход из системы
-// @Description Удаляет refresh токен пользователя
+// @Description Удаляет сессию, к которой относится access токен (по claim sid), и отзывает этот access токен. Другие сессии пользователя не затрагиваются. refresh_token нужен только для access токенов без sid
 // @Tags Аутентификация
 // @Accept json
 // @Produce json
@@ -200,44 +266,98 @@ func (h *TokenH) Logout(ctx *fiber.Ctx) error {
 		return ErrorResponse(ctx, err.Error(), 400)
 	}
 
-	claims, err := h.parseAccessToken(req.AccessToken)
+	claims, err := h.parseAccessToken(ctx, req.AccessToken)
 	if err != nil {
 		return ErrorResponse(ctx, "Invalid token", 400)
 	}
 
-	stored, err := h.getStoredRefreshToken(claims.Sub)
+	stored, err := h.getStoredRefreshToken(ctx, claims, req.RefreshToken)
 	if err != nil {
 		return ErrorResponse(ctx, "Not Found!", 404)
 	}
 
-	if err := h.tokenService.DeleteTokenByID(stored.ID); err != nil {
+	if err := h.tokenService.DeleteTokenByID(ctx.UserContext(), stored.TenantID, stored.ID); err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	if err := h.tokenService.RevokeAccessToken(ctx.UserContext(), stored.TenantID, claims); err != nil {
 		return ErrorResponse(ctx, "Internal Server Error", 500)
 	}
+	metrics.TokensRevoked.WithLabelValues(metrics.ReasonLogout).Inc()
+	h.audit.Record(ctx.UserContext(), models.AuditEvent{
+		TenantID: stored.TenantID,
+		Action:   models.AuditLogout,
+		Actor:    models.UserActor(stored.UserGuid),
+		Subject:  stored.UserGuid,
+	})
 
 	return ctx.Status(http.StatusOK).JSON(models.Logout{Msg: "Ok."})
 }
 
-func (h *TokenH) parseTokenRequest(ctx *fiber.Ctx) (*models.TokenRequest, error) {
-	var req models.TokenRequest
-	if err := ctx.BodyParser(&req); err != nil {
+// loginFailed и refreshFailed отвечают ошибкой, учитывают отказ в metrics по причине reason
+// и записывают его в журнал аудита. subject - GUID пользователя, если он известен.
+func (h *TokenH) loginFailed(ctx *fiber.Ctx, subject, reason, err string, code int) error {
+	metrics.LoginFailures.WithLabelValues(reason).Inc()
+	h.audit.Record(ctx.UserContext(), failureAuditEvent(ctx, models.AuditLoginFailed, subject, reason))
+	return ErrorResponse(ctx, err, code)
+}
+
+func (h *TokenH) refreshFailed(ctx *fiber.Ctx, subject, reason, err string, code int) error {
+	metrics.RefreshFailures.WithLabelValues(reason).Inc()
+	h.audit.Record(ctx.UserContext(), failureAuditEvent(ctx, models.AuditRefreshFailed, subject, reason))
+	return ErrorResponse(ctx, err, code)
+}
+
+func failureAuditEvent(ctx *fiber.Ctx, action, subject, reason string) models.AuditEvent {
+	e := models.AuditEvent{
+		TenantID: Tenant(ctx).ID,
+		Action:   action,
+		Outcome:  models.AuditOutcomeFailure,
+		Reason:   reason,
+		Actor:    models.AuditActorAnonymous,
+		Subject:  subject,
+	}
+	if subject != "" {
+		e.Actor = models.UserActor(subject)
+	}
+	return e
+}
+
+func (h *TokenH) parseTokenRequest(ctx *fiber.Ctx) (req *models.TokenRequest, err error) {
+	_, span := tracing.Start(ctx.UserContext(), "TokenH.parseTokenRequest")
+	defer func() { tracing.End(span, err) }()
+
+	req = &models.TokenRequest{}
+	if err := ctx.BodyParser(req); err != nil {
 		return nil, errors.New("invalid request body")
 	}
-	return &req, nil
+	return req, nil
 }
 
-func (h *TokenH) parseAccessToken(token string) (*models.TokenClaims, error) {
-	claims := models.GetClaims(token, config.GetConfig().Jwt.SecretKey)
-	if claims == nil {
+// parseAccessToken проверяет токен пользователя, выданный в арендаторе запроса и не отозванный.
+func (h *TokenH) parseAccessToken(ctx *fiber.Ctx, token string) (claims *models.TokenClaims, err error) {
+	spanCtx, span := tracing.Start(ctx.UserContext(), "TokenH.parseAccessToken")
+	defer func() { tracing.End(span, err) }()
+
+	claims = models.GetClaims(token, config.GetConfig().Jwt.SecretKey)
+	if claims == nil || claims.Tid != Tenant(ctx).Slug || h.tokenService.IsAccessTokenRevoked(spanCtx, Tenant(ctx).ID, claims) {
 		return nil, errors.New("invalid access token")
 	}
 	return claims, nil
 }
 
-func (h *TokenH) getStoredRefreshToken(guid string) (*models.Token, error) {
-	return h.tokenService.FindTokenByUserGUID(guid)
+// getStoredRefreshToken находит сессию предъявленного access токена. refreshToken нужен
+// только токенам, выданным до claim sid.
+func (h *TokenH) getStoredRefreshToken(ctx *fiber.Ctx, claims *models.TokenClaims, refreshToken string) (*models.Token, error) {
+	return h.tokenService.FindTokenSession(ctx.UserContext(), Tenant(ctx).ID, claims, refreshToken)
 }
 
-func (h *TokenH) isRefreshTokenValid(stored *models.Token, input string, claims *models.TokenClaims) bool {
-	return h.tokenService.ValidateRefreshToken(stored.RefreshToken, input) &&
+func (h *TokenH) isRefreshTokenValid(ctx *fiber.Ctx, stored *models.Token, input string, claims *models.TokenClaims) bool {
+	spanCtx, span := tracing.Start(ctx.UserContext(), "TokenH.isRefreshTokenValid")
+	defer span.End()
+
+	valid := stored.UserGuid == claims.Sub &&
+		h.tokenService.ValidateRefreshToken(spanCtx, stored, input) &&
 		h.tokenService.ValidateTokenPair(*claims, input)
+	span.SetAttributes(attribute.Bool("refresh_token.valid", valid))
+	return valid
 }
@@ -0,0 +1,134 @@
+package routers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/repositories"
+	"auth-service/services"
+	"auth-service/testdb"
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// tokenApp - приложение с маршрутами /api/me и /api/logout поверх тестовой базы db.
+// middleware регистрируются перед ResolveTenant.
+func tokenApp(t *testing.T, db *gorm.DB, middleware ...fiber.Handler) (*fiber.App, *services.TokenService, *models.Tenant) {
+	t.Helper()
+	config.GetConfig().Jwt.SecretKey = testSecret
+	c := *config.GetConfig()
+
+	users := repositories.NewUserRepository(db)
+	sessions := repositories.NewTokenRepository(db)
+	tenants := services.NewTenantService(repositories.NewTenantRepository(db), users, sessions)
+	audit := services.NewAuditService(repositories.NewAuditRepository(db))
+	webhooks := services.NewWebhookService(repositories.NewWebhookRepository(db), func() {}, c)
+	tokens := services.NewTokenService(sessions, services.NewRBACService(repositories.NewRoleRepository(db)), audit, webhooks, c)
+	handler := NewTokenHandler(tokens, services.NewUserService(users, sessions), services.NewOAuthClientService(repositories.NewOAuthClientRepository(db)), audit, webhooks)
+
+	tenant, err := tenants.GetTenant("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	for _, m := range middleware {
+		app.Use(m)
+	}
+	app.Use(ResolveTenant(tenants))
+	app.Post("/api/me", handler.GetUser)
+	app.Post("/api/logout", handler.Logout)
+	return app, tokens, tenant
+}
+
+// post отправляет пару токенов на path и возвращает код ответа.
+func post(t *testing.T, app *fiber.App, path, access, refresh string) int {
+	t.Helper()
+	body, _ := json.Marshal(models.TokenRequest{AccessToken: access, RefreshToken: refresh})
+	req := httptest.NewRequest("POST", path, strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestLogoutEndsPresentedSession(t *testing.T) {
+	db := testdb.SQLite(t)
+	app, tokens, tenant := tokenApp(t, db)
+	const guid = "11111111-1111-1111-1111-111111111111"
+	if err := repositories.NewUserRepository(db).Create(tenant.ID, &models.User{Guid: guid, Name: "user"}); err != nil {
+		t.Fatal(err)
+	}
+
+	session := services.Session{Tenant: tenant, UserGuid: guid}
+	firstAccess, firstRefresh, err := tokens.GenerateTokens(context.Background(), session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondAccess, secondRefresh, err := tokens.GenerateTokens(context.Background(), session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := post(t, app, "/api/me", secondAccess, ""); status != 200 {
+		t.Fatalf("me: status = %d, want 200", status)
+	}
+	if status := post(t, app, "/api/logout", secondAccess, ""); status != 200 {
+		t.Fatalf("logout: status = %d, want 200", status)
+	}
+
+	// вторая сессия завершена, её access токен отозван
+	if status := post(t, app, "/api/me", secondAccess, secondRefresh); status != 400 {
+		t.Errorf("me after logout: status = %d, want 400", status)
+	}
+	if _, err := tokens.FindSession(context.Background(), tenant.ID, guid, secondRefresh); err == nil {
+		t.Error("logged out session still exists")
+	}
+	// первая сессия не затронута
+	if status := post(t, app, "/api/me", firstAccess, ""); status != 200 {
+		t.Errorf("me of other session: status = %d, want 200", status)
+	}
+	if _, err := tokens.FindSession(context.Background(), tenant.ID, guid, firstRefresh); err != nil {
+		t.Errorf("other session: %v", err)
+	}
+}
+
+func TestSessionOfTokenWithoutSid(t *testing.T) {
+	db := testdb.SQLite(t)
+	app, tokens, tenant := tokenApp(t, db)
+	const guid = "11111111-1111-1111-1111-111111111111"
+	if err := repositories.NewUserRepository(db).Create(tenant.ID, &models.User{Guid: guid, Name: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	access, refresh, err := tokens.GenerateTokens(context.Background(), services.Session{Tenant: tenant, UserGuid: guid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tokens.GenerateTokens(context.Background(), services.Session{Tenant: tenant, UserGuid: guid}); err != nil {
+		t.Fatal(err)
+	}
+
+	// access токен, выданный до claim sid: сессия находится только по refresh токену пары
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(access, claims, func(*jwt.Token) (any, error) { return []byte(testSecret), nil }); err != nil {
+		t.Fatal(err)
+	}
+	delete(claims, "sid")
+	legacy := accessToken(t, claims)
+
+	if status := post(t, app, "/api/me", legacy, ""); status != 404 {
+		t.Errorf("without refresh token: status = %d, want 404", status)
+	}
+	if status := post(t, app, "/api/logout", legacy, refresh); status != 200 {
+		t.Fatalf("logout: status = %d, want 200", status)
+	}
+	if _, err := tokens.FindSession(context.Background(), tenant.ID, guid, refresh); err == nil {
+		t.Error("logged out session still exists")
+	}
+}
@@ -0,0 +1,44 @@
+package routers
+
+import (
+	"auth-service/models"
+	"auth-service/services"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+)
+
+type HealthH struct {
+	healthService *services.HealthService
+}
+
+func NewHealthHandler(healthService *services.HealthService) *HealthH {
+	return &HealthH{healthService: healthService}
+}
+
+// Liveness godoc
+// @Summary Liveness проба
+// @Description Процесс запущен и обрабатывает запросы. Зависимости не проверяются: их недоступность не лечится перезапуском
+// @Tags Состояние
+// @Produce json
+// @Success 200 {object} models.HealthResponse
+// @Router /healthz [get]
+func (h *HealthH) Liveness(ctx *fiber.Ctx) error {
+	return ctx.Status(http.StatusOK).JSON(models.HealthResponse{Status: models.HealthStatusOK})
+}
+
+// Readiness godoc
+// @Summary Readiness проба
+// @Description Сервис готов принимать запросы: база доступна, миграции применены, ключи подписи загружены и остановка не начата. Результат и время выполнения каждой проверки - в checks
+// @Tags Состояние
+// @Produce json
+// @Success 200 {object} models.HealthResponse
+// @Failure 503 {object} models.HealthResponse
+// @Router /readyz [get]
+func (h *HealthH) Readiness(ctx *fiber.Ctx) error {
+	report, ready := h.healthService.Ready(ctx.UserContext())
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	if !ready {
+		return ctx.Status(http.StatusServiceUnavailable).JSON(report)
+	}
+	return ctx.Status(http.StatusOK).JSON(report)
+}
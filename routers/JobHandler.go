@@ -0,0 +1,28 @@
+package routers
+
+import (
+	"auth-service/scheduler"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+)
+
+type JobH struct {
+	scheduler *scheduler.Scheduler
+}
+
+func NewJobHandler(s *scheduler.Scheduler) *JobH {
+	return &JobH{scheduler: s}
+}
+
+// GetJobs godoc
+// @Summary Фоновые задачи
+// @Description Счётчики фоновых задач этой реплики с момента запуска: выполненные и пропущенные запуски (задачу выполняла другая реплика), ошибки и количество удалённых записей
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Success 200 {array} scheduler.JobStatus
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/admin/jobs [get]
+func (h *JobH) GetJobs(ctx *fiber.Ctx) error {
+	return ctx.Status(http.StatusOK).JSON(h.scheduler.Status())
+}
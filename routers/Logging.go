@@ -0,0 +1,77 @@
+package routers
+
+import (
+	"auth-service/logging"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// RequestID принимает идентификатор запроса из X-Request-ID или создаёт новый, возвращает его
+// в ответе и кладёт в ctx.UserContext(): записи журнала с этим контекстом получают request_id.
+func RequestID() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id := ctx.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		} else {
+			id = utils.CopyString(id)
+		}
+		ctx.Set(requestIDHeader, id)
+		ctx.SetUserContext(logging.WithRequestID(ctx.UserContext(), id))
+		return ctx.Next()
+	}
+}
+
+// validRequestID - идентификатор от клиента попадает в журнал, поэтому принимаются только
+// короткие строки из букв, цифр и -_.:
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' || r == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLog пишет в журнал запись о каждом запросе, кроме запросов к skip. Пишется путь без
+// строки запроса: в ней могут быть коды авторизации и другие секреты.
+func AccessLog(skip ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		for _, path := range skip {
+			if ctx.Path() == path {
+				return ctx.Next()
+			}
+		}
+		started := time.Now()
+		err := ctx.Next()
+
+		status := responseStatus(ctx, err)
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Method()),
+			slog.String("path", ctx.Path()),
+			slog.String("route", ctx.Route().Path),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(started).Microseconds())/1000),
+			slog.String("ip", ctx.IP()),
+			slog.String("user_agent", ctx.Get(fiber.HeaderUserAgent)),
+			slog.Int("bytes", len(ctx.Response().Body())),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+		}
+		slog.LogAttrs(ctx.UserContext(), level, "Request", attrs...)
+		return err
+	}
+}
@@ -0,0 +1,39 @@
+package routers
+
+import (
+	"auth-service/metrics"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"strconv"
+	"time"
+)
+
+// Metrics измеряет время обработки запросов в metrics.HTTPDuration. Маршрут берётся после
+// обработки: только тогда известен шаблон, которому соответствовал запрос.
+func Metrics() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		started := time.Now()
+		err := ctx.Next()
+
+		status := responseStatus(ctx, err)
+		// Method указывает в буфер запроса, который fasthttp использует повторно, а метка хранится
+		metrics.HTTPDuration.
+			WithLabelValues(utils.CopyString(ctx.Method()), ctx.Route().Path, strconv.Itoa(status)).
+			Observe(time.Since(started).Seconds())
+		return err
+	}
+}
+
+// responseStatus - код ответа на запрос, обработка которого вернула err. Ответ на ошибку
+// записывает ErrorHandler уже после middleware, поэтому код берётся из самой ошибки.
+func responseStatus(ctx *fiber.Ctx, err error) int {
+	if err == nil {
+		return ctx.Response().StatusCode()
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
@@ -0,0 +1,224 @@
+package routers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/services"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"net"
+	"slices"
+	"strings"
+)
+
+const (
+	claimsKey  = "claims"
+	tenantKey  = "tenant"
+	revokedKey = "revoked"
+)
+
+// AdminAuth защищает административные маршруты ключом из конфигурации.
+// Пустой ключ полностью отключает административный API.
+func AdminAuth(apiKey string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		key := ctx.Get("X-Admin-Key")
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			return ErrorResponse(ctx, "Unauthorized", 401)
+		}
+		return ctx.Next()
+	}
+}
+
+// bearerToken возвращает access токен из заголовка Authorization или cookie access_token
+// (для браузерных переходов на /oauth/authorize).
+func bearerToken(ctx *fiber.Ctx) string {
+	if auth := ctx.Get(fiber.HeaderAuthorization); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ctx.Cookies("access_token")
+}
+
+// RequireScopes пропускает запрос, только если access токен (Authorization: Bearer) содержит
+// все перечисленные scope. Claims токена доступны дальше через Claims(ctx).
+func RequireScopes(scopes ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims := authenticate(ctx)
+		if claims == nil {
+			return unauthorized(ctx)
+		}
+		if !claims.HasScopes(scopes...) {
+			ctx.Set(fiber.HeaderWWWAuthenticate,
+				fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, strings.Join(scopes, " ")))
+			return ErrorResponse(ctx, "Insufficient scope", 403)
+		}
+		return ctx.Next()
+	}
+}
+
+// RequireAudience пропускает запрос, только если access токен выдан для аудитории aud.
+func RequireAudience(aud string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims := authenticate(ctx)
+		if claims == nil {
+			return unauthorized(ctx)
+		}
+		if !claims.HasAudience(aud) {
+			ctx.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token", error_description="audience mismatch"`)
+			return ErrorResponse(ctx, "Invalid token audience", 401)
+		}
+		return ctx.Next()
+	}
+}
+
+// Claims возвращает claims, проверенные RequireScopes/RequireAudience/Authorizer.
+func Claims(ctx *fiber.Ctx) *models.TokenClaims {
+	claims, _ := ctx.Locals(claimsKey).(*models.TokenClaims)
+	return claims
+}
+
+// authenticate проверяет access токен один раз за запрос. Токен пользователя принимается
+// только в арендаторе, для которого он выдан (claim tid).
+func authenticate(ctx *fiber.Ctx) *models.TokenClaims {
+	if claims := Claims(ctx); claims != nil {
+		return claims
+	}
+	if ctx.Locals(revokedKey) != nil {
+		return nil
+	}
+	claims := models.GetClaims(bearerToken(ctx), config.GetConfig().Jwt.SecretKey)
+	if claims == nil || !inTenant(ctx, claims) {
+		return nil
+	}
+	ctx.Locals(claimsKey, claims)
+	return claims
+}
+
+func inTenant(ctx *fiber.Ctx, claims *models.TokenClaims) bool {
+	tenant := Tenant(ctx)
+	return tenant == nil || claims.IsClientToken() || claims.Tid == tenant.Slug
+}
+
+// RejectRevoked отклоняет access токены завершённых сессий (выход, обновление токенов):
+// для них authenticate возвращает nil. Регистрируется после ResolveTenant.
+func RejectRevoked(tokens *services.TokenService) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if claims := authenticate(ctx); claims != nil && tokens.IsAccessTokenRevoked(ctx.UserContext(), Tenant(ctx).ID, claims) {
+			ctx.Locals(claimsKey, nil)
+			ctx.Locals(revokedKey, true)
+		}
+		return ctx.Next()
+	}
+}
+
+// ResolveTenant определяет арендатора запроса по явному параметру (заголовок X-Tenant или
+// query tenant), префиксу пути /t/{tenant}/... или заголовку Host (domains арендатора).
+// Без явного указания и совпадения по хосту используется арендатор по умолчанию.
+func ResolveTenant(tenants *services.TenantService) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		slug := ctx.Get("X-Tenant", ctx.Query("tenant"))
+		if fromPath := tenantFromPath(ctx.Path()); fromPath != "" {
+			if slug != "" && slug != fromPath {
+				return ErrorResponse(ctx, "Tenant in path and parameters does not match", 400)
+			}
+			slug = fromPath
+		}
+
+		tenant, err := tenants.Resolve(slug, hostname(ctx))
+		switch {
+		case errors.Is(err, services.ErrTenantDisabled):
+			return ErrorResponse(ctx, err.Error(), 403)
+		case err != nil:
+			return ErrorResponse(ctx, "Tenant not found", 404)
+		}
+		ctx.Locals(tenantKey, tenant)
+		return ctx.Next()
+	}
+}
+
+// RequireMemberRole пропускает пользователя арендатора запроса, если его роль участника
+// (consts.MemberRole*) входит в roles.
+func RequireMemberRole(tenants *services.TenantService, roles ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims := authenticate(ctx)
+		if claims == nil {
+			return unauthorized(ctx)
+		}
+		if claims.IsClientToken() || !slices.Contains(roles, tenants.MemberRole(Tenant(ctx).ID, claims.Sub)) {
+			return ErrorResponse(ctx, "Forbidden", 403)
+		}
+		return ctx.Next()
+	}
+}
+
+// Tenant возвращает арендатора, определённого ResolveTenant.
+func Tenant(ctx *fiber.Ctx) *models.Tenant {
+	tenant, _ := ctx.Locals(tenantKey).(*models.Tenant)
+	return tenant
+}
+
+func tenantFromPath(path string) string {
+	rest, ok := strings.CutPrefix(path, "/t/")
+	if !ok {
+		return ""
+	}
+	slug, _, _ := strings.Cut(rest, "/")
+	return slug
+}
+
+func hostname(ctx *fiber.Ctx) string {
+	host := ctx.Hostname()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+func unauthorized(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+	return ErrorResponse(ctx, "Unauthorized", 401)
+}
+
+// Authorizer проверяет роли и разрешения, записанные в access токен. Токен, выданный до
+// изменения ролей пользователя в арендаторе (claim pv отличается от текущей версии) или без pv,
+// отклоняется. Регистрируется после ResolveTenant.
+type Authorizer struct {
+	rbac *services.RBACService
+}
+
+func NewAuthorizer(rbac *services.RBACService) *Authorizer {
+	return &Authorizer{rbac: rbac}
+}
+
+// RequireRole пропускает запрос, если у пользователя есть хотя бы одна из ролей.
+func (a *Authorizer) RequireRole(roles ...string) fiber.Handler {
+	return a.require(func(claims *models.TokenClaims) bool {
+		return claims.HasAnyRole(roles...)
+	})
+}
+
+// RequirePermission пропускает запрос, если у пользователя есть все перечисленные разрешения.
+func (a *Authorizer) RequirePermission(permissions ...string) fiber.Handler {
+	return a.require(func(claims *models.TokenClaims) bool {
+		return claims.HasPermissions(permissions...)
+	})
+}
+
+func (a *Authorizer) require(allowed func(claims *models.TokenClaims) bool) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims := authenticate(ctx)
+		if claims == nil {
+			return unauthorized(ctx)
+		}
+		// токены клиентов не содержат pv и ролей и отклоняются проверкой ниже
+		if !claims.IsClientToken() && !a.rbac.IsCurrent(Tenant(ctx).ID, claims) {
+			ctx.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token", error_description="permissions changed"`)
+			return ErrorResponse(ctx, "Token permissions are outdated, refresh the token", 401)
+		}
+		if !allowed(claims) {
+			return ErrorResponse(ctx, "Forbidden", 403)
+		}
+		return ctx.Next()
+	}
+}
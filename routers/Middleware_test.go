@@ -0,0 +1,220 @@
+package routers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/repositories"
+	"auth-service/services"
+	"auth-service/testdb"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// accessToken подписывает access токен пользователя с claims поверх обязательных.
+func accessToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	config.GetConfig().Jwt.SecretKey = testSecret
+	base := jwt.MapClaims{
+		"sub": "11111111-1111-1111-1111-111111111111",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iss": "test",
+	}
+	for k, v := range claims {
+		base[k] = v
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS512, base).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// protected - приложение с одним маршрутом GET /, закрытым middleware.
+func protected(middleware ...fiber.Handler) *fiber.App {
+	app := fiber.New()
+	handlers := append(middleware, func(ctx *fiber.Ctx) error {
+		return ctx.SendString(Claims(ctx).Sub)
+	})
+	app.Get("/", handlers...)
+	return app
+}
+
+// get выполняет GET / с access токеном token и возвращает код и WWW-Authenticate ответа.
+func get(t *testing.T, app *fiber.App, token string) (int, string) {
+	t.Helper()
+	return getIn(t, app, "", token)
+}
+
+// getIn выполняет GET / в арендаторе tenant (заголовок X-Tenant).
+func getIn(t *testing.T, app *fiber.App, tenant, token string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/", nil)
+	if tenant != "" {
+		req.Header.Set("X-Tenant", tenant)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header.Get(fiber.HeaderWWWAuthenticate)
+}
+
+func TestRequireScopes(t *testing.T) {
+	app := protected(RequireScopes("users:read", "users:write"))
+	tests := []struct {
+		name   string
+		token  string
+		status int
+		header string
+	}{
+		{"no token", "", 401, `error="invalid_token"`},
+		{"bad signature", accessToken(t, nil) + "x", 401, `error="invalid_token"`},
+		{"no scope", accessToken(t, nil), 403, `error="insufficient_scope", scope="users:read users:write"`},
+		{"missing scope", accessToken(t, jwt.MapClaims{"scope": "users:read"}), 403, `error="insufficient_scope"`},
+		{"all scopes", accessToken(t, jwt.MapClaims{"scope": "openid users:write users:read"}), 200, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, header := get(t, app, tt.token)
+			if status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+			if !strings.Contains(header, tt.header) {
+				t.Errorf("WWW-Authenticate = %q, want %q", header, tt.header)
+			}
+		})
+	}
+}
+
+func TestRequireAudience(t *testing.T) {
+	app := protected(RequireAudience("https://api.example.com"))
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"no token", "", 401},
+		{"no audience", accessToken(t, nil), 401},
+		{"other audience", accessToken(t, jwt.MapClaims{"aud": []string{"https://other.example.com"}}), 401},
+		{"audience", accessToken(t, jwt.MapClaims{"aud": []string{"svc", "https://api.example.com"}}), 200},
+		{"single audience", accessToken(t, jwt.MapClaims{"aud": "https://api.example.com"}), 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, header := get(t, app, tt.token)
+			if status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+			if tt.status == 401 && tt.token != "" && !strings.Contains(header, "audience mismatch") {
+				t.Errorf("WWW-Authenticate = %q, want audience mismatch", header)
+			}
+		})
+	}
+}
+
+func TestRequireScopesAndAudience(t *testing.T) {
+	// middleware проверяют токен один раз, claims доступны обработчику через Claims
+	app := protected(RequireAudience("api"), RequireScopes("users:read"))
+	if status, _ := get(t, app, accessToken(t, jwt.MapClaims{"aud": "api", "scope": "users:read"})); status != 200 {
+		t.Errorf("status = %d, want 200", status)
+	}
+	if status, _ := get(t, app, accessToken(t, jwt.MapClaims{"aud": "api"})); status != 403 {
+		t.Errorf("without scope: status = %d, want 403", status)
+	}
+	if status, _ := get(t, app, accessToken(t, jwt.MapClaims{"scope": "users:read"})); status != 401 {
+		t.Errorf("without audience: status = %d, want 401", status)
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+	db := testdb.SQLite(t)
+	const guid = "11111111-1111-1111-1111-111111111111"
+	tenantRepo := repositories.NewTenantRepository(db)
+	users := repositories.NewUserRepository(db)
+	tenants := services.NewTenantService(tenantRepo, users, repositories.NewTokenRepository(db))
+	rbac := services.NewRBACService(repositories.NewRoleRepository(db))
+
+	defaultTenant, err := tenants.GetTenant("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &models.Tenant{Slug: "other", Name: "Other"}
+	if err := tenantRepo.Create(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Create(defaultTenant.ID, &models.User{Guid: guid, Name: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tenantRepo.AddMember(other.ID, guid, "member"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rbac.CreatePermission(models.PermissionRequest{Name: "docs:write"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rbac.CreateRole(models.RoleRequest{Name: "editor", Permissions: []string{"docs:write"}}); err != nil {
+		t.Fatal(err)
+	}
+	// роль назначена только в арендаторе other
+	authz, err := rbac.SetUserRoles(other.ID, guid, []string{"editor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := rbac.UserAuthorization(defaultTenant.ID, guid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authorizer := NewAuthorizer(rbac)
+	app := protected(ResolveTenant(tenants), authorizer.RequirePermission("docs:write"))
+	editor := jwt.MapClaims{"roles": []string{"editor"}, "permissions": []string{"docs:write"}}
+	withPv := func(claims jwt.MapClaims, tenant string, pv int64) jwt.MapClaims {
+		result := jwt.MapClaims{"tid": tenant, "pv": pv}
+		for k, v := range claims {
+			result[k] = v
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		token  string
+		status int
+	}{
+		{"no token", "other", "", 401},
+		{"current", "other", accessToken(t, withPv(editor, "other", authz.Version)), 200},
+		{"missing pv", "other", accessToken(t, jwt.MapClaims{"tid": "other", "roles": []string{"editor"}, "permissions": []string{"docs:write"}}), 401},
+		{"stale pv", "other", accessToken(t, withPv(editor, "other", authz.Version-1)), 401},
+		{"token of other tenant", "default", accessToken(t, withPv(editor, "other", authz.Version)), 401},
+		{"no role in tenant", "default", accessToken(t, withPv(nil, "default", current.Version)), 403},
+		{"client token", "other", accessToken(t, jwt.MapClaims{"sub": "svc", "client_id": "svc"}), 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := getIn(t, app, tt.tenant, tt.token); status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+		})
+	}
+
+	// роли в default не меняют версию членства в other
+	if _, err := rbac.SetUserRoles(defaultTenant.ID, guid, []string{"editor"}); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := getIn(t, app, "other", accessToken(t, withPv(editor, "other", authz.Version))); status != 200 {
+		t.Errorf("after role change in other tenant: status = %d, want 200", status)
+	}
+	if status, _ := getIn(t, app, "default", accessToken(t, withPv(editor, "default", current.Version))); status != 401 {
+		t.Errorf("after role change: status = %d, want 401", status)
+	}
+}
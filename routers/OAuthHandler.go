@@ -0,0 +1,567 @@
+package routers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/services"
+	"encoding/base64"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+var errInvalidRequest = errors.New("invalid request")
+
+type OAuthH struct {
+	clientService   *services.OAuthClientService
+	tokenService    *services.TokenService
+	authService     *services.AuthorizationService
+	oidcService     *services.OIDCService
+	userService     *services.UserService
+	deviceService   *services.DeviceService
+	exchangeService *services.TokenExchangeService
+}
+
+func NewOAuthHandler(
+	clientService *services.OAuthClientService,
+	tokenService *services.TokenService,
+	authService *services.AuthorizationService,
+	oidcService *services.OIDCService,
+	userService *services.UserService,
+	deviceService *services.DeviceService,
+	exchangeService *services.TokenExchangeService,
+) *OAuthH {
+	return &OAuthH{
+		clientService:   clientService,
+		tokenService:    tokenService,
+		authService:     authService,
+		oidcService:     oidcService,
+		userService:     userService,
+		deviceService:   deviceService,
+		exchangeService: exchangeService,
+	}
+}
+
+// Authorize godoc
+// @Summary Authorization endpoint (OAuth 2.0 / OpenID Connect)
+// @Description Выдаёт authorization code и перенаправляет на redirect_uri. Пользователь аутентифицируется access токеном этого сервиса (заголовок Authorization: Bearer или cookie access_token). Для публичных клиентов PKCE обязателен
+// @Tags OAuth
+// @Produce json
+// @Security ApiKeyAuth
+// @Param response_type query string true "code"
+// @Param client_id query string true "Идентификатор клиента"
+// @Param redirect_uri query string true "Зарегистрированный redirect_uri"
+// @Param scope query string false "Запрашиваемые scope через пробел (openid, profile, email, ...)"
+// @Param state query string false "Значение, возвращаемое клиенту без изменений"
+// @Param nonce query string false "Попадает в id_token"
+// @Param code_challenge query string false "PKCE code_challenge"
+// @Param code_challenge_method query string false "S256 или plain"
+// @Success 302
+// @Failure 400 {object} models.OAuthErrorResponse
+// @Router /oauth/authorize [get]
+func (h *OAuthH) Authorize(ctx *fiber.Ctx) error {
+	client, err := h.clientService.GetClient(ctx.Query("client_id"))
+	if err != nil {
+		return OAuthErrorResponse(ctx, "invalid_request", "unknown client_id", 400)
+	}
+	// на незарегистрированный redirect_uri ошибки не отправляются (RFC 6749, 4.1.2.1)
+	redirectURI := ctx.Query("redirect_uri")
+	if !client.AllowsRedirectURI(redirectURI) {
+		return OAuthErrorResponse(ctx, "invalid_request", "redirect_uri is not registered for this client", 400)
+	}
+	state := ctx.Query("state")
+
+	if ctx.Query("response_type") != "code" {
+		return authorizeRedirect(ctx, redirectURI, state, "error", "unsupported_response_type")
+	}
+	if !client.AllowsGrant(consts.GrantAuthorizationCode) {
+		return authorizeRedirect(ctx, redirectURI, state, "error", "unauthorized_client")
+	}
+	scopes, err := h.clientService.ResolveScopes(client, ctx.Query("scope"))
+	if err != nil {
+		return authorizeRedirect(ctx, redirectURI, state, "error", "invalid_scope")
+	}
+
+	claims, err := h.authenticateUser(ctx)
+	if err != nil {
+		return authorizeRedirect(ctx, redirectURI, state, "error", "login_required")
+	}
+
+	code, err := h.authService.CreateCode(services.AuthorizationRequest{
+		TenantID:            Tenant(ctx).ID,
+		Client:              client,
+		UserGuid:            claims.Sub,
+		RedirectURI:         redirectURI,
+		Scopes:              scopes,
+		Nonce:               ctx.Query("nonce"),
+		AuthTime:            claims.AuthenticatedAt(),
+		CodeChallenge:       ctx.Query("code_challenge"),
+		CodeChallengeMethod: ctx.Query("code_challenge_method"),
+	})
+	if errors.Is(err, services.ErrInvalidRequest) {
+		return authorizeRedirect(ctx, redirectURI, state, "error", "invalid_request")
+	}
+	if err != nil {
+		return authorizeRedirect(ctx, redirectURI, state, "error", "server_error")
+	}
+	return authorizeRedirect(ctx, redirectURI, state, "code", code)
+}
+
+// DeviceAuthorization godoc
+// @Summary Device authorization endpoint (RFC 8628)
+// @Description Выдаёт device_code и user_code для устройств без браузера. Пользователь подтверждает user_code на verification_uri, устройство опрашивает /oauth/token с grant_type urn:ietf:params:oauth:grant-type:device_code не чаще interval секунд
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param client_id formData string false "Идентификатор клиента"
+// @Param scope formData string false "Запрашиваемые scope через пробел"
+// @Success 200 {object} models.DeviceAuthorizationResponse
+// @Failure 400 {object} models.OAuthErrorResponse
+// @Failure 401 {object} models.OAuthErrorResponse
+// @Failure 500 {object} models.OAuthErrorResponse
+// @Router /oauth/device_authorization [post]
+func (h *OAuthH) DeviceAuthorization(ctx *fiber.Ctx) error {
+	client, err := h.authenticateClient(ctx)
+	if err != nil {
+		return h.clientError(ctx, err)
+	}
+	if !client.AllowsGrant(consts.GrantDeviceCode) {
+		return OAuthErrorResponse(ctx, "unauthorized_client", services.ErrUnauthorizedClient.Error(), 400)
+	}
+	scopes, err := h.clientService.ResolveScopes(client, ctx.FormValue("scope"))
+	if err != nil {
+		return OAuthErrorResponse(ctx, "invalid_scope", err.Error(), 400)
+	}
+
+	deviceCode, d, err := h.deviceService.Start(Tenant(ctx).ID, client, scopes)
+	if err != nil {
+		return OAuthErrorResponse(ctx, "server_error", "", 500)
+	}
+
+	userCode := services.FormatUserCode(d.UserCode)
+	verificationURI := publicBaseURL(ctx) + "/oauth/device"
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	return ctx.Status(http.StatusOK).JSON(models.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		ExpiresIn:               int64(time.Until(d.ExpiresAt).Seconds()),
+		Interval:                d.Interval,
+	})
+}
+
+// GetDeviceVerification godoc
+// @Summary Информация о запросе устройства
+// @Description Возвращает клиента и scope, которые запрашивает устройство по user_code. Требует access токен пользователя
+// @Tags OAuth
+// @Produce json
+// @Security ApiKeyAuth
+// @Param user_code query string true "Код, показанный устройством"
+// @Success 200 {object} models.DeviceVerificationResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /oauth/device [get]
+func (h *OAuthH) GetDeviceVerification(ctx *fiber.Ctx) error {
+	if _, err := h.authenticateUser(ctx); err != nil {
+		return ErrorResponse(ctx, "Unauthorized", 401)
+	}
+
+	d, client, err := h.deviceService.Lookup(Tenant(ctx).ID, ctx.Query("user_code"))
+	if err != nil {
+		return ErrorResponse(ctx, err.Error(), 404)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.DeviceVerificationResponse{
+		UserCode:   services.FormatUserCode(d.UserCode),
+		ClientID:   client.ClientID,
+		ClientName: client.Name,
+		Scopes:     d.Scopes,
+		Status:     d.Status,
+	})
+}
+
+// VerifyDevice godoc
+// @Summary Подтвердить или отклонить запрос устройства
+// @Description Пользователь, аутентифицированный access токеном, разрешает (approve = true) или запрещает устройству доступ по user_code
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body models.DeviceVerificationRequest true "user_code и решение пользователя"
+// @Success 200 {object} models.DeviceVerificationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /oauth/device [post]
+func (h *OAuthH) VerifyDevice(ctx *fiber.Ctx) error {
+	claims, err := h.authenticateUser(ctx)
+	if err != nil {
+		return ErrorResponse(ctx, "Unauthorized", 401)
+	}
+
+	var req models.DeviceVerificationRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	d, err := h.deviceService.Verify(Tenant(ctx).ID, req.UserCode, claims.Sub, claims.AuthenticatedAt(), req.Approve)
+	if errors.Is(err, services.ErrUserCodeNotFound) {
+		return ErrorResponse(ctx, err.Error(), 404)
+	}
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.DeviceVerificationResponse{
+		UserCode: services.FormatUserCode(d.UserCode),
+		ClientID: d.ClientID,
+		Scopes:   d.Scopes,
+		Status:   d.Status,
+	})
+}
+
+// Token godoc
+// @Summary Token endpoint (OAuth 2.0)
+// @Description Выдаёт токены по grant_type (client_credentials, authorization_code, urn:ietf:params:oauth:grant-type:device_code, urn:ietf:params:oauth:grant-type:token-exchange). Клиент аутентифицируется методом, указанным при регистрации: client_secret_basic, client_secret_post, private_key_jwt или none (публичный клиент, только client_id). При scope openid дополнительно выдаётся id_token. Refresh токен не выдаётся
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "Тип гранта"
+// @Param scope formData string false "Запрашиваемые scope через пробел"
+// @Param client_id formData string false "Идентификатор клиента"
+// @Param client_secret formData string false "Секрет клиента (client_secret_post)"
+// @Param client_assertion_type formData string false "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+// @Param client_assertion formData string false "Подписанный клиентом JWT (private_key_jwt)"
+// @Param code formData string false "Authorization code (authorization_code)"
+// @Param redirect_uri formData string false "redirect_uri, использованный при получении кода"
+// @Param code_verifier formData string false "PKCE code_verifier"
+// @Param device_code formData string false "device_code (device authorization grant)"
+// @Param subject_token formData string false "Токен субъекта (token exchange)"
+// @Param subject_token_type formData string false "urn:ietf:params:oauth:token-type:access_token"
+// @Param actor_token formData string false "Токен актора (token exchange)"
+// @Param actor_token_type formData string false "urn:ietf:params:oauth:token-type:access_token"
+// @Param audience formData string false "Целевая аудитория (token exchange)"
+// @Success 200 {object} models.OAuthTokenResponse
+// @Failure 400 {object} models.OAuthErrorResponse
+// @Failure 401 {object} models.OAuthErrorResponse
+// @Failure 500 {object} models.OAuthErrorResponse
+// @Router /oauth/token [post]
+func (h *OAuthH) Token(ctx *fiber.Ctx) error {
+	switch ctx.FormValue("grant_type") {
+	case consts.GrantClientCredentials:
+		return h.clientCredentials(ctx)
+	case consts.GrantAuthorizationCode:
+		return h.authorizationCode(ctx)
+	case consts.GrantDeviceCode:
+		return h.deviceCode(ctx)
+	case consts.GrantTokenExchange:
+		return h.tokenExchange(ctx)
+	case "":
+		return OAuthErrorResponse(ctx, "invalid_request", "grant_type is required", 400)
+	}
+	return OAuthErrorResponse(ctx, "unsupported_grant_type", "", 400)
+}
+
+func (h *OAuthH) clientCredentials(ctx *fiber.Ctx) error {
+	client, err := h.authenticateClient(ctx)
+	if err != nil {
+		return h.clientError(ctx, err)
+	}
+	if !client.AllowsGrant(consts.GrantClientCredentials) {
+		return OAuthErrorResponse(ctx, "unauthorized_client", services.ErrUnauthorizedClient.Error(), 400)
+	}
+
+	scopes, err := h.clientService.ResolveScopes(client, ctx.FormValue("scope"))
+	if err != nil {
+		return OAuthErrorResponse(ctx, "invalid_scope", err.Error(), 400)
+	}
+
+	access, lifetime, err := h.tokenService.GenerateClientToken(ctx.UserContext(), client, scopes)
+	if err != nil {
+		return OAuthErrorResponse(ctx, "server_error", "", 500)
+	}
+
+	return tokenResponse(ctx, models.OAuthTokenResponse{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(lifetime.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	})
+}
+
+func (h *OAuthH) authorizationCode(ctx *fiber.Ctx) error {
+	client, err := h.authenticateClient(ctx)
+	if err != nil {
+		return h.clientError(ctx, err)
+	}
+	if !client.AllowsGrant(consts.GrantAuthorizationCode) {
+		return OAuthErrorResponse(ctx, "unauthorized_client", services.ErrUnauthorizedClient.Error(), 400)
+	}
+
+	code, err := h.authService.ExchangeCode(
+		Tenant(ctx).ID,
+		ctx.FormValue("code"),
+		client.ClientID,
+		ctx.FormValue("redirect_uri"),
+		ctx.FormValue("code_verifier"),
+	)
+	if errors.Is(err, services.ErrInvalidGrant) {
+		return OAuthErrorResponse(ctx, "invalid_grant", err.Error(), 400)
+	}
+	if err != nil {
+		return OAuthErrorResponse(ctx, "server_error", "", 500)
+	}
+	return h.userTokens(ctx, client, userGrant{
+		UserGuid: code.UserGuid,
+		Scopes:   code.Scopes,
+		Nonce:    code.Nonce,
+		AuthTime: code.AuthTime,
+	})
+}
+
+func (h *OAuthH) deviceCode(ctx *fiber.Ctx) error {
+	client, err := h.authenticateClient(ctx)
+	if err != nil {
+		return h.clientError(ctx, err)
+	}
+	if !client.AllowsGrant(consts.GrantDeviceCode) {
+		return OAuthErrorResponse(ctx, "unauthorized_client", services.ErrUnauthorizedClient.Error(), 400)
+	}
+
+	d, err := h.deviceService.Poll(Tenant(ctx).ID, ctx.FormValue("device_code"), client.ClientID)
+	switch {
+	case errors.Is(err, services.ErrAuthorizationPending):
+		return OAuthErrorResponse(ctx, "authorization_pending", err.Error(), 400)
+	case errors.Is(err, services.ErrSlowDown):
+		return OAuthErrorResponse(ctx, "slow_down", err.Error(), 400)
+	case errors.Is(err, services.ErrExpiredToken):
+		return OAuthErrorResponse(ctx, "expired_token", err.Error(), 400)
+	case errors.Is(err, services.ErrAccessDenied):
+		return OAuthErrorResponse(ctx, "access_denied", err.Error(), 400)
+	case errors.Is(err, services.ErrInvalidGrant):
+		return OAuthErrorResponse(ctx, "invalid_grant", err.Error(), 400)
+	case err != nil:
+		return OAuthErrorResponse(ctx, "server_error", "", 500)
+	}
+
+	return h.userTokens(ctx, client, userGrant{
+		UserGuid: d.UserGuid,
+		Scopes:   d.Scopes,
+		AuthTime: d.AuthTime,
+	})
+}
+
+func (h *OAuthH) tokenExchange(ctx *fiber.Ctx) error {
+	client, err := h.authenticateClient(ctx)
+	if err != nil {
+		return h.clientError(ctx, err)
+	}
+	if !client.AllowsGrant(consts.GrantTokenExchange) {
+		return OAuthErrorResponse(ctx, "unauthorized_client", services.ErrUnauthorizedClient.Error(), 400)
+	}
+
+	access, lifetime, scopes, err := h.exchangeService.Exchange(ctx.UserContext(), client, services.ExchangeRequest{
+		Tenant:           Tenant(ctx),
+		SubjectToken:     ctx.FormValue("subject_token"),
+		SubjectTokenType: ctx.FormValue("subject_token_type"),
+		ActorToken:       ctx.FormValue("actor_token"),
+		ActorTokenType:   ctx.FormValue("actor_token_type"),
+		Audience:         formValues(ctx, "audience", "resource"),
+		Scope:            ctx.FormValue("scope"),
+	})
+	switch {
+	case errors.Is(err, services.ErrInvalidRequest):
+		return OAuthErrorResponse(ctx, "invalid_request", err.Error(), 400)
+	case errors.Is(err, services.ErrInvalidSubjectToken):
+		return OAuthErrorResponse(ctx, "invalid_grant", err.Error(), 400)
+	case errors.Is(err, services.ErrInvalidTarget):
+		return OAuthErrorResponse(ctx, "invalid_target", err.Error(), 400)
+	case errors.Is(err, services.ErrInvalidScope):
+		return OAuthErrorResponse(ctx, "invalid_scope", err.Error(), 400)
+	case errors.Is(err, services.ErrExchangeNotAllowed):
+		return OAuthErrorResponse(ctx, "unauthorized_client", err.Error(), 400)
+	case err != nil:
+		return OAuthErrorResponse(ctx, "server_error", "", 500)
+	}
+
+	return tokenResponse(ctx, models.OAuthTokenResponse{
+		AccessToken:     access,
+		IssuedTokenType: consts.TokenTypeAccessToken,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(lifetime.Seconds()),
+		Scope:           strings.Join(scopes, " "),
+	})
+}
+
+// userGrant - результат гранта, в котором пользователь разрешил клиенту доступ.
+type userGrant struct {
+	UserGuid string
+	Scopes   []string
+	Nonce    string
+	AuthTime time.Time
+}
+
+// userTokens выдаёт клиенту access токен пользователя и id_token при scope openid.
+// Коды и запросы устройств привязаны к арендатору, поэтому токены выдаются в арендаторе запроса.
+func (h *OAuthH) userTokens(ctx *fiber.Ctx, client *models.OAuthClient, grant userGrant) error {
+	tenant := Tenant(ctx)
+	if !h.userService.IsActive(tenant.ID, grant.UserGuid) {
+		return OAuthErrorResponse(ctx, "invalid_grant", services.ErrInvalidGrant.Error(), 400)
+	}
+
+	access, lifetime, err := h.tokenService.IssueUserAccessToken(ctx.UserContext(), services.AccessTokenParams{
+		Tenant:   tenant,
+		Subject:  grant.UserGuid,
+		ClientID: client.ClientID,
+		Scopes:   grant.Scopes,
+		Lifetime: client.AccessTokenTTL(0),
+		AuthTime: grant.AuthTime,
+	})
+	if err != nil {
+		return OAuthErrorResponse(ctx, "server_error", "", 500)
+	}
+
+	resp := models.OAuthTokenResponse{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(lifetime.Seconds()),
+		Scope:       strings.Join(grant.Scopes, " "),
+	}
+	if slices.Contains(grant.Scopes, consts.ScopeOpenID) {
+		resp.IDToken, err = h.oidcService.IDToken(services.IDTokenParams{
+			Tenant:      tenant,
+			UserGuid:    grant.UserGuid,
+			ClientID:    client.ClientID,
+			Nonce:       grant.Nonce,
+			AuthTime:    grant.AuthTime,
+			Scopes:      grant.Scopes,
+			AccessToken: access,
+		})
+		if err != nil {
+			return OAuthErrorResponse(ctx, "server_error", "", 500)
+		}
+	}
+	return tokenResponse(ctx, resp)
+}
+
+// authenticateUser проверяет access токен пользователя арендатора запроса.
+func (h *OAuthH) authenticateUser(ctx *fiber.Ctx) (*models.TokenClaims, error) {
+	claims := authenticate(ctx)
+	if claims == nil || claims.IsClientToken() || !h.userService.IsActive(Tenant(ctx).ID, claims.Sub) {
+		return nil, errors.New("user is not authenticated")
+	}
+	return claims, nil
+}
+
+func (h *OAuthH) authenticateClient(ctx *fiber.Ctx) (*models.OAuthClient, error) {
+	creds, err := parseClientCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	audiences := []string{
+		ctx.BaseURL() + "/oauth/token",
+		publicBaseURL(ctx) + "/oauth/token",
+		config.GetConfig().Jwt.Issuer,
+		h.tokenService.Issuer(Tenant(ctx)),
+	}
+	return h.clientService.Authenticate(creds, audiences)
+}
+
+func (h *OAuthH) clientError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, errInvalidRequest) {
+		return OAuthErrorResponse(ctx, "invalid_request", "client authentication is malformed", 400)
+	}
+	if strings.HasPrefix(ctx.Get(fiber.HeaderAuthorization), "Basic ") {
+		ctx.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+	return OAuthErrorResponse(ctx, "invalid_client", services.ErrInvalidClient.Error(), 401)
+}
+
+// formValues собирает все значения перечисленных параметров формы (параметры могут повторяться).
+func formValues(ctx *fiber.Ctx, keys ...string) []string {
+	var values []string
+	for _, key := range keys {
+		for _, v := range ctx.Request().PostArgs().PeekMulti(key) {
+			values = append(values, string(v))
+		}
+	}
+	return values
+}
+
+func tokenResponse(ctx *fiber.Ctx, resp models.OAuthTokenResponse) error {
+	ctx.Set(fiber.HeaderCacheControl, "no-store")
+	return ctx.Status(http.StatusOK).JSON(resp)
+}
+
+func authorizeRedirect(ctx *fiber.Ctx, redirectURI, state, key, value string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return OAuthErrorResponse(ctx, "invalid_request", "redirect_uri is malformed", 400)
+	}
+	q := u.Query()
+	q.Set(key, value)
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+	return ctx.Redirect(u.String(), http.StatusFound)
+}
+
+// parseClientCredentials определяет метод аутентификации клиента. Использование
+// нескольких методов в одном запросе запрещено (RFC 6749, 2.3).
+func parseClientCredentials(ctx *fiber.Ctx) (models.ClientCredentials, error) {
+	var creds models.ClientCredentials
+	methods := 0
+
+	if auth := ctx.Get(fiber.HeaderAuthorization); strings.HasPrefix(auth, "Basic ") {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+		if err != nil {
+			return creds, errInvalidRequest
+		}
+		id, secret, ok := strings.Cut(string(raw), ":")
+		if !ok {
+			return creds, errInvalidRequest
+		}
+		if creds.ClientID, err = url.QueryUnescape(id); err != nil {
+			return creds, errInvalidRequest
+		}
+		if creds.ClientSecret, err = url.QueryUnescape(secret); err != nil {
+			return creds, errInvalidRequest
+		}
+		creds.Method = consts.AuthMethodClientSecretBasic
+		methods++
+	}
+
+	if assertion := ctx.FormValue("client_assertion"); assertion != "" {
+		if ctx.FormValue("client_assertion_type") != consts.ClientAssertionTypeJwtBearer {
+			return creds, errInvalidRequest
+		}
+		creds.ClientID = ctx.FormValue("client_id")
+		creds.Assertion = assertion
+		creds.Method = consts.AuthMethodPrivateKeyJwt
+		methods++
+	}
+
+	if secret := ctx.FormValue("client_secret"); secret != "" {
+		creds.ClientID = ctx.FormValue("client_id")
+		creds.ClientSecret = secret
+		creds.Method = consts.AuthMethodClientSecretPost
+		methods++
+	}
+
+	switch {
+	case methods == 0 && ctx.FormValue("client_id") != "":
+		creds.ClientID = ctx.FormValue("client_id")
+		creds.Method = consts.AuthMethodNone
+	case methods == 0:
+		return creds, services.ErrInvalidClient
+	case methods > 1:
+		return creds, errInvalidRequest
+	}
+	return creds, nil
+}
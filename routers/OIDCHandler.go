@@ -0,0 +1,87 @@
+package routers
+
+import (
+	"auth-service/config"
+	"auth-service/models/consts"
+	"auth-service/services"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+type OIDCH struct {
+	oidcService *services.OIDCService
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService) *OIDCH {
+	return &OIDCH{oidcService: oidcService}
+}
+
+// Discovery godoc
+// @Summary OpenID Connect Discovery
+// @Description Метаданные провайдера. Адреса строятся от jwt.issuer, если это абсолютный URL, иначе от адреса запроса
+// @Tags OpenID Connect
+// @Produce json
+// @Success 200 {object} models.OpenIDConfiguration
+// @Router /.well-known/openid-configuration [get]
+func (h *OIDCH) Discovery(ctx *fiber.Ctx) error {
+	return ctx.Status(http.StatusOK).JSON(h.oidcService.Discovery(Tenant(ctx), publicBaseURL(ctx)))
+}
+
+// JWKS godoc
+// @Summary Публичные ключи подписи id_token
+// @Tags OpenID Connect
+// @Produce json
+// @Success 200 {object} models.Jwks
+// @Router /.well-known/jwks.json [get]
+func (h *OIDCH) JWKS(ctx *fiber.Ctx) error {
+	return ctx.Status(http.StatusOK).JSON(h.oidcService.JWKS())
+}
+
+// UserInfo godoc
+// @Summary UserInfo endpoint
+// @Description Возвращает claims пользователя по access токену со scope openid. Набор полей зависит от scope profile и email
+// @Tags OpenID Connect
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} models.UserInfoResponse
+// @Failure 401 {object} models.OAuthErrorResponse
+// @Failure 403 {object} models.OAuthErrorResponse
+// @Failure 404 {object} models.OAuthErrorResponse
+// @Router /userinfo [get]
+func (h *OIDCH) UserInfo(ctx *fiber.Ctx) error {
+	claims := authenticate(ctx)
+	if claims == nil || claims.IsClientToken() {
+		ctx.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return OAuthErrorResponse(ctx, "invalid_token", "", 401)
+	}
+	scopes := strings.Fields(claims.Scope)
+	if !slices.Contains(scopes, consts.ScopeOpenID) {
+		ctx.Set(fiber.HeaderWWWAuthenticate, `Bearer error="insufficient_scope", scope="openid"`)
+		return OAuthErrorResponse(ctx, "insufficient_scope", "", 403)
+	}
+
+	info, err := h.oidcService.UserInfo(Tenant(ctx), claims.Sub, scopes)
+	if err != nil {
+		return OAuthErrorResponse(ctx, "invalid_token", "user not found", 404)
+	}
+	return ctx.Status(http.StatusOK).JSON(info)
+}
+
+// publicBaseURL - внешний адрес сервиса для арендатора запроса: issuer арендатора (или jwt.issuer),
+// если это абсолютный URL, иначе адрес запроса с префиксом /t/{tenant}, если он использовался.
+func publicBaseURL(ctx *fiber.Ctx) string {
+	issuer := config.GetConfig().Jwt.Issuer
+	if tenant := Tenant(ctx); tenant != nil {
+		issuer = tenant.TokenIssuer(issuer)
+	}
+	if u, err := url.Parse(issuer); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return strings.TrimSuffix(issuer, "/")
+	}
+	if slug := tenantFromPath(ctx.Path()); slug != "" {
+		return ctx.BaseURL() + "/t/" + slug
+	}
+	return ctx.BaseURL()
+}
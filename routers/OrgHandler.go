@@ -0,0 +1,200 @@
+package routers
+
+import (
+	"auth-service/models"
+	"auth-service/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"net/http"
+	"net/url"
+)
+
+// OrgH - управление участниками арендатора запроса его владельцами и администраторами.
+type OrgH struct {
+	tenantService     *services.TenantService
+	invitationService *services.InvitationService
+}
+
+func NewOrgHandler(tenantService *services.TenantService, invitationService *services.InvitationService) *OrgH {
+	return &OrgH{tenantService: tenantService, invitationService: invitationService}
+}
+
+// GetMembers godoc
+// @Summary Участники организации
+// @Description Доступно владельцам и администраторам арендатора
+// @Tags Организация
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.MemberResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/org/members [get]
+func (h *OrgH) GetMembers(ctx *fiber.Ctx) error {
+	members, err := h.tenantService.TenantMembers(Tenant(ctx).ID)
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(members)
+}
+
+// SetMemberRole godoc
+// @Summary Изменить роль участника
+// @Description Назначать и снимать владельцев (owner) могут только владельцы, последний владелец не понижается
+// @Tags Организация
+// @Accept json
+// @Security ApiKeyAuth
+// @Param guid path string true "GUID участника"
+// @Param request body models.MemberRequest true "Роль: owner, admin, member"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/org/members/{guid} [put]
+func (h *OrgH) SetMemberRole(ctx *fiber.Ctx) error {
+	var req models.MemberRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	err := h.tenantService.ChangeMemberRole(Tenant(ctx).ID, Claims(ctx).Sub, ctx.Params("guid"), req.Role)
+	if err != nil {
+		return tenantErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// RemoveMember godoc
+// @Summary Исключить участника
+// @Description Участник теряет доступ к арендатору, его refresh токены в арендаторе удаляются
+// @Tags Организация
+// @Security ApiKeyAuth
+// @Param guid path string true "GUID участника"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/org/members/{guid} [delete]
+func (h *OrgH) RemoveMember(ctx *fiber.Ctx) error {
+	if err := h.tenantService.ExpelMember(ctx.UserContext(), Tenant(ctx).ID, Claims(ctx).Sub, ctx.Params("guid")); err != nil {
+		return tenantErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// Invite godoc
+// @Summary Пригласить пользователя
+// @Description Отправляет приглашение на email. Ссылка содержит подписанный токен, действительный 7 дней
+// @Tags Организация
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body models.InvitationRequest true "Email и роль приглашённого"
+// @Success 201 {object} models.InvitationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /api/org/invitations [post]
+func (h *OrgH) Invite(ctx *fiber.Ctx) error {
+	var req models.InvitationRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	i, err := h.invitationService.Invite(Tenant(ctx), Claims(ctx).Sub, req, acceptURL(ctx))
+	if err != nil {
+		return invitationErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusCreated).JSON(models.NewInvitationResponse(i))
+}
+
+// GetInvitations godoc
+// @Summary Ожидающие приглашения
+// @Tags Организация
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {array} models.InvitationResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/org/invitations [get]
+func (h *OrgH) GetInvitations(ctx *fiber.Ctx) error {
+	invitations, err := h.invitationService.Pending(Tenant(ctx).ID)
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(invitations)
+}
+
+// RevokeInvitation godoc
+// @Summary Отозвать приглашение
+// @Tags Организация
+// @Security ApiKeyAuth
+// @Param id path int true "ID приглашения"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/org/invitations/{id} [delete]
+func (h *OrgH) RevokeInvitation(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return ErrorResponse(ctx, "Not found", 404)
+	}
+	if err := h.invitationService.Revoke(Tenant(ctx).ID, uint(id)); err != nil {
+		return invitationErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// AcceptInvitation godoc
+// @Summary Принять приглашение
+// @Description Добавляет в арендатора пользователя с email приглашения или создаёт нового с именем name
+// @Tags Организация
+// @Accept json
+// @Produce json
+// @Param request body models.AcceptInvitationRequest true "Токен приглашения"
+// @Success 200 {object} models.AcceptInvitationResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/org/invitations/accept [post]
+func (h *OrgH) AcceptInvitation(ctx *fiber.Ctx) error {
+	var req models.AcceptInvitationRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	resp, err := h.invitationService.Accept(Tenant(ctx), req.Token, req.Name)
+	if err != nil {
+		return invitationErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(resp)
+}
+
+func invitationErrorResponse(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidEmail), errors.Is(err, services.ErrInvalidInvitation):
+		return ErrorResponse(ctx, err.Error(), 400)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrorResponse(ctx, "User is already a member", 409)
+	case errors.Is(err, services.ErrInvitationDelivery):
+		return ErrorResponse(ctx, services.ErrInvitationDelivery.Error(), 502)
+	}
+	return tenantErrorResponse(ctx, err)
+}
+
+// acceptURL - адрес принятия приглашения в арендаторе запроса. Без префикса /t/{tenant}
+// арендатор передаётся параметром tenant.
+func acceptURL(ctx *fiber.Ctx) string {
+	link := publicBaseURL(ctx) + "/api/org/invitations/accept"
+	if tenantFromPath(ctx.Path()) == "" {
+		link += "?tenant=" + url.QueryEscape(Tenant(ctx).Slug)
+	}
+	return link
+}
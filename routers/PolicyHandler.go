@@ -0,0 +1,105 @@
+package routers
+
+import (
+	"auth-service/models"
+	"auth-service/policy"
+	"auth-service/services"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type PolicyH struct {
+	policyService *services.PolicyService
+}
+
+func NewPolicyHandler(policyService *services.PolicyService) *PolicyH {
+	return &PolicyH{policyService: policyService}
+}
+
+// Authorize godoc
+// @Summary Решение о доступе
+// @Description Проверяет, разрешено ли субъекту действие над ресурсом, по политикам из policy.dir.
+// @Description Пользователь получает решение только для себя; OAuth клиент (client_credentials) может передать subject - GUID пользователя - и атрибуты окружения.
+// @Description В условиях доступны subject.* (guid, name, email, email_verified, roles, permissions), resource.*, action и environment.* (ip, user_agent, time, hour, weekday)
+// @Tags Авторизация
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param request body models.AuthorizeRequest true "Запрос решения"
+// @Success 200 {object} models.AuthorizeResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/authorize [post]
+func (h *PolicyH) Authorize(ctx *fiber.Ctx) error {
+	claims := authenticate(ctx)
+	if claims == nil {
+		return unauthorized(ctx)
+	}
+
+	var req models.AuthorizeRequest
+	if err := ctx.BodyParser(&req); err != nil || req.Action == "" {
+		return ErrorResponse(ctx, "action is required", 400)
+	}
+
+	env := requestEnvironment(ctx)
+	var subject map[string]interface{}
+	var err error
+	if claims.ClientID != "" && claims.Sub == claims.ClientID {
+		// клиент (resource server) спрашивает от имени своих пользователей и передаёт их окружение
+		env = mergeAttributes(env, req.Environment)
+		if req.Subject != "" {
+			subject, err = h.policyService.UserSubject(Tenant(ctx), req.Subject)
+		} else {
+			subject = h.policyService.ClientSubject(claims.ClientID, claims.Scopes())
+		}
+	} else {
+		if req.Subject != "" && req.Subject != claims.Sub {
+			return ErrorResponse(ctx, "subject must match the access token", 403)
+		}
+		env = mergeAttributes(req.Environment, env)
+		subject, err = h.policyService.UserSubject(Tenant(ctx), claims.Sub)
+	}
+	if err != nil {
+		return ErrorResponse(ctx, "User not found", 404)
+	}
+
+	d := h.policyService.Decide(policy.Request{
+		Subject:     subject,
+		Action:      req.Action,
+		Resource:    req.Resource,
+		Environment: env,
+	})
+	return ctx.Status(http.StatusOK).JSON(models.AuthorizeResponse{
+		Allowed: d.Allowed,
+		Effect:  d.Effect,
+		Policy:  d.Policy,
+		Rule:    d.Rule,
+	})
+}
+
+func requestEnvironment(ctx *fiber.Ctx) map[string]interface{} {
+	now := time.Now().UTC()
+	return map[string]interface{}{
+		"ip":         ctx.IP(),
+		"user_agent": ctx.Get(fiber.HeaderUserAgent),
+		"time":       now.Format(time.RFC3339),
+		"hour":       now.Hour(),
+		"weekday":    strings.ToLower(now.Weekday().String()),
+	}
+}
+
+// mergeAttributes возвращает base, дополненный override; значения override имеют приоритет.
+func mergeAttributes(base, override map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range override {
+		result[k] = v
+	}
+	return result
+}
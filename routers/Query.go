@@ -0,0 +1,43 @@
+package routers
+
+import (
+	"auth-service/models"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"time"
+)
+
+// listRequest читает общие параметры списков: sort, limit и cursor.
+func listRequest(ctx *fiber.Ctx) models.ListRequest {
+	return models.ListRequest{
+		Sort:   ctx.Query("sort"),
+		Limit:  ctx.QueryInt("limit"),
+		Cursor: ctx.Query("cursor"),
+	}
+}
+
+// queryBool - необязательный логический параметр: nil, если параметр не передан.
+func queryBool(ctx *fiber.Ctx, key string) (*bool, error) {
+	v := ctx.Query(key)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// queryTime - необязательный параметр-момент времени в RFC 3339.
+func queryTime(ctx *fiber.Ctx, key string) (*time.Time, error) {
+	v := ctx.Query(key)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,261 @@
+package routers
+
+import (
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type RoleH struct {
+	rbacService   *services.RBACService
+	tenantService *services.TenantService
+}
+
+func NewRoleHandler(rbacService *services.RBACService, tenantService *services.TenantService) *RoleH {
+	return &RoleH{rbacService: rbacService, tenantService: tenantService}
+}
+
+// CreatePermission godoc
+// @Summary Создать разрешение
+// @Tags Администрирование
+// @Accept json
+// @Produce json
+// @Security AdminKeyAuth
+// @Param request body models.PermissionRequest true "Разрешение"
+// @Success 201 {object} models.PermissionResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/permissions [post]
+func (h *RoleH) CreatePermission(ctx *fiber.Ctx) error {
+	var req models.PermissionRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	p, err := h.rbacService.CreatePermission(req)
+	if err != nil {
+		return roleErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusCreated).JSON(models.NewPermissionResponse(*p))
+}
+
+// GetPermissions godoc
+// @Summary Список разрешений
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Success 200 {array} models.PermissionResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/permissions [get]
+func (h *RoleH) GetPermissions(ctx *fiber.Ctx) error {
+	permissions, err := h.rbacService.GetPermissions()
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(permissions)
+}
+
+// DeletePermission godoc
+// @Summary Удалить разрешение
+// @Description Удаляет разрешение из всех ролей. Токены пользователей с этими ролями становятся устаревшими
+// @Tags Администрирование
+// @Security AdminKeyAuth
+// @Param name path string true "Имя разрешения"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/permissions/{name} [delete]
+func (h *RoleH) DeletePermission(ctx *fiber.Ctx) error {
+	if err := h.rbacService.DeletePermission(ctx.Params("name")); err != nil {
+		return roleErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// CreateRole godoc
+// @Summary Создать роль
+// @Tags Администрирование
+// @Accept json
+// @Produce json
+// @Security AdminKeyAuth
+// @Param request body models.RoleRequest true "Роль и её разрешения"
+// @Success 201 {object} models.RoleResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/roles [post]
+func (h *RoleH) CreateRole(ctx *fiber.Ctx) error {
+	var req models.RoleRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	role, err := h.rbacService.CreateRole(req)
+	if err != nil {
+		return roleErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusCreated).JSON(models.NewRoleResponse(role))
+}
+
+// GetRoles godoc
+// @Summary Список ролей
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Success 200 {array} models.RoleResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/roles [get]
+func (h *RoleH) GetRoles(ctx *fiber.Ctx) error {
+	roles, err := h.rbacService.GetRoles()
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(roles)
+}
+
+// GetRole godoc
+// @Summary Получить роль
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param name path string true "Имя роли"
+// @Success 200 {object} models.RoleResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/roles/{name} [get]
+func (h *RoleH) GetRole(ctx *fiber.Ctx) error {
+	role, err := h.rbacService.GetRole(ctx.Params("name"))
+	if err != nil {
+		return roleErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.NewRoleResponse(role))
+}
+
+// UpdateRole godoc
+// @Summary Изменить роль
+// @Description Заменяет описание и разрешения роли. Токены пользователей с этой ролью становятся устаревшими
+// @Tags Администрирование
+// @Accept json
+// @Produce json
+// @Security AdminKeyAuth
+// @Param name path string true "Имя роли"
+// @Param request body models.RoleRequest true "Роль и её разрешения"
+// @Success 200 {object} models.RoleResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/roles/{name} [put]
+func (h *RoleH) UpdateRole(ctx *fiber.Ctx) error {
+	var req models.RoleRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	role, err := h.rbacService.UpdateRole(ctx.Params("name"), req)
+	if err != nil {
+		return roleErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.NewRoleResponse(role))
+}
+
+// DeleteRole godoc
+// @Summary Удалить роль
+// @Description Снимает роль со всех пользователей. Их токены становятся устаревшими
+// @Tags Администрирование
+// @Security AdminKeyAuth
+// @Param name path string true "Имя роли"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/roles/{name} [delete]
+func (h *RoleH) DeleteRole(ctx *fiber.Ctx) error {
+	if err := h.rbacService.DeleteRole(ctx.Params("name")); err != nil {
+		return roleErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// GetUserRoles godoc
+// @Summary Роли пользователя
+// @Description Возвращает роли, разрешения и текущую версию разрешений пользователя в арендаторе
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param guid path string true "GUID пользователя"
+// @Param tenant query string false "Арендатор, по умолчанию default"
+// @Success 200 {object} models.UserRolesResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{guid}/roles [get]
+func (h *RoleH) GetUserRoles(ctx *fiber.Ctx) error {
+	tenant, err := h.tenantService.GetTenant(ctx.Query("tenant", consts.DefaultTenant))
+	if err != nil {
+		return ErrorResponse(ctx, "Tenant not found", 404)
+	}
+
+	guid := ctx.Params("guid")
+	authz, err := h.rbacService.UserAuthorization(tenant.ID, guid)
+	if err != nil {
+		return roleErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.NewUserRolesResponse(guid, authz))
+}
+
+// SetUserRoles godoc
+// @Summary Назначить роли пользователю
+// @Description Заменяет роли участника арендатора. Выданные ранее в арендаторе токены отклоняются RequireRole/RequirePermission до обновления
+// @Tags Администрирование
+// @Accept json
+// @Produce json
+// @Security AdminKeyAuth
+// @Param guid path string true "GUID пользователя"
+// @Param tenant query string false "Арендатор, по умолчанию default"
+// @Param request body models.UserRolesRequest true "Роли пользователя"
+// @Success 200 {object} models.UserRolesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{guid}/roles [put]
+func (h *RoleH) SetUserRoles(ctx *fiber.Ctx) error {
+	var req models.UserRolesRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	tenant, err := h.tenantService.GetTenant(ctx.Query("tenant", consts.DefaultTenant))
+	if err != nil {
+		return ErrorResponse(ctx, "Tenant not found", 404)
+	}
+
+	guid := ctx.Params("guid")
+	authz, err := h.rbacService.SetUserRoles(tenant.ID, guid, req.Roles)
+	if err != nil {
+		return roleErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.NewUserRolesResponse(guid, authz))
+}
+
+func roleErrorResponse(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrorResponse(ctx, "Not found", 404)
+	case errors.Is(err, services.ErrUserNotFound):
+		return ErrorResponse(ctx, "User not found", 404)
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrInvalidPermission):
+		return ErrorResponse(ctx, err.Error(), 400)
+	}
+	return ErrorResponse(ctx, "Internal Server Error", 500)
+}
@@ -0,0 +1,206 @@
+package routers
+
+import (
+	"auth-service/models"
+	"auth-service/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type TenantH struct {
+	tenantService *services.TenantService
+}
+
+func NewTenantHandler(tenantService *services.TenantService) *TenantH {
+	return &TenantH{tenantService: tenantService}
+}
+
+// CreateTenant godoc
+// @Summary Создать арендатора
+// @Tags Администрирование
+// @Accept json
+// @Produce json
+// @Security AdminKeyAuth
+// @Param request body models.TenantRequest true "Настройки арендатора"
+// @Success 201 {object} models.TenantResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/tenants [post]
+func (h *TenantH) CreateTenant(ctx *fiber.Ctx) error {
+	var req models.TenantRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	t, err := h.tenantService.Create(req)
+	if err != nil {
+		return tenantErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusCreated).JSON(models.NewTenantResponse(t))
+}
+
+// GetTenants godoc
+// @Summary Список арендаторов
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Success 200 {array} models.TenantResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/tenants [get]
+func (h *TenantH) GetTenants(ctx *fiber.Ctx) error {
+	tenants, err := h.tenantService.GetTenants()
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(tenants)
+}
+
+// GetTenant godoc
+// @Summary Получить арендатора
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param slug path string true "Идентификатор арендатора"
+// @Success 200 {object} models.TenantResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/admin/tenants/{slug} [get]
+func (h *TenantH) GetTenant(ctx *fiber.Ctx) error {
+	t, err := h.tenantService.GetTenant(ctx.Params("slug"))
+	if err != nil {
+		return tenantErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.NewTenantResponse(t))
+}
+
+// UpdateTenant godoc
+// @Summary Изменить арендатора
+// @Description Заменяет настройки арендатора. Slug изменить нельзя
+// @Tags Администрирование
+// @Accept json
+// @Produce json
+// @Security AdminKeyAuth
+// @Param slug path string true "Идентификатор арендатора"
+// @Param request body models.TenantRequest true "Настройки арендатора"
+// @Success 200 {object} models.TenantResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/tenants/{slug} [put]
+func (h *TenantH) UpdateTenant(ctx *fiber.Ctx) error {
+	var req models.TenantRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ErrorResponse(ctx, "invalid request body", 400)
+	}
+
+	t, err := h.tenantService.Update(ctx.Params("slug"), req)
+	if err != nil {
+		return tenantErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.NewTenantResponse(t))
+}
+
+// DeleteTenant godoc
+// @Summary Удалить арендатора
+// @Description Удаляет арендатора, членство пользователей в нём и его refresh токены
+// @Tags Администрирование
+// @Security AdminKeyAuth
+// @Param slug path string true "Идентификатор арендатора"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/tenants/{slug} [delete]
+func (h *TenantH) DeleteTenant(ctx *fiber.Ctx) error {
+	if err := h.tenantService.Delete(ctx.UserContext(), ctx.Params("slug")); err != nil {
+		return tenantErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// GetMembers godoc
+// @Summary Пользователи арендатора
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param slug path string true "Идентификатор арендатора"
+// @Success 200 {array} models.MemberResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/tenants/{slug}/members [get]
+func (h *TenantH) GetMembers(ctx *fiber.Ctx) error {
+	users, err := h.tenantService.Members(ctx.Params("slug"))
+	if err != nil {
+		return tenantErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(users)
+}
+
+// AddMember godoc
+// @Summary Добавить пользователя в арендатора
+// @Description Добавляет пользователя с ролью участника (по умолчанию member) или меняет роль участника
+// @Tags Администрирование
+// @Accept json
+// @Security AdminKeyAuth
+// @Param slug path string true "Идентификатор арендатора"
+// @Param guid path string true "GUID пользователя"
+// @Param request body models.MemberRequest false "Роль участника: owner, admin, member"
+// @Success 204
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/tenants/{slug}/members/{guid} [put]
+func (h *TenantH) AddMember(ctx *fiber.Ctx) error {
+	var req models.MemberRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			return ErrorResponse(ctx, "invalid request body", 400)
+		}
+	}
+
+	if err := h.tenantService.AddMember(ctx.Params("slug"), ctx.Params("guid"), req.Role); err != nil {
+		return tenantErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// RemoveMember godoc
+// @Summary Исключить пользователя из арендатора
+// @Description Refresh токены пользователя в этом арендаторе удаляются
+// @Tags Администрирование
+// @Security AdminKeyAuth
+// @Param slug path string true "Идентификатор арендатора"
+// @Param guid path string true "GUID пользователя"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/tenants/{slug}/members/{guid} [delete]
+func (h *TenantH) RemoveMember(ctx *fiber.Ctx) error {
+	if err := h.tenantService.RemoveMember(ctx.UserContext(), ctx.Params("slug"), ctx.Params("guid")); err != nil {
+		return tenantErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+func tenantErrorResponse(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrorResponse(ctx, "Not found", 404)
+	case errors.Is(err, services.ErrInvalidTenantMetadata), errors.Is(err, services.ErrInvalidMemberRole):
+		return ErrorResponse(ctx, err.Error(), 400)
+	case errors.Is(err, services.ErrOwnerRequired):
+		return ErrorResponse(ctx, err.Error(), 403)
+	case errors.Is(err, services.ErrLastOwner):
+		return ErrorResponse(ctx, err.Error(), 409)
+	}
+	return ErrorResponse(ctx, "Internal Server Error", 500)
+}
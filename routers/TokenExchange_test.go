@@ -0,0 +1,370 @@
+package routers
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/repositories"
+	"auth-service/services"
+	"auth-service/testdb"
+	"context"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const exchangeUser = "11111111-1111-1111-1111-111111111111"
+
+// exchangeEnv - /oauth/token и /api/logout поверх тестовой базы с пользователем exchangeUser в арендаторе по умолчанию.
+type exchangeEnv struct {
+	app     *fiber.App
+	tokens  *services.TokenService
+	clients *services.OAuthClientService
+	tenants repositories.TenantRepository
+	rbac    *services.RBACService
+	tenant  *models.Tenant
+}
+
+func newExchangeEnv(t *testing.T) *exchangeEnv {
+	t.Helper()
+	db := testdb.SQLite(t)
+	config.GetConfig().Jwt.SecretKey = testSecret
+	c := *config.GetConfig()
+
+	users := repositories.NewUserRepository(db)
+	tenantRepo := repositories.NewTenantRepository(db)
+	sessions := repositories.NewTokenRepository(db)
+	tenants := services.NewTenantService(tenantRepo, users, sessions)
+	rbac := services.NewRBACService(repositories.NewRoleRepository(db))
+	audit := services.NewAuditService(repositories.NewAuditRepository(db))
+	webhooks := services.NewWebhookService(repositories.NewWebhookRepository(db), func() {}, c)
+	tokens := services.NewTokenService(sessions, rbac, audit, webhooks, c)
+	clients := services.NewOAuthClientService(repositories.NewOAuthClientRepository(db))
+	handler := NewOAuthHandler(clients, tokens, nil, nil, nil, nil, services.NewTokenExchangeService(tokens, c))
+	tokenHandler := NewTokenHandler(tokens, services.NewUserService(users, sessions), clients, audit, webhooks)
+
+	tenant, err := tenants.GetTenant("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Create(tenant.ID, &models.User{Guid: exchangeUser, Name: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	app.Use(ResolveTenant(tenants))
+	app.Post("/oauth/token", handler.Token)
+	app.Post("/api/logout", tokenHandler.Logout)
+	return &exchangeEnv{app: app, tokens: tokens, clients: clients, tenants: tenantRepo, rbac: rbac, tenant: tenant}
+}
+
+// client регистрирует клиента с грантом token exchange и возвращает его client_id и секрет.
+func (e *exchangeEnv) client(t *testing.T, req models.OAuthClientRequest) (string, string) {
+	t.Helper()
+	req.GrantTypes = []string{consts.GrantTokenExchange}
+	client, secret, err := e.clients.Register(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.ClientID, secret
+}
+
+// userToken выдаёт клиенту clientID access токен пользователя exchangeUser, как это делает authorization_code.
+func (e *exchangeEnv) userToken(t *testing.T, clientID string, scopes []string, lifetime time.Duration) string {
+	t.Helper()
+	authz, err := e.rbac.UserAuthorization(e.tenant.ID, exchangeUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := e.tokens.IssueAccessToken(context.Background(), services.AccessTokenParams{
+		Tenant:        e.tenant,
+		Subject:       exchangeUser,
+		ClientID:      clientID,
+		Scopes:        scopes,
+		Authorization: authz,
+		Lifetime:      lifetime,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// exchange обменивает subject токен от имени клиента id:secret в арендаторе tenant.
+// Возвращает код ответа, ответ token endpoint'а и код ошибки OAuth.
+func (e *exchangeEnv) exchange(t *testing.T, tenant, id, secret string, form url.Values) (int, models.OAuthTokenResponse, string) {
+	t.Helper()
+	form.Set("grant_type", consts.GrantTokenExchange)
+	if form.Get("subject_token_type") == "" {
+		form.Set("subject_token_type", consts.TokenTypeAccessToken)
+	}
+	req := httptest.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Tenant", tenant)
+	req.SetBasicAuth(id, secret)
+	resp, err := e.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		models.OAuthTokenResponse
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body.OAuthTokenResponse, body.Error
+}
+
+// exchangedClaims разбирает выданный токен.
+func exchangedClaims(t *testing.T, token string) jwt.MapClaims {
+	t.Helper()
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) { return []byte(testSecret), nil }); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestExchangeRecordsActorChain(t *testing.T) {
+	e := newExchangeEnv(t)
+	backend, backendSecret := e.client(t, models.OAuthClientRequest{Name: "backend", Scopes: []string{"docs:read"}, ExchangeAudiences: []string{"storage"}})
+	frontend, frontendSecret := e.client(t, models.OAuthClientRequest{Name: "frontend", Scopes: []string{"docs:read"}, ExchangeAudiences: []string{backend}})
+	subject := e.userToken(t, frontend, []string{"docs:read"}, time.Hour)
+
+	// frontend обменивает токен пользователя на токен для backend
+	status, first, errCode := e.exchange(t, "default", frontend, frontendSecret, url.Values{
+		"subject_token": {subject},
+		"audience":      {backend},
+	})
+	if status != 200 {
+		t.Fatalf("first exchange: status = %d (%s), want 200", status, errCode)
+	}
+	if first.IssuedTokenType != consts.TokenTypeAccessToken {
+		t.Errorf("issued_token_type = %q", first.IssuedTokenType)
+	}
+	claims := exchangedClaims(t, first.AccessToken)
+	if claims["sub"] != exchangeUser || claims["tid"] != "default" {
+		t.Errorf("first exchange: sub = %v, tid = %v", claims["sub"], claims["tid"])
+	}
+	if want := map[string]any{"sub": frontend}; !reflect.DeepEqual(claims["act"], want) {
+		t.Errorf("first exchange: act = %v, want %v", claims["act"], want)
+	}
+
+	// backend - аудитория обменянного токена, поэтому может обменять его дальше;
+	// новый актор записывается поверх цепочки
+	status, second, errCode := e.exchange(t, "default", backend, backendSecret, url.Values{
+		"subject_token": {first.AccessToken},
+		"audience":      {"storage"},
+	})
+	if status != 200 {
+		t.Fatalf("second exchange: status = %d (%s), want 200", status, errCode)
+	}
+	claims = exchangedClaims(t, second.AccessToken)
+	want := map[string]any{"sub": backend, "act": map[string]any{"sub": frontend}}
+	if !reflect.DeepEqual(claims["act"], want) {
+		t.Errorf("second exchange: act = %v, want %v", claims["act"], want)
+	}
+	if claims["sub"] != exchangeUser || claims["client_id"] != backend {
+		t.Errorf("second exchange: sub = %v, client_id = %v", claims["sub"], claims["client_id"])
+	}
+
+	// actor_token самого клиента допускается, чужой - нет
+	backendClient, err := e.clients.GetClient(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	own, _, err := e.tokens.GenerateClientToken(context.Background(), backendClient, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, third, errCode := e.exchange(t, "default", backend, backendSecret, url.Values{
+		"subject_token":    {first.AccessToken},
+		"audience":         {"storage"},
+		"actor_token":      {own},
+		"actor_token_type": {consts.TokenTypeAccessToken},
+	})
+	if status != 200 {
+		t.Fatalf("own actor_token: status = %d (%s), want 200", status, errCode)
+	}
+	if claims := exchangedClaims(t, third.AccessToken); !reflect.DeepEqual(claims["act"], want) {
+		t.Errorf("own actor_token: act = %v, want %v", claims["act"], want)
+	}
+	status, _, errCode = e.exchange(t, "default", backend, backendSecret, url.Values{
+		"subject_token":    {first.AccessToken},
+		"audience":         {"storage"},
+		"actor_token":      {subject},
+		"actor_token_type": {consts.TokenTypeAccessToken},
+	})
+	if status != 400 || errCode != "unauthorized_client" {
+		t.Errorf("foreign actor_token: status = %d, error = %q; want 400 unauthorized_client", status, errCode)
+	}
+}
+
+func TestExchangeRejectsOtherTenant(t *testing.T) {
+	e := newExchangeEnv(t)
+	id, secret := e.client(t, models.OAuthClientRequest{Scopes: []string{"docs:read"}, ExchangeAudiences: []string{"backend"}})
+	other := &models.Tenant{Slug: "other", Name: "Other"}
+	if err := e.tenants.Create(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.tenants.AddMember(other.ID, exchangeUser, "member"); err != nil {
+		t.Fatal(err)
+	}
+	subject := e.userToken(t, id, []string{"docs:read"}, time.Hour)
+
+	// токен арендатора default не обменивается в арендаторе other, даже если пользователь - его участник
+	status, _, errCode := e.exchange(t, "other", id, secret, url.Values{
+		"subject_token": {subject},
+		"audience":      {"backend"},
+	})
+	if status != 400 || errCode != "invalid_grant" {
+		t.Errorf("other tenant: status = %d, error = %q; want 400 invalid_grant", status, errCode)
+	}
+	if status, _, errCode := e.exchange(t, "default", id, secret, url.Values{
+		"subject_token": {subject},
+		"audience":      {"backend"},
+	}); status != 200 {
+		t.Errorf("own tenant: status = %d (%s), want 200", status, errCode)
+	}
+}
+
+func TestExchangeImpersonation(t *testing.T) {
+	e := newExchangeEnv(t)
+	frontend, _ := e.client(t, models.OAuthClientRequest{Scopes: []string{"docs:read"}})
+	plain, plainSecret := e.client(t, models.OAuthClientRequest{Scopes: []string{"docs:read"}, ExchangeAudiences: []string{"backend"}})
+	impersonator, impersonatorSecret := e.client(t, models.OAuthClientRequest{Scopes: []string{"docs:read"}, ExchangeAudiences: []string{"backend"}, AllowImpersonation: true})
+	subject := e.userToken(t, frontend, []string{"docs:read"}, time.Hour)
+	form := func() url.Values {
+		return url.Values{"subject_token": {subject}, "audience": {"backend"}}
+	}
+
+	// токен выдан frontend: без token_exchange_impersonation чужой клиент его не обменивает
+	status, _, errCode := e.exchange(t, "default", plain, plainSecret, form())
+	if status != 400 || errCode != "unauthorized_client" {
+		t.Errorf("without impersonation: status = %d, error = %q; want 400 unauthorized_client", status, errCode)
+	}
+
+	status, resp, errCode := e.exchange(t, "default", impersonator, impersonatorSecret, form())
+	if status != 200 {
+		t.Fatalf("with impersonation: status = %d (%s), want 200", status, errCode)
+	}
+	claims := exchangedClaims(t, resp.AccessToken)
+	if claims["sub"] != exchangeUser || claims["client_id"] != impersonator {
+		t.Errorf("with impersonation: sub = %v, client_id = %v", claims["sub"], claims["client_id"])
+	}
+	if want := map[string]any{"sub": impersonator}; !reflect.DeepEqual(claims["act"], want) {
+		t.Errorf("with impersonation: act = %v, want %v", claims["act"], want)
+	}
+}
+
+func TestExchangeNarrowsAudienceAndScope(t *testing.T) {
+	e := newExchangeEnv(t)
+	id, secret := e.client(t, models.OAuthClientRequest{
+		Scopes:            []string{"docs:read", "docs:write"},
+		ExchangeAudiences: []string{"backend", "storage"},
+	})
+	subject := e.userToken(t, id, []string{"docs:read", "profile"}, time.Hour)
+
+	tests := []struct {
+		name     string
+		audience []string
+		scope    string
+		status   int
+		errCode  string
+		wantAud  []any
+		wantScp  string
+	}{
+		{name: "no audience", status: 400, errCode: "invalid_target"},
+		{name: "unknown audience", audience: []string{"backend", "billing"}, status: 400, errCode: "invalid_target"},
+		// scope - пересечение scope субъекта и клиента: profile нет у клиента, docs:write - у субъекта
+		{name: "default scope", audience: []string{"backend"}, status: 200, wantAud: []any{"backend"}, wantScp: "docs:read"},
+		{name: "narrowed scope", audience: []string{"backend", "storage"}, scope: "docs:read", status: 200, wantAud: []any{"backend", "storage"}, wantScp: "docs:read"},
+		{name: "scope of client only", audience: []string{"backend"}, scope: "docs:write", status: 400, errCode: "invalid_scope"},
+		{name: "scope of subject only", audience: []string{"backend"}, scope: "profile", status: 400, errCode: "invalid_scope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"subject_token": {subject}, "audience": tt.audience}
+			if tt.scope != "" {
+				form.Set("scope", tt.scope)
+			}
+			status, resp, errCode := e.exchange(t, "default", id, secret, form)
+			if status != tt.status || errCode != tt.errCode {
+				t.Fatalf("status = %d, error = %q; want %d %q", status, errCode, tt.status, tt.errCode)
+			}
+			if status != 200 {
+				return
+			}
+			claims := exchangedClaims(t, resp.AccessToken)
+			if !reflect.DeepEqual(claims["aud"], tt.wantAud) {
+				t.Errorf("aud = %v, want %v", claims["aud"], tt.wantAud)
+			}
+			if claims["scope"] != tt.wantScp || resp.Scope != tt.wantScp {
+				t.Errorf("scope = %v (response %q), want %q", claims["scope"], resp.Scope, tt.wantScp)
+			}
+		})
+	}
+}
+
+func TestExchangeLifetimeCap(t *testing.T) {
+	e := newExchangeEnv(t)
+	long, longSecret := e.client(t, models.OAuthClientRequest{ExchangeAudiences: []string{"backend"}, AccessTokenLifetime: 3600})
+	short, shortSecret := e.client(t, models.OAuthClientRequest{ExchangeAudiences: []string{"backend"}, AccessTokenLifetime: 60})
+	form := func(subject string) url.Values {
+		return url.Values{"subject_token": {subject}, "audience": {"backend"}}
+	}
+
+	// обменянный токен не переживает исходный
+	subject := e.userToken(t, long, nil, 2*time.Minute)
+	status, resp, errCode := e.exchange(t, "default", long, longSecret, form(subject))
+	if status != 200 {
+		t.Fatalf("long client: status = %d (%s), want 200", status, errCode)
+	}
+	if resp.ExpiresIn > 120 || resp.ExpiresIn < 110 {
+		t.Errorf("long client: expires_in = %d, want at most the subject's 120", resp.ExpiresIn)
+	}
+	subjectExp := exchangedClaims(t, subject)["exp"].(float64)
+	if exp := exchangedClaims(t, resp.AccessToken)["exp"].(float64); exp > subjectExp {
+		t.Errorf("long client: exp = %v after subject exp %v", exp, subjectExp)
+	}
+
+	// и не живёт дольше времени жизни токенов клиента
+	subject = e.userToken(t, short, nil, time.Hour)
+	status, resp, errCode = e.exchange(t, "default", short, shortSecret, form(subject))
+	if status != 200 {
+		t.Fatalf("short client: status = %d (%s), want 200", status, errCode)
+	}
+	if resp.ExpiresIn != 60 {
+		t.Errorf("short client: expires_in = %d, want 60", resp.ExpiresIn)
+	}
+}
+
+func TestExchangeRejectsLoggedOutToken(t *testing.T) {
+	e := newExchangeEnv(t)
+	id, secret := e.client(t, models.OAuthClientRequest{ExchangeAudiences: []string{"backend"}, AllowImpersonation: true})
+	access, refresh, err := e.tokens.GenerateTokens(context.Background(), services.Session{Tenant: e.tenant, UserGuid: exchangeUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := func() url.Values {
+		return url.Values{"subject_token": {access}, "audience": {"backend"}}
+	}
+
+	if status, _, errCode := e.exchange(t, "default", id, secret, form()); status != 200 {
+		t.Fatalf("before logout: status = %d (%s), want 200", status, errCode)
+	}
+	// выход не меняет pv: access токен отзывается только через denylist
+	if status := post(t, e.app, "/api/logout", access, refresh); status != 200 {
+		t.Fatalf("logout: status = %d, want 200", status)
+	}
+	status, _, errCode := e.exchange(t, "default", id, secret, form())
+	if status != 400 || errCode != "invalid_grant" {
+		t.Errorf("after logout: status = %d, error = %q; want 400 invalid_grant", status, errCode)
+	}
+}
@@ -0,0 +1,65 @@
+package routers
+
+import (
+	"auth-service/tracing"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing начинает span запроса, продолжая трассу из заголовка traceparent, и кладёт его
+// в ctx.UserContext(): оттуда контекст получают сервисы и хранилища.
+func Tracing() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		method := utils.CopyString(ctx.Method())
+		parent := otel.GetTextMapPropagator().Extract(ctx.UserContext(), headerCarrier{&ctx.Request().Header})
+		spanCtx, span := tracing.Start(parent, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.URLPath(utils.CopyString(ctx.Path())),
+			),
+		)
+		defer span.End()
+		ctx.SetUserContext(spanCtx)
+
+		err := ctx.Next()
+
+		status := responseStatus(ctx, err)
+		if err != nil {
+			span.RecordError(err)
+		}
+		route := ctx.Route().Path
+		span.SetName(method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, utils.StatusMessage(status))
+		}
+		return err
+	}
+}
+
+// headerCarrier - заголовки запроса fasthttp для propagation.TextMapPropagator.
+type headerCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (c headerCarrier) Get(key string) string {
+	return string(c.header.Peek(key))
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c.header.Set(key, value)
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := []string{}
+	c.header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
@@ -0,0 +1,150 @@
+package routers
+
+import (
+	"auth-service/models"
+	"auth-service/repositories"
+	"auth-service/services"
+	"auth-service/testdb"
+	"auth-service/tracing"
+	"context"
+	"encoding/json"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"gorm.io/gorm"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// recordSpans устанавливает провайдер, записывающий span'ы в память, и подключает GormPlugin к db.
+func recordSpans(t *testing.T, db *gorm.DB) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Install(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { tracing.Install(noop.NewTracerProvider()) })
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+	return exporter
+}
+
+func attr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	db := testdb.SQLite(t)
+	app, tokens, tenant := tokenApp(t, db, Tracing())
+	exporter := recordSpans(t, db)
+	const guid = "11111111-1111-1111-1111-111111111111"
+	if err := repositories.NewUserRepository(db).Create(tenant.ID, &models.User{Guid: guid, Name: "user"}); err != nil {
+		t.Fatal(err)
+	}
+	access, _, err := tokens.GenerateTokens(context.Background(), services.Session{Tenant: tenant, UserGuid: guid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter.Reset()
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+	body, _ := json.Marshal(models.TokenRequest{AccessToken: access})
+	req := httptest.NewRequest("POST", "/api/me", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceID+"-"+spanID+"-01")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	spans := exporter.GetSpans()
+	var server *tracetest.SpanStub
+	parents := map[trace.SpanID]trace.SpanID{}
+	for i, span := range spans {
+		parents[span.SpanContext.SpanID()] = span.Parent.SpanID()
+		if span.SpanKind == trace.SpanKindServer {
+			server = &spans[i]
+		}
+	}
+	if server == nil {
+		t.Fatalf("no server span among %d spans", len(spans))
+	}
+	if server.Name != "POST /api/me" {
+		t.Errorf("server span name = %q, want %q", server.Name, "POST /api/me")
+	}
+	if route := attr(*server, semconv.HTTPRouteKey).AsString(); route != "/api/me" {
+		t.Errorf("http.route = %q, want /api/me", route)
+	}
+	if status := attr(*server, semconv.HTTPResponseStatusCodeKey).AsInt64(); status != 200 {
+		t.Errorf("http.response.status_code = %d, want 200", status)
+	}
+	// трасса продолжает входящий traceparent
+	if got := server.SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("trace id = %s, want %s", got, traceID)
+	}
+	if got := server.Parent.SpanID().String(); got != spanID || !server.Parent.IsRemote() {
+		t.Errorf("parent = %s (remote %v), want remote %s", got, server.Parent.IsRemote(), spanID)
+	}
+
+	// запросы к базе - дочерние span'ы запроса
+	queries := 0
+	for _, span := range spans {
+		if attr(span, semconv.DBSystemKey).AsString() != "sqlite" {
+			continue
+		}
+		queries++
+		if span.SpanKind != trace.SpanKindClient {
+			t.Errorf("%s: kind = %v, want client", span.Name, span.SpanKind)
+		}
+		if span.SpanContext.TraceID() != server.SpanContext.TraceID() {
+			t.Errorf("%s: trace id = %s, want %s", span.Name, span.SpanContext.TraceID(), traceID)
+		}
+		ancestor := span.Parent.SpanID()
+		for ancestor.IsValid() && ancestor != server.SpanContext.SpanID() {
+			ancestor = parents[ancestor]
+		}
+		if !ancestor.IsValid() {
+			t.Errorf("%s: not a descendant of the server span", span.Name)
+		}
+	}
+	if queries == 0 {
+		t.Error("no database spans recorded")
+	}
+}
+
+func TestTracingStartsTraceWithoutTraceparent(t *testing.T) {
+	db := testdb.SQLite(t)
+	app, _, _ := tokenApp(t, db, Tracing())
+	exporter := recordSpans(t, db)
+
+	if status := post(t, app, "/api/me", "invalid", ""); status != 400 {
+		t.Fatalf("status = %d, want 400", status)
+	}
+	for _, span := range exporter.GetSpans() {
+		if span.SpanKind != trace.SpanKindServer {
+			continue
+		}
+		if span.Parent.IsValid() {
+			t.Errorf("server span has parent %s", span.Parent.SpanID())
+		}
+		if status := attr(span, semconv.HTTPResponseStatusCodeKey).AsInt64(); status != 400 {
+			t.Errorf("http.response.status_code = %d, want 400", status)
+		}
+		return
+	}
+	t.Error("no server span recorded")
+}
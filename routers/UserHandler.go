@@ -0,0 +1,218 @@
+package routers
+
+import (
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type UserH struct {
+	userService   *services.UserService
+	tenantService *services.TenantService
+}
+
+func NewUserHandler(userService *services.UserService, tenantService *services.TenantService) *UserH {
+	return &UserH{userService: userService, tenantService: tenantService}
+}
+
+// SearchUsers godoc
+// @Summary Поиск пользователей
+// @Description Поиск пользователей арендатора по подстроке GUID, имени или email с постраничной выборкой по курсору
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param tenant query string false "Арендатор, по умолчанию default"
+// @Param q query string false "Подстрока GUID, имени или email"
+// @Param disabled query bool false "Только отключённые (true) или активные (false)"
+// @Param deleted query bool false "Только удалённые пользователи"
+// @Param created_after query string false "Созданные не раньше момента (RFC 3339)"
+// @Param created_before query string false "Созданные раньше момента (RFC 3339)"
+// @Param sort query string false "Сортировка: guid, name, email, disabled, created_at, updated_at; минус - по убыванию. По умолчанию created_at"
+// @Param limit query int false "Размер страницы, по умолчанию 20, не больше 100"
+// @Param cursor query string false "next_cursor предыдущей страницы"
+// @Success 200 {object} models.UserPageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users [get]
+func (h *UserH) SearchUsers(ctx *fiber.Ctx) error {
+	tenant, err := h.tenantService.GetTenant(ctx.Query("tenant", consts.DefaultTenant))
+	if err != nil {
+		return ErrorResponse(ctx, "Tenant not found", 404)
+	}
+
+	filter := models.UserSearch{
+		ListRequest: listRequest(ctx),
+		Query:       ctx.Query("q"),
+		Deleted:     ctx.QueryBool("deleted"),
+	}
+	if filter.Disabled, err = queryBool(ctx, "disabled"); err != nil {
+		return ErrorResponse(ctx, "disabled must be true or false", 400)
+	}
+	if filter.CreatedAfter, err = queryTime(ctx, "created_after"); err != nil {
+		return ErrorResponse(ctx, "created_after must be an RFC 3339 timestamp", 400)
+	}
+	if filter.CreatedBefore, err = queryTime(ctx, "created_before"); err != nil {
+		return ErrorResponse(ctx, "created_before must be an RFC 3339 timestamp", 400)
+	}
+
+	page, err := h.userService.Search(tenant.ID, filter)
+	if err != nil {
+		return userErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(page)
+}
+
+// GetSessions godoc
+// @Summary Refresh сессии
+// @Description Refresh сессии арендатора с постраничной выборкой по курсору
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param tenant query string false "Арендатор, по умолчанию default"
+// @Param user query string false "GUID пользователя"
+// @Param client_id query string false "OAuth клиент"
+// @Param active query bool false "Только действующие (true) или истёкшие (false)"
+// @Param sort query string false "Сортировка: id, user_guid, client_id, created_at, expires_at (в Redis - только id и created_at); минус - по убыванию. По умолчанию created_at"
+// @Param limit query int false "Размер страницы, по умолчанию 20, не больше 100"
+// @Param cursor query string false "next_cursor предыдущей страницы"
+// @Success 200 {object} models.SessionPageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/sessions [get]
+func (h *UserH) GetSessions(ctx *fiber.Ctx) error {
+	tenant, err := h.tenantService.GetTenant(ctx.Query("tenant", consts.DefaultTenant))
+	if err != nil {
+		return ErrorResponse(ctx, "Tenant not found", 404)
+	}
+
+	filter := models.SessionSearch{
+		ListRequest: listRequest(ctx),
+		UserGuid:    ctx.Query("user"),
+		ClientID:    ctx.Query("client_id"),
+	}
+	if filter.Active, err = queryBool(ctx, "active"); err != nil {
+		return ErrorResponse(ctx, "active must be true or false", 400)
+	}
+
+	page, err := h.userService.Sessions(ctx.UserContext(), tenant.ID, filter)
+	if err != nil {
+		return userErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(page)
+}
+
+// GetUser godoc
+// @Summary Получить пользователя
+// @Description Пользователь арендатора, его роль участника и refresh сессии в арендаторе
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param guid path string true "GUID пользователя"
+// @Param tenant query string false "Арендатор, по умолчанию default"
+// @Success 200 {object} models.UserDetailResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{guid} [get]
+func (h *UserH) GetUser(ctx *fiber.Ctx) error {
+	tenant, err := h.tenantService.GetTenant(ctx.Query("tenant", consts.DefaultTenant))
+	if err != nil {
+		return ErrorResponse(ctx, "Tenant not found", 404)
+	}
+
+	guid := ctx.Params("guid")
+	user, err := h.userService.Detail(ctx.UserContext(), tenant.ID, guid, h.tenantService.MemberRole(tenant.ID, guid))
+	if err != nil {
+		return userErrorResponse(ctx, err)
+	}
+	return ctx.Status(http.StatusOK).JSON(user)
+}
+
+// DisableUser godoc
+// @Summary Отключить пользователя
+// @Description Отключённый пользователь не получает и не обновляет токены. Все его сессии завершаются
+// @Tags Администрирование
+// @Security AdminKeyAuth
+// @Param guid path string true "GUID пользователя"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{guid}/disable [post]
+func (h *UserH) DisableUser(ctx *fiber.Ctx) error {
+	if err := h.userService.Disable(ctx.UserContext(), ctx.Params("guid")); err != nil {
+		return userErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// EnableUser godoc
+// @Summary Включить пользователя
+// @Tags Администрирование
+// @Security AdminKeyAuth
+// @Param guid path string true "GUID пользователя"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{guid}/enable [post]
+func (h *UserH) EnableUser(ctx *fiber.Ctx) error {
+	if err := h.userService.Enable(ctx.Params("guid")); err != nil {
+		return userErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// DeleteUser godoc
+// @Summary Удалить пользователя
+// @Description Мягкое удаление: пользователь скрывается из всех запросов, его сессии завершаются
+// @Tags Администрирование
+// @Security AdminKeyAuth
+// @Param guid path string true "GUID пользователя"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{guid} [delete]
+func (h *UserH) DeleteUser(ctx *fiber.Ctx) error {
+	if err := h.userService.Delete(ctx.UserContext(), ctx.Params("guid")); err != nil {
+		return userErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// LogoutUser godoc
+// @Summary Принудительный выход
+// @Description Удаляет refresh токены пользователя во всех арендаторах, выданные access токены становятся устаревшими
+// @Tags Администрирование
+// @Security AdminKeyAuth
+// @Param guid path string true "GUID пользователя"
+// @Success 204
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{guid}/logout [post]
+func (h *UserH) LogoutUser(ctx *fiber.Ctx) error {
+	if err := h.userService.Logout(ctx.UserContext(), ctx.Params("guid")); err != nil {
+		return userErrorResponse(ctx, err)
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+func userErrorResponse(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrorResponse(ctx, "User not found", 404)
+	case errors.Is(err, services.ErrInvalidList):
+		return ErrorResponse(ctx, err.Error(), 400)
+	}
+	return ErrorResponse(ctx, "Internal Server Error", 500)
+}
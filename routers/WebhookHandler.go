@@ -0,0 +1,136 @@
+package routers
+
+import (
+	"auth-service/models"
+	"auth-service/services"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+type WebhookH struct {
+	webhooks *services.WebhookService
+}
+
+func NewWebhookHandler(webhooks *services.WebhookService) *WebhookH {
+	return &WebhookH{webhooks: webhooks}
+}
+
+// GetWebhookDeliveries godoc
+// @Summary Доставки webhook'ов
+// @Description Доставки из outbox webhook'ов с постраничной выборкой по курсору, по умолчанию от новых к старым. status=dead - доставки, попытки которых исчерпаны
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param status query string false "Состояние: pending, delivered или dead"
+// @Param event query string false "Событие, например new_ip"
+// @Param sort query string false "Сортировка: id, created_at, event, status, attempts, next_attempt_at; минус - по убыванию. По умолчанию -id"
+// @Param limit query int false "Размер страницы, по умолчанию 20, не больше 100"
+// @Param cursor query string false "next_cursor предыдущей страницы"
+// @Success 200 {object} models.WebhookPageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/webhooks [get]
+func (h *WebhookH) GetWebhookDeliveries(ctx *fiber.Ctx) error {
+	filter := models.WebhookSearch{
+		ListRequest: listRequest(ctx),
+		Status:      ctx.Query("status"),
+		Event:       ctx.Query("event"),
+	}
+	switch filter.Status {
+	case "", models.WebhookPending, models.WebhookDelivered, models.WebhookDead:
+	default:
+		return ErrorResponse(ctx, "status must be one of pending, delivered, dead", 400)
+	}
+	page, err := h.webhooks.Search(ctx.UserContext(), filter)
+	if errors.Is(err, services.ErrInvalidList) {
+		return ErrorResponse(ctx, err.Error(), 400)
+	}
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(page)
+}
+
+// GetWebhookDelivery godoc
+// @Summary Доставка webhook'а
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param id path int true "ID доставки"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/admin/webhooks/{id} [get]
+func (h *WebhookH) GetWebhookDelivery(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return ErrorResponse(ctx, "Not found", 404)
+	}
+	delivery, err := h.webhooks.GetDelivery(ctx.UserContext(), uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrorResponse(ctx, "Not found", 404)
+	}
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(delivery)
+}
+
+// ReplayWebhookDelivery godoc
+// @Summary Повторить доставку webhook'а
+// @Description Возвращает доставку в очередь с полным набором попыток: недоставленную (dead) или доставленную, если получатель её потерял. Получатель отличает повтор по заголовку X-Webhook-ID
+// @Tags Администрирование
+// @Produce json
+// @Security AdminKeyAuth
+// @Param id path int true "ID доставки"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse "Доставка уже в очереди"
+// @Router /api/admin/webhooks/{id}/replay [post]
+func (h *WebhookH) ReplayWebhookDelivery(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return ErrorResponse(ctx, "Not found", 404)
+	}
+	delivery, err := h.webhooks.Replay(ctx.UserContext(), uint(id))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrorResponse(ctx, "Not found", 404)
+	case errors.Is(err, services.ErrWebhookQueued):
+		return ErrorResponse(ctx, err.Error(), 409)
+	case err != nil:
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(delivery)
+}
+
+// ReplayDeadWebhooks godoc
+// @Summary Повторить недоставленные webhook'и
+// @Description Возвращает в очередь все доставки в состоянии dead, например после восстановления получателя
+// @Tags Администрирование
+// @Accept json
+// @Produce json
+// @Security AdminKeyAuth
+// @Param request body models.WebhookReplayRequest false "Только доставки события event"
+// @Success 200 {object} models.WebhookReplayResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/webhooks/replay [post]
+func (h *WebhookH) ReplayDeadWebhooks(ctx *fiber.Ctx) error {
+	var req models.WebhookReplayRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			return ErrorResponse(ctx, "invalid request body", 400)
+		}
+	}
+	n, err := h.webhooks.ReplayDead(ctx.UserContext(), req.Event)
+	if err != nil {
+		return ErrorResponse(ctx, "Internal Server Error", 500)
+	}
+	return ctx.Status(http.StatusOK).JSON(models.WebhookReplayResponse{Replayed: n})
+}
@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"hash/fnv"
+)
+
+// Locker выбирает реплику, которая выполняет задачу name. acquired false - задачу уже
+// выполняет другая реплика; release освобождает взятую блокировку.
+type Locker interface {
+	TryLock(ctx context.Context, name string) (release func(), acquired bool, err error)
+}
+
+// NewLocker возвращает блокировку для базы db: в PostgreSQL - pg_try_advisory_lock,
+// общий для всех реплик. SQLite обслуживает один процесс, и блокировка не нужна.
+func NewLocker(db *gorm.DB) Locker {
+	if db.Dialector.Name() == "postgres" {
+		return &advisoryLocker{db: db}
+	}
+	return localLocker{}
+}
+
+type localLocker struct{}
+
+func (localLocker) TryLock(context.Context, string) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+// advisoryLocker держит блокировку на отдельном соединении: advisory lock принадлежит
+// сессии PostgreSQL, а запросы задачи идут через общий пул.
+type advisoryLocker struct {
+	db *gorm.DB
+}
+
+func (l *advisoryLocker) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	pool, err := l.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	key := lockKey(name)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil || !acquired {
+		conn.Close()
+		return nil, false, err
+	}
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		conn.Close()
+	}, true, nil
+}
+
+// lockKey - ключ advisory lock задачи, производный от её имени.
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("scheduler:" + name))
+	return int64(h.Sum64())
+}
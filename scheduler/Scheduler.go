@@ -0,0 +1,152 @@
+// Package scheduler - периодические фоновые задачи сервиса. Каждая задача выполняется
+// в своей горутине раз в Interval; перед запуском берётся блокировка Locker, поэтому
+// при нескольких репликах задачу в каждый момент выполняет только одна из них.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job - периодическая задача. Run возвращает количество обработанных записей
+// (например, удалённых строк), которое накапливается в JobStatus.Processed.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) (int64, error)
+}
+
+// JobStatus - счётчики задачи с момента запуска процесса. Skipped - запуски, пропущенные
+// потому, что задачу выполняет другая реплика.
+type JobStatus struct {
+	Name      string     `json:"name"`
+	Interval  string     `json:"interval"`
+	Runs      int64      `json:"runs"`
+	Skipped   int64      `json:"skipped"`
+	Failures  int64      `json:"failures"`
+	Processed int64      `json:"processed"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+type Scheduler struct {
+	locker Locker
+	jobs   []Job
+	mu     sync.Mutex
+	status map[string]*JobStatus
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(locker Locker) *Scheduler {
+	return &Scheduler{locker: locker, status: map[string]*JobStatus{}}
+}
+
+// Add регистрирует задачу; задачи добавляются до Start.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+	s.status[job.Name] = &JobStatus{Name: job.Name, Interval: job.Interval.String()}
+}
+
+// Start запускает задачи: первый раз сразу, затем раз в Interval.
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Stop отменяет выполняющиеся задачи и ждёт их завершения, но не дольше ctx.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status возвращает счётчики задач по имени.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.status))
+	for _, st := range s.status {
+		statuses = append(statuses, *st)
+	}
+	slices.SortFunc(statuses, func(a, b JobStatus) int { return strings.Compare(a.Name, b.Name) })
+	return statuses
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		s.run(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	release, acquired, err := s.locker.TryLock(ctx, job.Name)
+	if err != nil {
+		s.record(job.Name, 0, err)
+		return
+	}
+	if !acquired {
+		s.mu.Lock()
+		s.status[job.Name].Skipped++
+		s.mu.Unlock()
+		return
+	}
+	defer release()
+
+	processed, err := job.Run(ctx)
+	if ctx.Err() != nil {
+		// процесс останавливается, прерванный запуск не считается ошибкой
+		err = nil
+	}
+	s.record(job.Name, processed, err)
+}
+
+func (s *Scheduler) record(name string, processed int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.status[name]
+	now := time.Now()
+	st.LastRun = &now
+	st.Processed += processed
+	if err != nil {
+		st.Failures++
+		st.LastError = err.Error()
+		slog.Error("Job failed", "job", name, "error", err)
+		return
+	}
+	st.Runs++
+	st.LastError = ""
+	if processed > 0 {
+		slog.Info("Job processed records", "job", name, "processed", processed)
+	}
+}
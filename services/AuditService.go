@@ -0,0 +1,262 @@
+package services
+
+import (
+	"auth-service/logging"
+	"auth-service/models"
+	"auth-service/repositories"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditBatch - записей в одном запросе при выгрузке и проверке журнала.
+const auditBatch = 1000
+
+const (
+	// auditQueue - событий в очереди фоновой записи. При заполненной очереди Record пишет сам.
+	auditQueue = 4096
+	// auditWriteBatch - событий в одной транзакции фоновой записи.
+	auditWriteBatch = 100
+)
+
+// AuditService пишет, выбирает и проверяет журнал аудита.
+//
+// Цепочка хешей требует дописывать события строго по очереди: на время транзакции берётся
+// блокировка (pg_advisory_xact_lock между репликами). Чтобы запросы не ждали её, после Start
+// Record только ставит событие в очередь, а фоновая запись дописывает накопленные события
+// пачками - одна блокировка на пачку. Цена - событие попадает в журнал чуть позже ответа,
+// и при падении процесса теряются события, ещё не записанные из очереди; при штатной остановке
+// Flush дописывает очередь. Без Start (команды, тесты) Record пишет синхронно.
+type AuditService struct {
+	repo repositories.AuditRepository
+
+	mu      sync.RWMutex
+	queue   chan *models.AuditEvent
+	stopped bool
+	done    chan struct{}
+}
+
+func NewAuditService(repo repositories.AuditRepository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+type requestInfoKey struct{}
+
+// RequestInfo - адрес и User-Agent клиента, от которого пришёл запрос.
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// WithRequestInfo возвращает контекст запроса клиента info: записи аудита, сделанные с этим
+// контекстом, получают его адрес и User-Agent.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// Start запускает фоновую запись событий пачками.
+func (s *AuditService) Start() {
+	s.queue = make(chan *models.AuditEvent, auditQueue)
+	s.done = make(chan struct{})
+	go s.write()
+}
+
+// Flush прекращает приём событий в очередь и ждёт записи оставшихся до истечения ctx.
+// События, пришедшие после Flush, пишутся синхронно.
+func (s *AuditService) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	if s.queue == nil || s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopped = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d audit events not written: %w", len(s.queue), ctx.Err())
+	}
+}
+
+func (s *AuditService) write() {
+	defer close(s.done)
+	batch := make([]*models.AuditEvent, 0, auditWriteBatch)
+	for e := range s.queue {
+		batch = append(batch[:0], e)
+	collect:
+		for len(batch) < auditWriteBatch {
+			select {
+			case e, ok := <-s.queue:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, e)
+			default:
+				break collect
+			}
+		}
+		if err := s.repo.Append(context.Background(), batch...); err != nil {
+			slog.Error("Failed to write audit events", "count", len(batch), "error", err)
+		}
+	}
+}
+
+// enqueue ставит событие в очередь фоновой записи; false - очереди нет или она заполнена.
+func (s *AuditService) enqueue(e *models.AuditEvent) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.queue == nil || s.stopped {
+		return false
+	}
+	select {
+	case s.queue <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// detach копирует строки события: строки из fiber.Ctx ссылаются на буферы запроса, которые
+// используются повторно после ответа, раньше, чем событие запишется из очереди.
+func detach(e models.AuditEvent) *models.AuditEvent {
+	for _, f := range []*string{&e.Action, &e.Outcome, &e.Reason, &e.Actor, &e.Subject, &e.IP, &e.UserAgent, &e.RequestID} {
+		*f = strings.Clone(*f)
+	}
+	if e.Details != nil {
+		details := make(map[string]string, len(e.Details))
+		for k, v := range e.Details {
+			details[strings.Clone(k)] = strings.Clone(v)
+		}
+		e.Details = details
+	}
+	return &e
+}
+
+// Record добавляет событие в журнал; IP, User-Agent и request_id, не заданные в e, берутся из
+// ctx, время события - момент вызова. Ошибка записи не прерывает операцию, а пишется в журнал
+// приложения: недоступность аудита не должна блокировать вход пользователей.
+func (s *AuditService) Record(ctx context.Context, e models.AuditEvent) {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	if e.IP == "" {
+		e.IP = info.IP
+	}
+	if e.UserAgent == "" {
+		e.UserAgent = info.UserAgent
+	}
+	if e.RequestID == "" {
+		e.RequestID = logging.RequestID(ctx)
+	}
+	if e.Outcome == "" {
+		e.Outcome = models.AuditOutcomeSuccess
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	if s.enqueue(detach(e)) {
+		return
+	}
+	// запись не отменяется вместе с запросом, если клиент отключился
+	if err := s.repo.Append(context.WithoutCancel(ctx), &e); err != nil {
+		slog.ErrorContext(ctx, "Failed to write audit event", "action", e.Action, "subject", e.Subject, "error", err)
+	}
+}
+
+// Search возвращает страницу журнала по фильтру; по умолчанию - от новых записей к старым.
+func (s *AuditService) Search(ctx context.Context, filter models.AuditSearch) (*models.AuditPageResponse, error) {
+	events, err := s.repo.List(ctx, auditQuery(filter))
+	if err != nil {
+		return nil, listError(err)
+	}
+	return &models.AuditPageResponse{Items: events.Items, NextCursor: events.NextCursor}, nil
+}
+
+// Export передаёт write все записи по фильтру в порядке цепочки (по возрастанию id).
+// Сортировка и размер страницы фильтра не учитываются.
+func (s *AuditService) Export(ctx context.Context, filter models.AuditSearch, write func(*models.AuditEvent) error) error {
+	q := auditQuery(filter)
+	q.Sort = []repositories.Sort{{Field: "id"}}
+	q.Limit = repositories.MaxLimit
+	q.Cursor = ""
+	for {
+		page, err := s.repo.List(ctx, q)
+		if err != nil {
+			return listError(err)
+		}
+		for i := range page.Items {
+			if err := write(&page.Items[i]); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// Verify проходит цепочку от первой записи и пересчитывает хеши. Цепочка нарушена, если хеш
+// записи не совпадает с её содержимым или PrevHash - с хешем предыдущей записи.
+func (s *AuditService) Verify(ctx context.Context) (*models.AuditVerifyResponse, error) {
+	res := &models.AuditVerifyResponse{Valid: true}
+	var afterID uint
+	for {
+		events, err := s.repo.Chain(ctx, afterID, auditBatch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range events {
+			e := &events[i]
+			switch {
+			case e.PrevHash != res.Head:
+				res.Error = "prev_hash does not match the previous event"
+			case e.ComputeHash() != e.Hash:
+				res.Error = "hash does not match the event content"
+			}
+			if res.Error != "" {
+				res.Valid = false
+				res.BrokenAt = e.ID
+				return res, nil
+			}
+			res.Head = e.Hash
+			res.Checked++
+			afterID = e.ID
+		}
+		if len(events) < auditBatch {
+			return res, nil
+		}
+	}
+}
+
+func auditQuery(filter models.AuditSearch) repositories.Query {
+	q := listQuery(filter.ListRequest)
+	eq := func(field string, value interface{}) {
+		q.Filters = append(q.Filters, repositories.Filter{Field: field, Op: repositories.OpEq, Value: value})
+	}
+	if filter.TenantID != nil {
+		eq("tenant_id", *filter.TenantID)
+	}
+	for _, f := range [][2]string{
+		{"action", filter.Action},
+		{"outcome", filter.Outcome},
+		{"actor", filter.Actor},
+		{"subject", filter.Subject},
+		{"ip", filter.IP},
+	} {
+		if f[1] != "" {
+			eq(f[0], f[1])
+		}
+	}
+	if filter.Since != nil {
+		q.Filters = append(q.Filters, repositories.Filter{Field: "created_at", Op: repositories.OpGte, Value: filter.Since.UTC()})
+	}
+	if filter.Until != nil {
+		q.Filters = append(q.Filters, repositories.Filter{Field: "created_at", Op: repositories.OpLt, Value: filter.Until.UTC()})
+	}
+	return q
+}
@@ -0,0 +1,72 @@
+package services_test
+
+import (
+	"auth-service/models"
+	"auth-service/repositories"
+	"auth-service/services"
+	"auth-service/testdb"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func auditService(t *testing.T) *services.AuditService {
+	t.Helper()
+	return services.NewAuditService(repositories.NewAuditRepository(testdb.SQLite(t)))
+}
+
+// record пишет n событий из workers горутин и проверяет, что все они в целой цепочке.
+func record(t *testing.T, audit *services.AuditService, flush func()) {
+	t.Helper()
+	const workers, n = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				audit.Record(context.Background(), models.AuditEvent{
+					Action:  models.AuditTokenIssued,
+					Actor:   models.AuditActorAnonymous,
+					Subject: fmt.Sprintf("%d-%d", w, i),
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	flush()
+
+	res, err := audit.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid || res.Checked != workers*n {
+		t.Errorf("verify = %+v, want valid chain of %d events", res, workers*n)
+	}
+}
+
+func TestAuditRecordSync(t *testing.T) {
+	audit := auditService(t)
+	record(t, audit, func() {})
+}
+
+func TestAuditRecordBatched(t *testing.T) {
+	audit := auditService(t)
+	audit.Start()
+	record(t, audit, func() {
+		if err := audit.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// после Flush события пишутся синхронно
+	audit.Record(context.Background(), models.AuditEvent{Action: models.AuditLogout, Actor: models.AuditActorAnonymous})
+	res, err := audit.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid || res.Checked != 401 {
+		t.Errorf("verify after flush = %+v, want valid chain of 401 events", res)
+	}
+}
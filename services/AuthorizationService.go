@@ -0,0 +1,121 @@
+package services
+
+import (
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/repositories"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidGrant   = errors.New("authorization grant is invalid, expired or revoked")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// AuthorizationRequest - параметры, с которыми пользователь разрешил клиенту доступ.
+type AuthorizationRequest struct {
+	TenantID            uint
+	Client              *models.OAuthClient
+	UserGuid            string
+	RedirectURI         string
+	Scopes              []string
+	Nonce               string
+	AuthTime            time.Time
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+type AuthorizationService struct {
+	repo repositories.AuthorizationCodeRepository
+}
+
+func NewAuthorizationService(r repositories.AuthorizationCodeRepository) *AuthorizationService {
+	return &AuthorizationService{repo: r}
+}
+
+// CreateCode выпускает одноразовый authorization code. Публичные клиенты обязаны
+// использовать PKCE (RFC 7636).
+func (s *AuthorizationService) CreateCode(req AuthorizationRequest) (string, error) {
+	if req.CodeChallenge == "" && req.Client.IsPublic() {
+		return "", ErrInvalidRequest
+	}
+	if req.CodeChallenge != "" {
+		if req.CodeChallengeMethod == "" {
+			req.CodeChallengeMethod = consts.CodeChallengePlain
+		}
+		if req.CodeChallengeMethod != consts.CodeChallengePlain && req.CodeChallengeMethod != consts.CodeChallengeS256 {
+			return "", ErrInvalidRequest
+		}
+	}
+
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(bytes)
+
+	err := s.repo.Create(&models.AuthorizationCode{
+		CodeHash:            hashCode(code),
+		ClientID:            req.Client.ClientID,
+		TenantID:            req.TenantID,
+		UserGuid:            req.UserGuid,
+		RedirectURI:         req.RedirectURI,
+		Scopes:              req.Scopes,
+		Nonce:               req.Nonce,
+		AuthTime:            req.AuthTime,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		ExpiresAt:           time.Now().Add(consts.AuthorizationCodeLifeTime),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ExchangeCode проверяет и гасит authorization code. Код можно обменять только один раз.
+func (s *AuthorizationService) ExchangeCode(tenantID uint, code, clientID, redirectURI, verifier string) (*models.AuthorizationCode, error) {
+	stored, err := s.repo.FindByHash(hashCode(code))
+	if err != nil {
+		return nil, ErrInvalidGrant
+	}
+	if stored.Used || stored.ExpiresAt.Before(time.Now()) || stored.TenantID != tenantID ||
+		stored.ClientID != clientID || stored.RedirectURI != redirectURI {
+		return nil, ErrInvalidGrant
+	}
+	if stored.CodeChallenge != "" && !verifyCodeChallenge(stored, verifier) {
+		return nil, ErrInvalidGrant
+	}
+
+	ok, err := s.repo.MarkUsed(stored.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidGrant
+	}
+	return stored, nil
+}
+
+func verifyCodeChallenge(code *models.AuthorizationCode, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	expected := verifier
+	if code.CodeChallengeMethod == consts.CodeChallengeS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(code.CodeChallenge)) == 1
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,22 @@
+package services
+
+import (
+	"auth-service/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// compareHash и generateHash - bcrypt с замером времени: медленный хеш заметно влияет на
+// задержку выдачи токенов, и его стоимость видна в metrics.BcryptDuration.
+func compareHash(hash, password string) error {
+	timer := prometheus.NewTimer(metrics.BcryptDuration.WithLabelValues("compare"))
+	defer timer.ObserveDuration()
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+func generateHash(password string) (string, error) {
+	timer := prometheus.NewTimer(metrics.BcryptDuration.WithLabelValues("hash"))
+	defer timer.ObserveDuration()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/repositories"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAuthorizationPending = errors.New("the authorization request is still pending")
+	ErrSlowDown             = errors.New("polling too frequently, increase the interval")
+	ErrExpiredToken         = errors.New("the device_code has expired")
+	ErrAccessDenied         = errors.New("the authorization request was denied")
+	ErrUserCodeNotFound     = errors.New("user code not found or expired")
+)
+
+// userCodeAlphabet - согласные без гласных и похожих символов (RFC 8628, 6.1).
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+type DeviceService struct {
+	repo    repositories.DeviceAuthorizationRepository
+	clients repositories.OAuthClientRepository
+}
+
+func NewDeviceService(r repositories.DeviceAuthorizationRepository, clients repositories.OAuthClientRepository) *DeviceService {
+	return &DeviceService{repo: r, clients: clients}
+}
+
+// Start создаёт запрос устройства и возвращает device_code и user_code в открытом виде.
+func (s *DeviceService) Start(tenantID uint, client *models.OAuthClient, scopes []string) (string, *models.DeviceAuthorization, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", nil, err
+	}
+	deviceCode := base64.RawURLEncoding.EncodeToString(bytes)
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		return "", nil, err
+	}
+
+	d := &models.DeviceAuthorization{
+		DeviceCodeHash: hashCode(deviceCode),
+		UserCode:       userCode,
+		ClientID:       client.ClientID,
+		TenantID:       tenantID,
+		Scopes:         scopes,
+		Status:         consts.DeviceStatusPending,
+		Interval:       int64(consts.DevicePollInterval.Seconds()),
+		ExpiresAt:      time.Now().Add(consts.DeviceCodeLifeTime),
+	}
+	if err := s.repo.Create(d); err != nil {
+		return "", nil, err
+	}
+	return deviceCode, d, nil
+}
+
+// Lookup возвращает ожидающий подтверждения запрос арендатора и клиента, который его создал.
+func (s *DeviceService) Lookup(tenantID uint, userCode string) (*models.DeviceAuthorization, *models.OAuthClient, error) {
+	d, err := s.repo.FindByUserCode(NormalizeUserCode(userCode))
+	if err != nil || d.TenantID != tenantID || d.Status != consts.DeviceStatusPending || d.ExpiresAt.Before(time.Now()) {
+		return nil, nil, ErrUserCodeNotFound
+	}
+	client, err := s.clients.FindByClientID(d.ClientID)
+	if err != nil {
+		return nil, nil, ErrUserCodeNotFound
+	}
+	return d, client, nil
+}
+
+// Verify фиксирует решение пользователя по user_code.
+func (s *DeviceService) Verify(tenantID uint, userCode, guid string, authTime time.Time, approve bool) (*models.DeviceAuthorization, error) {
+	d, _, err := s.Lookup(tenantID, userCode)
+	if err != nil {
+		return nil, err
+	}
+
+	d.UserGuid = guid
+	d.AuthTime = authTime
+	d.Status = consts.DeviceStatusDenied
+	if approve {
+		d.Status = consts.DeviceStatusApproved
+	}
+	if err := s.repo.Update(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Poll обрабатывает опрос token endpoint'а устройством. Слишком частый опрос
+// увеличивает интервал на 5 секунд (RFC 8628, 3.5).
+func (s *DeviceService) Poll(tenantID uint, deviceCode, clientID string) (*models.DeviceAuthorization, error) {
+	d, err := s.repo.FindByDeviceCodeHash(hashCode(deviceCode))
+	if err != nil || d.TenantID != tenantID || d.ClientID != clientID {
+		return nil, ErrInvalidGrant
+	}
+
+	now := time.Now()
+	if d.ExpiresAt.Before(now) {
+		return nil, ErrExpiredToken
+	}
+
+	switch d.Status {
+	case consts.DeviceStatusDenied:
+		return nil, ErrAccessDenied
+	case consts.DeviceStatusConsumed:
+		return nil, ErrInvalidGrant
+	}
+
+	tooFast := now.Sub(d.LastPolledAt) < time.Duration(d.Interval)*time.Second
+	d.LastPolledAt = now
+	if tooFast {
+		d.Interval += int64(consts.DevicePollInterval.Seconds())
+	}
+	if err := s.repo.Update(d); err != nil {
+		return nil, err
+	}
+	if tooFast {
+		return nil, ErrSlowDown
+	}
+
+	if d.Status == consts.DeviceStatusPending {
+		return nil, ErrAuthorizationPending
+	}
+	ok, err := s.repo.MarkConsumed(d.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidGrant
+	}
+	return d, nil
+}
+
+// NormalizeUserCode приводит введённый пользователем код к виду, в котором он хранится.
+func NormalizeUserCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(userCodeAlphabet, r) {
+			return r
+		}
+		return -1
+	}, code)
+}
+
+// FormatUserCode возвращает код в виде XXXX-XXXX для показа пользователю.
+func FormatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+func generateUserCode() (string, error) {
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
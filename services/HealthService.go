@@ -0,0 +1,70 @@
+package services
+
+import (
+	"auth-service/models"
+	"context"
+	"sync"
+	"time"
+)
+
+// checkTimeout ограничивает одну проверку, чтобы зависшая зависимость не задерживала ответ
+// дольше таймаута пробы.
+const checkTimeout = 2 * time.Second
+
+// HealthCheck - проверка зависимости, без которой сервис не может обслуживать запросы.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthService выполняет проверки готовности параллельно; сервис готов, если прошли все.
+type HealthService struct {
+	checks []HealthCheck
+}
+
+func NewHealthService(checks ...HealthCheck) *HealthService {
+	return &HealthService{checks: checks}
+}
+
+func (s *HealthService) Ready(ctx context.Context) (models.HealthResponse, bool) {
+	results := make([]models.HealthCheck, len(s.checks))
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, r := range results {
+		if r.Status != models.HealthStatusOK {
+			ready = false
+		}
+	}
+	status := models.HealthStatusOK
+	if !ready {
+		status = models.HealthStatusFail
+	}
+	return models.HealthResponse{Status: status, Checks: results}, ready
+}
+
+func runCheck(ctx context.Context, check HealthCheck) models.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	started := time.Now()
+	err := check.Check(ctx)
+	result := models.HealthCheck{
+		Name:     check.Name,
+		Status:   models.HealthStatusOK,
+		Duration: time.Since(started).Round(time.Microsecond).String(),
+	}
+	if err != nil {
+		result.Status = models.HealthStatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/notifier"
+	"auth-service/repositories"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidInvitation    = errors.New("invitation is invalid, expired or already accepted")
+	ErrInvalidEmail         = errors.New("invalid email")
+	ErrInvitationDelivery   = errors.New("failed to deliver invitation")
+	errInvitationTokenClaim = errors.New("not an invitation token")
+)
+
+// invitationTokenType - значение claim typ, отличающее токен приглашения от access токена.
+const invitationTokenType = "invite"
+
+// InvitationService приглашает пользователей в арендатора по email. Токен приглашения - JWT,
+// подписанный jwt.secret_key, со сроком действия consts.InvitationLifeTime; доставляется через notifier.
+type InvitationService struct {
+	repo     repositories.InvitationRepository
+	tenants  *TenantService
+	notifier notifier.Notifier
+	secret   string
+	issuer   string
+	lifetime time.Duration
+}
+
+func NewInvitationService(
+	r repositories.InvitationRepository,
+	tenants *TenantService,
+	n notifier.Notifier,
+	c config.Config,
+) *InvitationService {
+	return &InvitationService{
+		repo:     r,
+		tenants:  tenants,
+		notifier: n,
+		secret:   c.Jwt.SecretKey,
+		issuer:   c.Jwt.Issuer,
+		lifetime: consts.InvitationLifeTime,
+	}
+}
+
+// Invite создаёт приглашение от участника inviter и отправляет его на email. acceptURL - адрес
+// страницы или endpoint'а принятия, к нему добавляется параметр token.
+func (s *InvitationService) Invite(tenant *models.Tenant, inviter string, req models.InvitationRequest, acceptURL string) (*models.Invitation, error) {
+	addr, err := mail.ParseAddress(req.Email)
+	if err != nil || addr.Address != strings.TrimSpace(req.Email) {
+		return nil, ErrInvalidEmail
+	}
+	if req.Role == "" {
+		req.Role = consts.MemberRoleMember
+	}
+	if !slices.Contains(memberRoles, req.Role) {
+		return nil, ErrInvalidMemberRole
+	}
+	if req.Role == consts.MemberRoleOwner && s.tenants.MemberRole(tenant.ID, inviter) != consts.MemberRoleOwner {
+		return nil, ErrOwnerRequired
+	}
+
+	tokenID := make([]byte, 16)
+	if _, err := rand.Read(tokenID); err != nil {
+		return nil, err
+	}
+	i := &models.Invitation{
+		TenantID:  tenant.ID,
+		Email:     strings.ToLower(addr.Address),
+		Role:      req.Role,
+		TokenID:   hex.EncodeToString(tokenID),
+		InvitedBy: inviter,
+		ExpiresAt: time.Now().Add(s.lifetime),
+	}
+	token, err := s.sign(tenant, i)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(i); err != nil {
+		return nil, err
+	}
+
+	if err := s.notifier.Send(invitationMessage(tenant, i, token, acceptURL)); err != nil {
+		_ = s.repo.Delete(tenant.ID, i.ID)
+		return nil, fmt.Errorf("%w: %s", ErrInvitationDelivery, err)
+	}
+	return i, nil
+}
+
+func (s *InvitationService) Pending(tenantID uint) ([]models.InvitationResponse, error) {
+	invitations, err := s.repo.GetPending(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.InvitationResponse, len(invitations))
+	for i := range invitations {
+		result[i] = models.NewInvitationResponse(&invitations[i])
+	}
+	return result, nil
+}
+
+func (s *InvitationService) Revoke(tenantID uint, id uint) error {
+	return s.repo.Delete(tenantID, id)
+}
+
+// Accept принимает приглашение арендатора tenant. Пользователь с email приглашения добавляется
+// в арендатора, при отсутствии - создаётся с именем name и подтверждённым email.
+func (s *InvitationService) Accept(tenant *models.Tenant, token, name string) (*models.AcceptInvitationResponse, error) {
+	tokenID, err := s.parse(tenant, token)
+	if err != nil {
+		return nil, ErrInvalidInvitation
+	}
+	i, err := s.repo.FindByTokenID(tokenID)
+	if err != nil || i.TenantID != tenant.ID || i.AcceptedAt != nil || i.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidInvitation
+	}
+
+	user := &models.User{
+		Guid:          uuid.New().String(),
+		Name:          name,
+		Email:         i.Email,
+		EmailVerified: true,
+	}
+	guid, created, err := s.repo.Accept(i, user)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AcceptInvitationResponse{Guid: guid, Tenant: tenant.Slug, Role: i.Role, Created: created}, nil
+}
+
+func (s *InvitationService) sign(tenant *models.Tenant, i *models.Invitation) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"typ":   invitationTokenType,
+		"jti":   i.TokenID,
+		"tid":   tenant.Slug,
+		"email": i.Email,
+		"iss":   tenant.TokenIssuer(s.issuer),
+		"iat":   time.Now().Unix(),
+		"exp":   i.ExpiresAt.Unix(),
+	})
+	return token.SignedString([]byte(s.secret))
+}
+
+// parse проверяет подпись, срок действия и арендатора токена приглашения и возвращает jti.
+func (s *InvitationService) parse(tenant *models.Tenant, token string) (string, error) {
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		return []byte(s.secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+	claims, _ := parsed.Claims.(jwt.MapClaims)
+	typ, _ := claims["typ"].(string)
+	tid, _ := claims["tid"].(string)
+	jti, _ := claims["jti"].(string)
+	if typ != invitationTokenType || tid != tenant.Slug || jti == "" {
+		return "", errInvitationTokenClaim
+	}
+	return jti, nil
+}
+
+func invitationMessage(tenant *models.Tenant, i *models.Invitation, token, acceptURL string) notifier.Message {
+	link := acceptURL
+	if u, err := url.Parse(acceptURL); err == nil {
+		q := u.Query()
+		q.Set("token", token)
+		u.RawQuery = q.Encode()
+		link = u.String()
+	}
+	name := tenant.Name
+	if name == "" {
+		name = tenant.Slug
+	}
+	return notifier.Message{
+		Kind:    "invitation",
+		To:      i.Email,
+		Subject: "Приглашение в " + name,
+		Body: fmt.Sprintf("Вас пригласили в %s с ролью %s.\nПринять приглашение: %s\nСсылка действительна до %s.",
+			name, i.Role, link, i.ExpiresAt.UTC().Format(time.RFC1123)),
+		Data: map[string]string{
+			"tenant":     tenant.Slug,
+			"role":       i.Role,
+			"token":      token,
+			"accept_url": link,
+			"expires_at": i.ExpiresAt.UTC().Format(time.RFC3339),
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"math/big"
+	"os"
+)
+
+// KeyService хранит RSA ключ для подписи id_token'ов и публикует его в JWKS.
+// Access токены по-прежнему подписываются HS512 общим секретом.
+type KeyService struct {
+	key    *rsa.PrivateKey
+	kid    string
+	secret bool
+}
+
+// NewKeyService читает ключ из jwt.private_key_path (PKCS#1 или PKCS#8). Без пути ключ создаётся
+// только в режиме разработки (application.dev): id_token'ы, выданные до перезапуска или другой
+// репликой, с таким ключом не проверяются.
+func NewKeyService(c config.Config) (*KeyService, error) {
+	var key *rsa.PrivateKey
+	if c.Jwt.PrivateKeyPath == "" {
+		if !c.Application.Dev {
+			return nil, errors.New("jwt.private_key_path is not set (a temporary key is allowed only with application.dev: true or AUTH_DEV=true)")
+		}
+		slog.Warn("jwt.private_key_path is not set, using ephemeral RSA key for id_token signing")
+		generated, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		key = generated
+	} else {
+		content, err := os.ReadFile(c.Jwt.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = parsePrivateKey(content); err != nil {
+			return nil, err
+		}
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	thumbprint := sha256.Sum256(der)
+	return &KeyService{
+		key:    key,
+		kid:    base64.RawURLEncoding.EncodeToString(thumbprint[:])[:16],
+		secret: c.Jwt.SecretKey != "",
+	}, nil
+}
+
+// Check сообщает, загружены ли ключи подписи access и id_token'ов.
+func (s *KeyService) Check(context.Context) error {
+	if s.key == nil {
+		return errors.New("id_token signing key is not loaded")
+	}
+	if !s.secret {
+		return errors.New("jwt.secret_key is not set")
+	}
+	return nil
+}
+
+func (s *KeyService) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.kid
+	return token.SignedString(s.key)
+}
+
+func (s *KeyService) JWKS() models.Jwks {
+	return models.Jwks{Keys: []models.Jwk{{
+		Kty: "RSA",
+		Use: "sig",
+		Kid: s.kid,
+		Alg: jwt.SigningMethodRS256.Alg(),
+		N:   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+	}}}
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+	return key, nil
+}
@@ -0,0 +1,26 @@
+package services
+
+import (
+	"auth-service/models"
+	"auth-service/repositories"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidList - неизвестное поле сортировки или фильтра либо неверный курсор.
+var ErrInvalidList = errors.New("invalid list parameters")
+
+func listQuery(r models.ListRequest) repositories.Query {
+	return repositories.Query{
+		Sort:   repositories.ParseSort(r.Sort),
+		Limit:  r.Limit,
+		Cursor: r.Cursor,
+	}
+}
+
+func listError(err error) error {
+	if errors.Is(err, repositories.ErrInvalidQuery) || errors.Is(err, repositories.ErrInvalidCursor) {
+		return fmt.Errorf("%w: %s", ErrInvalidList, err)
+	}
+	return err
+}
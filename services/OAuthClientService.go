@@ -0,0 +1,311 @@
+package services
+
+import (
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/repositories"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"slices"
+)
+
+var (
+	ErrInvalidClient         = errors.New("client authentication failed")
+	ErrUnauthorizedClient    = errors.New("client is not allowed to use this grant type")
+	ErrInvalidScope          = errors.New("requested scope is not allowed for this client")
+	ErrInvalidClientMetadata = errors.New("invalid client metadata")
+)
+
+var supportedGrantTypes = []string{
+	consts.GrantClientCredentials,
+	consts.GrantAuthorizationCode,
+	consts.GrantDeviceCode,
+	consts.GrantTokenExchange,
+}
+
+var supportedAuthMethods = []string{
+	consts.AuthMethodClientSecretBasic,
+	consts.AuthMethodClientSecretPost,
+	consts.AuthMethodPrivateKeyJwt,
+	consts.AuthMethodNone,
+}
+
+var assertionSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+type OAuthClientService struct {
+	repo repositories.OAuthClientRepository
+}
+
+func NewOAuthClientService(r repositories.OAuthClientRepository) *OAuthClientService {
+	return &OAuthClientService{repo: r}
+}
+
+// Register создаёт клиента и возвращает его вместе с секретом в открытом виде.
+// Секрет показывается только один раз, для private_key_jwt и публичных клиентов он не создаётся.
+func (s *OAuthClientService) Register(req models.OAuthClientRequest) (*models.OAuthClient, string, error) {
+	client := &models.OAuthClient{ClientID: uuid.New().String()}
+	if err := applyClientRequest(client, req); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := s.assignSecret(client)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.repo.Create(client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+func (s *OAuthClientService) Update(clientID string, req models.OAuthClientRequest) (*models.OAuthClient, string, error) {
+	client, err := s.repo.FindByClientID(clientID)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := applyClientRequest(client, req); err != nil {
+		return nil, "", err
+	}
+
+	// секрет выдаётся заново только при переходе на секретный метод аутентификации
+	secret, err := s.assignSecret(client)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.repo.Update(client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+func (s *OAuthClientService) RotateSecret(clientID string) (*models.OAuthClient, string, error) {
+	client, err := s.repo.FindByClientID(clientID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !client.UsesSecret() {
+		return nil, "", ErrInvalidClientMetadata
+	}
+	client.SecretHash = ""
+	secret, err := s.assignSecret(client)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.repo.Update(client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+func (s *OAuthClientService) Delete(clientID string) error {
+	if _, err := s.repo.FindByClientID(clientID); err != nil {
+		return err
+	}
+	return s.repo.DeleteByClientID(clientID)
+}
+
+func (s *OAuthClientService) GetClient(clientID string) (*models.OAuthClient, error) {
+	return s.repo.FindByClientID(clientID)
+}
+
+func (s *OAuthClientService) GetClients() ([]models.OAuthClientResponse, error) {
+	clients, err := s.repo.GetClients()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.OAuthClientResponse, len(clients))
+	for i := range clients {
+		result[i] = models.NewOAuthClientResponse(&clients[i], "")
+	}
+	return result, nil
+}
+
+// Authenticate проверяет учётные данные клиента. audiences - допустимые значения aud
+// для client_assertion (адрес token endpoint'а и issuer).
+func (s *OAuthClientService) Authenticate(creds models.ClientCredentials, audiences []string) (*models.OAuthClient, error) {
+	clientID := creds.ClientID
+	if clientID == "" && creds.Method == consts.AuthMethodPrivateKeyJwt {
+		clientID = assertionIssuer(creds.Assertion)
+	}
+	if clientID == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := s.repo.FindByClientID(clientID)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+	if client.TokenEndpointAuthMethod != creds.Method {
+		return nil, ErrInvalidClient
+	}
+
+	switch creds.Method {
+	case consts.AuthMethodClientSecretBasic, consts.AuthMethodClientSecretPost:
+		if client.SecretHash == "" ||
+			compareHash(client.SecretHash, creds.ClientSecret) != nil {
+			return nil, ErrInvalidClient
+		}
+	case consts.AuthMethodPrivateKeyJwt:
+		claims, err := verifyClientAssertion(client, creds.Assertion, audiences)
+		if err != nil {
+			return nil, ErrInvalidClient
+		}
+		// assertion одноразовый: повтор перехваченного assertion до exp отклоняется
+		first, err := s.repo.UseAssertion(client.ClientID, claims.ID, claims.ExpiresAt.Time)
+		if err != nil {
+			return nil, err
+		}
+		if !first {
+			return nil, ErrInvalidClient
+		}
+	case consts.AuthMethodNone:
+	default:
+		return nil, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// ResolveScopes возвращает запрошенные scope, если все они разрешены клиенту.
+// Пустой запрос означает все scope клиента.
+func (s *OAuthClientService) ResolveScopes(client *models.OAuthClient, requested string) ([]string, error) {
+	return narrowTo(client.Scopes, requested)
+}
+
+func (s *OAuthClientService) assignSecret(client *models.OAuthClient) (string, error) {
+	if !client.UsesSecret() {
+		client.SecretHash = ""
+		return "", nil
+	}
+	if client.SecretHash != "" {
+		return "", nil
+	}
+
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(bytes)
+	hash, err := generateHash(secret)
+	if err != nil {
+		return "", err
+	}
+	client.SecretHash = hash
+	return secret, nil
+}
+
+func applyClientRequest(client *models.OAuthClient, req models.OAuthClientRequest) error {
+	if req.TokenEndpointAuthMethod == "" {
+		req.TokenEndpointAuthMethod = consts.AuthMethodClientSecretBasic
+	}
+	if !slices.Contains(supportedAuthMethods, req.TokenEndpointAuthMethod) {
+		return ErrInvalidClientMetadata
+	}
+	if len(req.GrantTypes) == 0 {
+		return ErrInvalidClientMetadata
+	}
+	for _, grant := range req.GrantTypes {
+		if !slices.Contains(supportedGrantTypes, grant) {
+			return ErrInvalidClientMetadata
+		}
+	}
+	if req.AccessTokenLifetime < 0 {
+		return ErrInvalidClientMetadata
+	}
+	// публичный клиент не может доказать свою подлинность, поэтому client_credentials ему недоступен
+	if req.TokenEndpointAuthMethod == consts.AuthMethodNone && slices.Contains(req.GrantTypes, consts.GrantClientCredentials) {
+		return ErrInvalidClientMetadata
+	}
+	if slices.Contains(req.GrantTypes, consts.GrantAuthorizationCode) && len(req.RedirectURIs) == 0 {
+		return ErrInvalidClientMetadata
+	}
+	if req.TokenEndpointAuthMethod == consts.AuthMethodPrivateKeyJwt {
+		if _, err := parsePublicKey(req.PublicKey); err != nil {
+			return ErrInvalidClientMetadata
+		}
+	}
+
+	client.Name = req.Name
+	client.TokenEndpointAuthMethod = req.TokenEndpointAuthMethod
+	client.GrantTypes = req.GrantTypes
+	client.Scopes = req.Scopes
+	client.RedirectURIs = req.RedirectURIs
+	client.PublicKey = req.PublicKey
+	client.AccessTokenLifetime = req.AccessTokenLifetime
+	client.ExchangeAudiences = req.ExchangeAudiences
+	client.AllowImpersonation = req.AllowImpersonation
+	if client.ExchangeAudiences == nil {
+		client.ExchangeAudiences = []string{}
+	}
+	if client.Scopes == nil {
+		client.Scopes = []string{}
+	}
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+	return nil
+}
+
+// verifyClientAssertion проверяет подпись, iss, sub, aud и exp client_assertion и возвращает
+// его claims. jti обязателен: по нему отклоняются повторы.
+func verifyClientAssertion(client *models.OAuthClient, assertion string, audiences []string) (*jwt.RegisteredClaims, error) {
+	key, err := parsePublicKey(client.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err = jwt.ParseWithClaims(assertion, &claims, func(t *jwt.Token) (interface{}, error) {
+		return key, nil
+	},
+		jwt.WithValidMethods(assertionSigningMethods),
+		jwt.WithIssuer(client.ClientID),
+		jwt.WithSubject(client.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.ID == "" {
+		return nil, errors.New("client assertion has no jti")
+	}
+
+	for _, a := range claims.Audience {
+		if slices.Contains(audiences, a) {
+			return &claims, nil
+		}
+	}
+	return nil, errors.New("client assertion audience mismatch")
+}
+
+func assertionIssuer(assertion string) string {
+	token, _, err := jwt.NewParser().ParseUnverified(assertion, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+	iss, _ := token.Claims.GetIssuer()
+	return iss
+}
+
+func parsePublicKey(data string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, errors.New("unsupported public key type")
+}
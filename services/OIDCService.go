@@ -0,0 +1,122 @@
+package services
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/repositories"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/golang-jwt/jwt/v5"
+	"slices"
+	"time"
+)
+
+// IDTokenParams - данные аутентификации, из которых собирается id_token.
+type IDTokenParams struct {
+	Tenant      *models.Tenant
+	UserGuid    string
+	ClientID    string
+	Nonce       string
+	AuthTime    time.Time
+	Scopes      []string
+	AccessToken string
+}
+
+type OIDCService struct {
+	keys     *KeyService
+	users    repositories.UserRepository
+	issuer   string
+	duration time.Duration
+}
+
+func NewOIDCService(keys *KeyService, users repositories.UserRepository, c config.Config) *OIDCService {
+	return &OIDCService{
+		keys:     keys,
+		users:    users,
+		issuer:   c.Jwt.Issuer,
+		duration: consts.TokenRefreshLifeTime,
+	}
+}
+
+// IDToken выпускает id_token (RS256). at_hash - левая половина SHA-256 от access токена.
+func (s *OIDCService) IDToken(p IDTokenParams) (string, error) {
+	user, err := s.users.FindByGUID(p.Tenant.ID, p.UserGuid)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":       p.Tenant.TokenIssuer(s.issuer),
+		"sub":       user.Guid,
+		"aud":       p.ClientID,
+		"azp":       p.ClientID,
+		"exp":       now.Add(s.duration).Unix(),
+		"iat":       now.Unix(),
+		"auth_time": p.AuthTime.Unix(),
+	}
+	if p.Nonce != "" {
+		claims["nonce"] = p.Nonce
+	}
+	if p.AccessToken != "" {
+		sum := sha256.Sum256([]byte(p.AccessToken))
+		claims["at_hash"] = base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+	}
+
+	info := userClaims(user, p.Scopes)
+	if info.Name != "" {
+		claims["name"] = info.Name
+	}
+	if info.Email != "" {
+		claims["email"] = info.Email
+		claims["email_verified"] = *info.EmailVerified
+	}
+	return s.keys.Sign(claims)
+}
+
+// UserInfo возвращает claims пользователя, отфильтрованные по выданным scope.
+func (s *OIDCService) UserInfo(tenant *models.Tenant, guid string, scopes []string) (*models.UserInfoResponse, error) {
+	user, err := s.users.FindByGUID(tenant.ID, guid)
+	if err != nil {
+		return nil, err
+	}
+	info := userClaims(user, scopes)
+	return &info, nil
+}
+
+func (s *OIDCService) Discovery(tenant *models.Tenant, baseURL string) models.OpenIDConfiguration {
+	return models.OpenIDConfiguration{
+		Issuer:                            tenant.TokenIssuer(s.issuer),
+		AuthorizationEndpoint:             baseURL + "/oauth/authorize",
+		TokenEndpoint:                     baseURL + "/oauth/token",
+		UserinfoEndpoint:                  baseURL + "/userinfo",
+		JwksURI:                           baseURL + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{jwt.SigningMethodRS256.Alg()},
+		ScopesSupported:                   []string{consts.ScopeOpenID, consts.ScopeProfile, consts.ScopeEmail},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "name", "updated_at", "email", "email_verified"},
+		GrantTypesSupported:               supportedGrantTypes,
+		TokenEndpointAuthMethodsSupported: supportedAuthMethods,
+		CodeChallengeMethodsSupported:     []string{consts.CodeChallengeS256, consts.CodeChallengePlain},
+	}
+}
+
+func (s *OIDCService) JWKS() models.Jwks {
+	return s.keys.JWKS()
+}
+
+func userClaims(user *models.User, scopes []string) models.UserInfoResponse {
+	info := models.UserInfoResponse{Sub: user.Guid}
+	if slices.Contains(scopes, consts.ScopeProfile) {
+		info.Name = user.Name
+		info.UpdatedAt = user.UpdatedAt.Unix()
+	}
+	if slices.Contains(scopes, consts.ScopeEmail) && user.Email != "" {
+		verified := user.EmailVerified
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+	return info
+}
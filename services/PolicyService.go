@@ -0,0 +1,68 @@
+package services
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/policy"
+	"auth-service/repositories"
+	"errors"
+	"log/slog"
+	"os"
+)
+
+// PolicyService принимает решения о доступе по политикам из policy.dir. Атрибуты субъекта
+// берутся из пользователя и его ролей в момент запроса, а не из токена.
+type PolicyService struct {
+	engine *policy.Engine
+	users  repositories.UserRepository
+	rbac   *RBACService
+}
+
+// NewPolicyService загружает политики. Без каталога политик все запросы запрещаются.
+func NewPolicyService(users repositories.UserRepository, rbac *RBACService, c config.Config) (*PolicyService, error) {
+	engine, err := policy.LoadDir(c.Policy.Dir)
+	if errors.Is(err, os.ErrNotExist) || c.Policy.Dir == "" {
+		slog.Warn("Policy directory not found, all authorization requests will be denied", "dir", c.Policy.Dir)
+		engine, err = policy.NewEngine()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PolicyService{engine: engine, users: users, rbac: rbac}, nil
+}
+
+// UserSubject возвращает атрибуты пользователя арендатора для условий subject.*.
+func (s *PolicyService) UserSubject(tenant *models.Tenant, guid string) (map[string]interface{}, error) {
+	user, err := s.users.FindByGUID(tenant.ID, guid)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	authz, err := s.rbac.UserAuthorization(tenant.ID, guid)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"type":           "user",
+		"tenant":         tenant.Slug,
+		"guid":           user.Guid,
+		"name":           user.Name,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"created_at":     user.CreatedAt.Unix(),
+		"roles":          authz.Roles,
+		"permissions":    authz.Permissions,
+	}, nil
+}
+
+// ClientSubject - атрибуты OAuth клиента, запрашивающего решение от своего имени.
+func (s *PolicyService) ClientSubject(clientID string, scopes []string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "client",
+		"client_id": clientID,
+		"scopes":    scopes,
+	}
+}
+
+func (s *PolicyService) Decide(req policy.Request) policy.Decision {
+	return s.engine.Evaluate(req)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"auth-service/config"
+	"auth-service/repositories"
+	"context"
+	"time"
+)
+
+// PurgeService удаляет записи, которые больше не нужны: истёкшие и удалённые сессии, коды
+// авторизации, запросы устройств и доставленные webhook'и старше retention, истёкшие записи
+// об отозванных токенах и использованных client_assertion. Удаление идёт пачками по batchSize, чтобы не держать долгие блокировки.
+type PurgeService struct {
+	tokens    repositories.TokenRepository
+	codes     repositories.AuthorizationCodeRepository
+	devices   repositories.DeviceAuthorizationRepository
+	webhooks  repositories.WebhookRepository
+	clients   repositories.OAuthClientRepository
+	retention time.Duration
+	batchSize int
+}
+
+func NewPurgeService(
+	tokens repositories.TokenRepository,
+	codes repositories.AuthorizationCodeRepository,
+	devices repositories.DeviceAuthorizationRepository,
+	webhooks repositories.WebhookRepository,
+	clients repositories.OAuthClientRepository,
+	c config.Config,
+) *PurgeService {
+	return &PurgeService{
+		tokens:    tokens,
+		codes:     codes,
+		devices:   devices,
+		webhooks:  webhooks,
+		clients:   clients,
+		retention: c.Scheduler.Retention,
+		batchSize: c.Scheduler.BatchSize,
+	}
+}
+
+func (s *PurgeService) Sessions(ctx context.Context) (int64, error) {
+	return s.purge(ctx, time.Now().Add(-s.retention), s.tokens.PurgeSessions)
+}
+
+// DeniedTokens удаляет записи сразу после истечения: истёкший access токен не принимается и так.
+func (s *PurgeService) DeniedTokens(ctx context.Context) (int64, error) {
+	return s.purge(ctx, time.Now(), s.tokens.PurgeDenied)
+}
+
+func (s *PurgeService) AuthorizationCodes(ctx context.Context) (int64, error) {
+	return s.purge(ctx, time.Now().Add(-s.retention), s.codes.Purge)
+}
+
+func (s *PurgeService) DeviceAuthorizations(ctx context.Context) (int64, error) {
+	return s.purge(ctx, time.Now().Add(-s.retention), s.devices.Purge)
+}
+
+// WebhookDeliveries удаляет только доставленные webhook'и: недоставленные (dead) хранятся,
+// пока их не отправят повторно.
+func (s *PurgeService) WebhookDeliveries(ctx context.Context) (int64, error) {
+	return s.purge(ctx, time.Now().Add(-s.retention), s.webhooks.Purge)
+}
+
+// ClientAssertions удаляет jti client_assertion после истечения: истёкший assertion не
+// принимается и так.
+func (s *PurgeService) ClientAssertions(ctx context.Context) (int64, error) {
+	return s.purge(ctx, time.Now(), s.clients.PurgeAssertions)
+}
+
+// purge вызывает batch, пока он удаляет полные пачки, и возвращает общее число удалённых записей.
+func (s *PurgeService) purge(ctx context.Context, before time.Time, batch func(ctx context.Context, before time.Time, limit int) (int64, error)) (int64, error) {
+	var total int64
+	for ctx.Err() == nil {
+		n, err := batch(ctx, before, s.batchSize)
+		total += n
+		if err != nil || n < int64(s.batchSize) {
+			return total, err
+		}
+	}
+	return total, ctx.Err()
+}
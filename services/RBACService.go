@@ -0,0 +1,183 @@
+package services
+
+import (
+	"auth-service/models"
+	"auth-service/repositories"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+var (
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrUserNotFound      = errors.New("user not found")
+)
+
+// namePattern - допустимые имена ролей и разрешений: "admin", "users:write", "billing.read".
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]{0,63}$`)
+
+type RBACService struct {
+	repo repositories.RoleRepository
+}
+
+func NewRBACService(r repositories.RoleRepository) *RBACService {
+	return &RBACService{repo: r}
+}
+
+func (s *RBACService) CreatePermission(req models.PermissionRequest) (*models.Permission, error) {
+	if !namePattern.MatchString(req.Name) {
+		return nil, fmt.Errorf("%w: name must match %s", ErrInvalidPermission, namePattern)
+	}
+	if existing, _ := s.repo.FindPermissionsByNames([]string{req.Name}); len(existing) > 0 {
+		return nil, fmt.Errorf("%w: %s already exists", ErrInvalidPermission, req.Name)
+	}
+	p := &models.Permission{Name: req.Name, Description: req.Description}
+	if err := s.repo.CreatePermission(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *RBACService) GetPermissions() ([]models.PermissionResponse, error) {
+	permissions, err := s.repo.GetPermissions()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.PermissionResponse, len(permissions))
+	for i, p := range permissions {
+		result[i] = models.NewPermissionResponse(p)
+	}
+	return result, nil
+}
+
+func (s *RBACService) DeletePermission(name string) error {
+	return s.repo.DeletePermission(name)
+}
+
+func (s *RBACService) CreateRole(req models.RoleRequest) (*models.Role, error) {
+	if _, err := s.repo.FindRoleByName(req.Name); err == nil {
+		return nil, fmt.Errorf("%w: %s already exists", ErrInvalidRole, req.Name)
+	}
+	role := &models.Role{}
+	if err := s.applyRoleRequest(role, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRole(role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// UpdateRole заменяет описание и разрешения роли. Токены пользователей с этой ролью
+// становятся устаревшими.
+func (s *RBACService) UpdateRole(name string, req models.RoleRequest) (*models.Role, error) {
+	role, err := s.repo.FindRoleByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name == "" {
+		req.Name = role.Name
+	}
+	if req.Name != name {
+		if _, err := s.repo.FindRoleByName(req.Name); err == nil {
+			return nil, fmt.Errorf("%w: %s already exists", ErrInvalidRole, req.Name)
+		}
+	}
+	if err := s.applyRoleRequest(role, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateRole(role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (s *RBACService) DeleteRole(name string) error {
+	return s.repo.DeleteRole(name)
+}
+
+func (s *RBACService) GetRole(name string) (*models.Role, error) {
+	return s.repo.FindRoleByName(name)
+}
+
+func (s *RBACService) GetRoles() ([]models.RoleResponse, error) {
+	roles, err := s.repo.GetRoles()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.RoleResponse, len(roles))
+	for i := range roles {
+		result[i] = models.NewRoleResponse(&roles[i])
+	}
+	return result, nil
+}
+
+// SetUserRoles заменяет роли пользователя в арендаторе. Выданные ранее в этом арендаторе
+// токены становятся устаревшими.
+func (s *RBACService) SetUserRoles(tenantID uint, guid string, names []string) (*models.UserAuthorization, error) {
+	roles, err := s.repo.FindRolesByNames(names)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) != len(unique(names)) {
+		return nil, fmt.Errorf("%w: unknown role in %v", ErrInvalidRole, names)
+	}
+	if err := s.repo.SetUserRoles(tenantID, guid, roles); err != nil {
+		return nil, err
+	}
+	return s.UserAuthorization(tenantID, guid)
+}
+
+// UserAuthorization возвращает текущие роли, разрешения и версию разрешений пользователя
+// в арендаторе.
+func (s *RBACService) UserAuthorization(tenantID uint, guid string) (*models.UserAuthorization, error) {
+	version, err := s.repo.GetPermissionVersion(tenantID, guid)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	roles, err := s.repo.GetUserRoles(tenantID, guid)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewUserAuthorization(roles, version), nil
+}
+
+// IsCurrent - роли и разрешения в токене пользователя соответствуют его текущим ролям
+// в арендаторе. Токен без pv считается устаревшим.
+func (s *RBACService) IsCurrent(tenantID uint, claims *models.TokenClaims) bool {
+	if claims.PermissionVersion == 0 {
+		return false
+	}
+	version, err := s.repo.GetPermissionVersion(tenantID, claims.Sub)
+	return err == nil && version == claims.PermissionVersion
+}
+
+func (s *RBACService) applyRoleRequest(role *models.Role, req models.RoleRequest) error {
+	if !namePattern.MatchString(req.Name) {
+		return fmt.Errorf("%w: name must match %s", ErrInvalidRole, namePattern)
+	}
+	permissions := []models.Permission{}
+	if len(req.Permissions) > 0 {
+		found, err := s.repo.FindPermissionsByNames(req.Permissions)
+		if err != nil {
+			return err
+		}
+		if len(found) != len(unique(req.Permissions)) {
+			return fmt.Errorf("%w: unknown permission in %v", ErrInvalidRole, req.Permissions)
+		}
+		permissions = found
+	}
+
+	role.Name = req.Name
+	role.Description = req.Description
+	role.Permissions = permissions
+	return nil
+}
+
+func unique(values []string) []string {
+	result := slices.Clone(values)
+	slices.Sort(result)
+	return slices.Compact(result)
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"auth-service/models"
+	"auth-service/models/consts"
+	"auth-service/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrTenantNotFound        = errors.New("tenant not found")
+	ErrTenantDisabled        = errors.New("tenant is disabled")
+	ErrInvalidTenantMetadata = errors.New("invalid tenant metadata")
+	ErrInvalidMemberRole     = errors.New("invalid member role")
+	ErrOwnerRequired         = errors.New("only owners can manage owners")
+	ErrLastOwner             = errors.New("the last owner cannot be removed or demoted")
+)
+
+var memberRoles = []string{consts.MemberRoleOwner, consts.MemberRoleAdmin, consts.MemberRoleMember}
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type TenantService struct {
+	repo   repositories.TenantRepository
+	users  repositories.UserRepository
+	tokens repositories.TokenRepository
+}
+
+func NewTenantService(r repositories.TenantRepository, users repositories.UserRepository, tokens repositories.TokenRepository) *TenantService {
+	return &TenantService{repo: r, users: users, tokens: tokens}
+}
+
+func (s *TenantService) Create(req models.TenantRequest) (*models.Tenant, error) {
+	if _, err := s.repo.FindBySlug(req.Slug); err == nil {
+		return nil, fmt.Errorf("%w: %s already exists", ErrInvalidTenantMetadata, req.Slug)
+	}
+	t := &models.Tenant{}
+	if err := applyTenantRequest(t, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Update заменяет настройки арендатора. Slug не меняется: он записан в выданные токены (tid).
+func (s *TenantService) Update(slug string, req models.TenantRequest) (*models.Tenant, error) {
+	t, err := s.repo.FindBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	req.Slug = t.Slug
+	if err := applyTenantRequest(t, req); err != nil {
+		return nil, err
+	}
+	if t.Slug == consts.DefaultTenant && t.Disabled {
+		return nil, fmt.Errorf("%w: default tenant cannot be disabled", ErrInvalidTenantMetadata)
+	}
+	if err := s.repo.Update(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Delete удаляет арендатора и его сессии. Сессии удаляются и в хранилище сессий:
+// оно может быть отдельным от базы арендаторов (Redis).
+func (s *TenantService) Delete(ctx context.Context, slug string) error {
+	if slug == consts.DefaultTenant {
+		return fmt.Errorf("%w: default tenant cannot be deleted", ErrInvalidTenantMetadata)
+	}
+	t, err := s.repo.FindBySlug(slug)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteBySlug(slug); err != nil {
+		return err
+	}
+	return s.tokens.DeleteByTenant(ctx, t.ID)
+}
+
+func (s *TenantService) GetTenant(slug string) (*models.Tenant, error) {
+	return s.repo.FindBySlug(slug)
+}
+
+func (s *TenantService) GetByID(id uint) (*models.Tenant, error) {
+	return s.repo.FindByID(id)
+}
+
+func (s *TenantService) GetTenants() ([]models.TenantResponse, error) {
+	tenants, err := s.repo.GetTenants()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.TenantResponse, len(tenants))
+	for i := range tenants {
+		result[i] = models.NewTenantResponse(&tenants[i])
+	}
+	return result, nil
+}
+
+// Resolve определяет арендатора запроса: явно указанный slug, затем имя хоста, затем
+// арендатор по умолчанию. Явно указанный, но неизвестный арендатор - ошибка.
+func (s *TenantService) Resolve(slug, host string) (*models.Tenant, error) {
+	var t *models.Tenant
+	var err error
+	switch {
+	case slug != "":
+		t, err = s.repo.FindBySlug(slug)
+	case host != "":
+		if t, err = s.repo.FindByDomain(strings.ToLower(host)); err != nil {
+			t, err = s.repo.FindBySlug(consts.DefaultTenant)
+		}
+	default:
+		t, err = s.repo.FindBySlug(consts.DefaultTenant)
+	}
+	if err != nil {
+		return nil, ErrTenantNotFound
+	}
+	if t.Disabled {
+		return nil, ErrTenantDisabled
+	}
+	return t, nil
+}
+
+func (s *TenantService) Members(slug string) ([]models.MemberResponse, error) {
+	t, err := s.repo.FindBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	return s.TenantMembers(t.ID)
+}
+
+// AddMember добавляет пользователя в арендатора с ролью role (по умолчанию member)
+// или меняет роль участника. Административный API не ограничен правилами владельцев.
+func (s *TenantService) AddMember(slug, guid, role string) error {
+	if role == "" {
+		role = consts.MemberRoleMember
+	}
+	if !slices.Contains(memberRoles, role) {
+		return ErrInvalidMemberRole
+	}
+	t, err := s.repo.FindBySlug(slug)
+	if err != nil {
+		return err
+	}
+	return s.repo.AddMember(t.ID, guid, role)
+}
+
+func (s *TenantService) RemoveMember(ctx context.Context, slug, guid string) error {
+	t, err := s.repo.FindBySlug(slug)
+	if err != nil {
+		return err
+	}
+	return s.removeMember(ctx, t.ID, guid)
+}
+
+func (s *TenantService) TenantMembers(tenantID uint) ([]models.MemberResponse, error) {
+	members, err := s.repo.GetMembers(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.MemberResponse, len(members))
+	for i, m := range members {
+		result[i] = models.NewMemberResponse(m)
+	}
+	return result, nil
+}
+
+// MemberRole возвращает роль пользователя в арендаторе или пустую строку, если он не участник.
+func (s *TenantService) MemberRole(tenantID uint, guid string) string {
+	m, err := s.repo.FindMember(tenantID, guid)
+	if err != nil {
+		return ""
+	}
+	return m.Role
+}
+
+// ChangeMemberRole меняет роль участника по запросу участника actor.
+func (s *TenantService) ChangeMemberRole(tenantID uint, actor, guid, role string) error {
+	if !slices.Contains(memberRoles, role) {
+		return ErrInvalidMemberRole
+	}
+	current, err := s.checkOwnerRules(tenantID, actor, guid, role == consts.MemberRoleOwner)
+	if err != nil {
+		return err
+	}
+	if current == role {
+		return nil
+	}
+	return s.repo.SetMemberRole(tenantID, guid, role)
+}
+
+// ExpelMember исключает участника по запросу участника actor и завершает его сессии в арендаторе.
+func (s *TenantService) ExpelMember(ctx context.Context, tenantID uint, actor, guid string) error {
+	if _, err := s.checkOwnerRules(tenantID, actor, guid, false); err != nil {
+		return err
+	}
+	return s.removeMember(ctx, tenantID, guid)
+}
+
+// removeMember исключает пользователя из арендатора и удаляет его сессии в арендаторе,
+// в том числе в отдельном хранилище сессий.
+func (s *TenantService) removeMember(ctx context.Context, tenantID uint, guid string) error {
+	if err := s.repo.RemoveMember(tenantID, guid); err != nil {
+		return err
+	}
+	return s.tokens.DeleteByTenantUser(ctx, tenantID, guid)
+}
+
+// checkOwnerRules проверяет изменение участника guid: владельцев назначают, понижают и исключают
+// только владельцы, а последний владелец остаётся владельцем. Возвращает текущую роль участника.
+func (s *TenantService) checkOwnerRules(tenantID uint, actor, guid string, grantsOwner bool) (string, error) {
+	target, err := s.repo.FindMember(tenantID, guid)
+	if err != nil {
+		return "", err
+	}
+	if target.Role != consts.MemberRoleOwner && !grantsOwner {
+		return target.Role, nil
+	}
+	if s.MemberRole(tenantID, actor) != consts.MemberRoleOwner {
+		return "", ErrOwnerRequired
+	}
+	if target.Role == consts.MemberRoleOwner && !grantsOwner {
+		owners, err := s.repo.CountMembersWithRole(tenantID, consts.MemberRoleOwner)
+		if err != nil {
+			return "", err
+		}
+		if owners <= 1 {
+			return "", ErrLastOwner
+		}
+	}
+	return target.Role, nil
+}
+
+func applyTenantRequest(t *models.Tenant, req models.TenantRequest) error {
+	if !slugPattern.MatchString(req.Slug) {
+		return fmt.Errorf("%w: slug must match %s", ErrInvalidTenantMetadata, slugPattern)
+	}
+	if req.AccessTokenLifetime < 0 {
+		return fmt.Errorf("%w: access_token_lifetime must not be negative", ErrInvalidTenantMetadata)
+	}
+	domains := make([]string, 0, len(req.Domains))
+	for _, d := range req.Domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if u, err := url.Parse("//" + d); err != nil || u.Host != d || u.Port() != "" || d == "" {
+			return fmt.Errorf("%w: bad domain %q", ErrInvalidTenantMetadata, d)
+		}
+		domains = append(domains, d)
+	}
+
+	t.Slug = req.Slug
+	t.Name = req.Name
+	t.Domains = domains
+	t.Issuer = req.Issuer
+	t.Audience = req.Audience
+	t.DefaultScopes = req.DefaultScopes
+	t.AccessTokenLifetime = req.AccessTokenLifetime
+	t.Disabled = req.Disabled
+	return nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/models/consts"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidTarget       = errors.New("requested audience is not allowed for this client")
+	ErrExchangeNotAllowed  = errors.New("client is not allowed to exchange this subject token")
+	ErrInvalidSubjectToken = errors.New("subject_token is invalid or expired")
+)
+
+// ExchangeRequest - параметры запроса token exchange (RFC 8693, 2.1).
+type ExchangeRequest struct {
+	Tenant           *models.Tenant
+	SubjectToken     string
+	SubjectTokenType string
+	ActorToken       string
+	ActorTokenType   string
+	Audience         []string
+	Scope            string
+}
+
+type TokenExchangeService struct {
+	tokenService *TokenService
+	secret       string
+}
+
+func NewTokenExchangeService(tokenService *TokenService, c config.Config) *TokenExchangeService {
+	return &TokenExchangeService{
+		tokenService: tokenService,
+		secret:       c.Jwt.SecretKey,
+	}
+}
+
+// Exchange выпускает access токен от имени субъекта subject_token'а для клиента client.
+//
+// Политика:
+//   - без token_exchange_impersonation клиент может обменять только токен, выданный ему
+//     (client_id или aud токена совпадает с клиентом);
+//   - целевая аудитория должна входить в token_exchange_audiences клиента;
+//   - scope только сужаются: пересечение scope субъекта и клиента;
+//   - в act записывается актор (клиент или субъект actor_token'а) поверх предыдущей цепочки;
+//   - новый токен живёт не дольше исходного;
+//   - токен пользователя обменивается только в его арендаторе.
+func (s *TokenExchangeService) Exchange(ctx context.Context, client *models.OAuthClient, req ExchangeRequest) (string, time.Duration, []string, error) {
+	if req.SubjectToken == "" || req.SubjectTokenType != consts.TokenTypeAccessToken {
+		return "", 0, nil, ErrInvalidRequest
+	}
+	subject := models.GetClaims(req.SubjectToken, s.secret)
+	if subject == nil || (subject.Tid != "" && subject.Tid != req.Tenant.Slug) {
+		return "", 0, nil, ErrInvalidSubjectToken
+	}
+	// токены пользователя после отключения, удаления, принудительного выхода или изменения
+	// ролей устаревают по pv; токен пользователя без pv не принимается. Выход из сессии и
+	// отзыв сессий по User-Agent pv не меняют, такие токены отклоняются по denylist
+	if !subject.IsClientToken() && !s.tokenService.rbac.IsCurrent(req.Tenant.ID, subject) {
+		return "", 0, nil, ErrInvalidSubjectToken
+	}
+	if s.tokenService.IsAccessTokenRevoked(ctx, req.Tenant.ID, subject) {
+		return "", 0, nil, ErrInvalidSubjectToken
+	}
+
+	issuedToClient := subject.ClientID == client.ClientID || slices.Contains(subject.Aud, client.ClientID)
+	if !issuedToClient && !client.AllowImpersonation {
+		return "", 0, nil, ErrExchangeNotAllowed
+	}
+
+	actor, err := s.actor(ctx, client, req)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	if len(req.Audience) == 0 {
+		return "", 0, nil, ErrInvalidTarget
+	}
+	for _, aud := range req.Audience {
+		if !slices.Contains(client.ExchangeAudiences, aud) {
+			return "", 0, nil, ErrInvalidTarget
+		}
+	}
+
+	scopes, err := narrowScopes(subject, client, req.Scope)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	lifetime := client.AccessTokenTTL(s.tokenService.duration)
+	if remaining := time.Until(time.Unix(subject.Exp, 0)); remaining < lifetime {
+		lifetime = remaining
+	}
+
+	params := AccessTokenParams{
+		Subject:  subject.Sub,
+		ClientID: client.ClientID,
+		Scopes:   scopes,
+		Audience: req.Audience,
+		Actor:    &models.ActorClaim{Sub: actor, Act: subject.Act},
+		Lifetime: lifetime,
+	}
+	// роли субъекта переносятся как есть: версия pv остаётся прежней, поэтому
+	// после изменения ролей пользователя обменянный токен тоже устаревает
+	if subject.Tid != "" {
+		params.Tenant = req.Tenant
+	}
+	if subject.PermissionVersion != 0 {
+		params.Authorization = &models.UserAuthorization{
+			Roles:       subject.Roles,
+			Permissions: subject.Permissions,
+			Version:     subject.PermissionVersion,
+		}
+	}
+
+	token, lifetime, err := s.tokenService.IssueAccessToken(ctx, params)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	return token, lifetime, scopes, nil
+}
+
+// actor определяет, кто действует от имени субъекта. actor_token должен принадлежать
+// самому клиенту, иначе клиент мог бы выдать себя за другой сервис. Отозванный actor_token
+// не принимается, как и отозванный subject_token.
+func (s *TokenExchangeService) actor(ctx context.Context, client *models.OAuthClient, req ExchangeRequest) (string, error) {
+	if req.ActorToken == "" {
+		return client.ClientID, nil
+	}
+	if req.ActorTokenType != consts.TokenTypeAccessToken {
+		return "", ErrInvalidRequest
+	}
+	actor := models.GetClaims(req.ActorToken, s.secret)
+	if actor == nil || s.tokenService.IsAccessTokenRevoked(ctx, req.Tenant.ID, actor) {
+		return "", ErrInvalidRequest
+	}
+	if actor.Sub != client.ClientID && actor.ClientID != client.ClientID {
+		return "", ErrExchangeNotAllowed
+	}
+	return actor.Sub, nil
+}
+
+func narrowScopes(subject *models.TokenClaims, client *models.OAuthClient, requested string) ([]string, error) {
+	granted := []string{}
+	for _, scope := range strings.Fields(subject.Scope) {
+		if slices.Contains(client.Scopes, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	return narrowTo(granted, requested)
+}
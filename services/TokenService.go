@@ -2,64 +2,255 @@ package services
 
 import (
 	"auth-service/config"
+	"auth-service/metrics"
 	"auth-service/models"
 	"auth-service/models/consts"
 	"auth-service/repositories"
+	"auth-service/tracing"
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
+	"go.opentelemetry.io/otel/attribute"
+	"log/slog"
+	"slices"
+	"strings"
 	"time"
 )
 
+// ErrSessionNotFound - access токен не относится ни к одной действующей сессии.
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session - параметры сессии, для которой выдаётся пара access/refresh токенов.
+type Session struct {
+	Tenant    *models.Tenant
+	UserGuid  string
+	UserAgent string
+	IP        string
+	ClientID  string
+	Scopes    []string
+	// AuthTime - момент аутентификации пользователя (auth_time); нулевой - сейчас.
+	// При обновлении токенов берётся из заменяемой сессии.
+	AuthTime time.Time
+	// Webhooks ставятся в очередь только вместе с заменой сессии в RotateTokens
+	Webhooks []models.WebhookDelivery
+}
+
 type TokenService struct {
-	repo     repositories.TokenRepository
-	secret   string
-	issuer   string
-	duration time.Duration
+	repo          repositories.TokenRepository
+	rbac          *RBACService
+	audit         *AuditService
+	webhooks      *WebhookService
+	secret        string
+	issuer        string
+	audience      []string
+	defaultScopes []string
+	duration      time.Duration
 }
 
-func NewTokenService(repo repositories.TokenRepository, c config.Config) *TokenService {
+func NewTokenService(repo repositories.TokenRepository, rbac *RBACService, audit *AuditService, webhooks *WebhookService, c config.Config) *TokenService {
 	return &TokenService{
-		repo:     repo,
-		secret:   c.Jwt.SecretKey,
-		issuer:   c.Jwt.Issuer,
-		duration: consts.TokenRefreshLifeTime,
+		repo:          repo,
+		rbac:          rbac,
+		audit:         audit,
+		webhooks:      webhooks,
+		secret:        c.Jwt.SecretKey,
+		issuer:        c.Jwt.Issuer,
+		audience:      c.Jwt.Audience,
+		defaultScopes: c.Jwt.DefaultScopes,
+		duration:      consts.TokenRefreshLifeTime,
+	}
+}
+
+func (s *TokenService) DeleteTokenByID(ctx context.Context, tenantID uint, id uint) error {
+	return s.repo.DeleteByID(ctx, tenantID, id)
+}
+
+func (s *TokenService) FindTokenByUserGUID(ctx context.Context, tenantID uint, guid string) (*models.Token, error) {
+	return s.repo.FindByUserGUID(ctx, tenantID, guid)
+}
+
+// FindSession находит сессию refresh токена: selector.verifier - по selector, токен прежнего
+// формата - первую сессию пользователя guid. Сам токен проверяет ValidateRefreshToken.
+func (s *TokenService) FindSession(ctx context.Context, tenantID uint, guid, refreshToken string) (token *models.Token, err error) {
+	ctx, span := tracing.Start(ctx, "TokenService.FindSession")
+	defer func() { tracing.End(span, err) }()
+
+	if selector, _, ok := strings.Cut(refreshToken, "."); ok {
+		return s.repo.FindBySelector(ctx, tenantID, selector)
+	}
+	span.SetAttributes(attribute.Bool("session.legacy", true))
+	return s.repo.FindByUserGUID(ctx, tenantID, guid)
+}
+
+// FindTokenSession находит сессию, к которой относится access токен claims: по его sid или,
+// для токенов без sid, по предъявленному refresh токену пары. Сессия другого пользователя
+// или не совпадающая пара дают ErrSessionNotFound.
+func (s *TokenService) FindTokenSession(ctx context.Context, tenantID uint, claims *models.TokenClaims, refreshToken string) (token *models.Token, err error) {
+	ctx, span := tracing.Start(ctx, "TokenService.FindTokenSession")
+	defer func() { tracing.End(span, err) }()
+
+	if claims.Sid != "" {
+		token, err = s.repo.FindBySelector(ctx, tenantID, claims.Sid)
+	} else {
+		if refreshToken == "" || !s.ValidateTokenPair(*claims, refreshToken) {
+			return nil, ErrSessionNotFound
+		}
+		token, err = s.FindSession(ctx, tenantID, claims.Sub, refreshToken)
+		if err == nil && !s.ValidateRefreshToken(ctx, token, refreshToken) {
+			return nil, ErrSessionNotFound
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token.UserGuid != claims.Sub {
+		return nil, ErrSessionNotFound
+	}
+	return token, nil
+}
+
+// ResolveUserScopes возвращает scope для сессии пользователя: запрошенные scope должны входить
+// в scope пользователя по умолчанию (default_scopes арендатора или jwt.default_scopes) и,
+// если указан клиент, в scope клиента. Пустой запрос означает все доступные scope.
+func (s *TokenService) ResolveUserScopes(tenant *models.Tenant, requested string, client *models.OAuthClient) ([]string, error) {
+	defaults := s.defaultScopes
+	if len(tenant.DefaultScopes) > 0 {
+		defaults = tenant.DefaultScopes
+	}
+	allowed := []string{}
+	for _, scope := range defaults {
+		if client == nil || slices.Contains(client.Scopes, scope) {
+			allowed = append(allowed, scope)
+		}
+	}
+	return narrowTo(allowed, requested)
+}
+
+// DownscopeSession сужает scope сессии при обновлении токенов. Расширить scope нельзя.
+func (s *TokenService) DownscopeSession(stored *models.Token, requested string) ([]string, error) {
+	return narrowTo(stored.Scopes, requested)
+}
+
+func (s *TokenService) GenerateTokens(ctx context.Context, session Session) (string, string, error) {
+	ctx, span := tracing.Start(ctx, "TokenService.GenerateTokens")
+	access, refresh, err := s.issueTokens(session, func(t *models.Token) error {
+		return s.repo.Create(ctx, t)
+	})
+	tracing.End(span, err)
+	if err == nil {
+		metrics.TokensIssued.WithLabelValues("session").Inc()
+		s.audit.Record(ctx, sessionAuditEvent(models.AuditTokenIssued, session))
+	}
+	return access, refresh, err
+}
+
+// RotateTokens выдаёт новую пару токенов взамен сессии stored: старая сессия удаляется
+// и новая создаётся атомарно. Если stored уже заменена или удалена (например, параллельным
+// обновлением тем же refresh токеном), возвращает ошибку "не найдено" хранилища.
+func (s *TokenService) RotateTokens(ctx context.Context, stored *models.Token, session Session) (string, string, error) {
+	ctx, span := tracing.Start(ctx, "TokenService.RotateTokens")
+	rotate := func(t *models.Token) error {
+		return s.repo.Rotate(ctx, stored.TenantID, stored.ID, t)
+	}
+	outbox, transactional := s.repo.(repositories.OutboxTokenRepository)
+	if transactional {
+		rotate = func(t *models.Token) error {
+			return outbox.RotateWithDeliveries(ctx, stored.TenantID, stored.ID, t, session.Webhooks)
+		}
+	}
+	access, refresh, err := s.issueTokens(session, rotate)
+	tracing.End(span, err)
+	if err != nil {
+		return "", "", err
+	}
+	metrics.TokensRefreshed.Inc()
+	s.audit.Record(ctx, sessionAuditEvent(models.AuditTokenRefreshed, session))
+	if transactional {
+		s.webhooks.Queued()
+	} else if err := s.webhooks.Enqueue(ctx, session.Webhooks); err != nil {
+		// сессии вне базы данных (Redis) уже заменены: токены выдаются, а событие теряется
+		slog.ErrorContext(ctx, "Failed to enqueue webhooks", "error", err)
+	}
+	return access, refresh, nil
+}
+
+// sessionAuditEvent - запись аудита о выдаче токенов сессии: от имени клиента, если сессия
+// выдана клиенту, иначе - от имени самого пользователя.
+func sessionAuditEvent(action string, session Session) models.AuditEvent {
+	actor := models.UserActor(session.UserGuid)
+	details := map[string]string{"type": "session", "scope": strings.Join(session.Scopes, " ")}
+	if session.ClientID != "" {
+		actor = models.ClientActor(session.ClientID)
+		details["client_id"] = session.ClientID
+	}
+	return models.AuditEvent{
+		TenantID:  session.Tenant.ID,
+		Action:    action,
+		Actor:     actor,
+		Subject:   session.UserGuid,
+		IP:        session.IP,
+		UserAgent: session.UserAgent,
+		Details:   details,
 	}
 }
 
-func (s *TokenService) DeleteTokenByID(id uint) error {
-	return s.repo.DeleteByID(id)
+// RevokeAccessToken отзывает access токен сессии до его истечения: такие токены перестают
+// приниматься (см. IsAccessTokenRevoked). Токены без refresh_sig (OAuth гранты) не отзываются.
+func (s *TokenService) RevokeAccessToken(ctx context.Context, tenantID uint, claims *models.TokenClaims) error {
+	if claims.RefreshSig == "" {
+		return nil
+	}
+	return s.repo.Deny(ctx, tenantID, claims.Sub, claims.RefreshSig, time.Unix(claims.Exp, 0))
 }
 
-func (s *TokenService) FindTokenByUserGUID(guid string) (*models.Token, error) {
-	return s.repo.FindByUserGUID(guid)
+// IsAccessTokenRevoked сообщает, отозван ли access токен. При ошибке хранилища токен
+// считается отозванным.
+func (s *TokenService) IsAccessTokenRevoked(ctx context.Context, tenantID uint, claims *models.TokenClaims) bool {
+	if claims.RefreshSig == "" {
+		return false
+	}
+	denied, err := s.repo.IsDenied(ctx, tenantID, claims.Sub, claims.RefreshSig)
+	return err != nil || denied
 }
 
-func (s *TokenService) GenerateTokens(guid, userAgent, ip string) (string, string, error) {
-	refreshToken, err := s.createRefreshToken()
+func (s *TokenService) issueTokens(session Session, store func(*models.Token) error) (string, string, error) {
+	refreshToken, selector, verifierHash, err := s.createRefreshToken()
 	if err != nil {
 		return "", "", err
 	}
 
-	accessToken, err := s.createAccessToken(guid, refreshToken)
+	authz, err := s.rbac.UserAuthorization(session.Tenant.ID, session.UserGuid)
 	if err != nil {
 		return "", "", err
 	}
 
-	hashedRefresh, _ := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+	if session.AuthTime.IsZero() {
+		session.AuthTime = time.Now()
+	}
+	accessToken, err := s.createAccessToken(session, authz, refreshToken, selector)
+	if err != nil {
+		return "", "", err
+	}
 
 	token := &models.Token{
-		UserGuid:     guid,
-		RefreshToken: string(hashedRefresh),
-		UserAgent:    userAgent,
-		IpAddress:    ip,
+		TenantID:     session.Tenant.ID,
+		UserGuid:     session.UserGuid,
+		ClientID:     session.ClientID,
+		Scopes:       session.Scopes,
+		Selector:     selector,
+		RefreshToken: verifierHash,
+		UserAgent:    session.UserAgent,
+		IpAddress:    session.IP,
 		ExpiresAt:    time.Now().Add(s.duration),
+		AuthTime:     session.AuthTime,
 	}
 
-	err = s.repo.Create(token)
+	err = store(token)
 	if err != nil {
 		return "", "", err
 	}
@@ -67,9 +258,128 @@ func (s *TokenService) GenerateTokens(guid, userAgent, ip string) (string, strin
 	return accessToken, refreshToken, nil
 }
 
-func (s *TokenService) ValidateRefreshToken(hashedToken, inputToken string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedToken), []byte(inputToken))
-	return err == nil
+// AccessTokenParams описывает access токен, выдаваемый через OAuth гранты.
+// Tenant задаёт tid, issuer и настройки токена; nil - токен клиента вне арендаторов.
+type AccessTokenParams struct {
+	Tenant   *models.Tenant
+	Subject  string
+	ClientID string
+	Scopes   []string
+	Audience []string
+	Actor    *models.ActorClaim
+	Lifetime time.Duration
+	// AuthTime - момент аутентификации пользователя, нулевой - без claim auth_time.
+	AuthTime time.Time
+	// Authorization - роли и разрешения пользователя, nil для токенов клиентов.
+	Authorization *models.UserAuthorization
+}
+
+// IssueAccessToken подписывает access токен без refresh токена (OAuth гранты).
+// Нулевой Lifetime означает время жизни по умолчанию.
+func (s *TokenService) IssueAccessToken(ctx context.Context, p AccessTokenParams) (string, time.Duration, error) {
+	if p.Lifetime <= 0 {
+		p.Lifetime = s.accessLifetime(p.Tenant)
+	}
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"exp": now.Add(p.Lifetime).Unix(),
+		"sub": p.Subject,
+		"iat": now.Unix(),
+		"iss": s.Issuer(p.Tenant),
+	}
+	if p.Tenant != nil {
+		claims["tid"] = p.Tenant.Slug
+	}
+	if p.ClientID != "" {
+		claims["client_id"] = p.ClientID
+	}
+	if len(p.Scopes) > 0 {
+		claims["scope"] = strings.Join(p.Scopes, " ")
+	}
+	if len(p.Audience) == 0 {
+		p.Audience = s.audienceFor(p.Tenant)
+	}
+	if len(p.Audience) > 0 {
+		claims["aud"] = p.Audience
+	}
+	if p.Actor != nil {
+		claims["act"] = p.Actor
+	}
+	if !p.AuthTime.IsZero() {
+		claims["auth_time"] = p.AuthTime.Unix()
+	}
+	if p.Authorization != nil {
+		setAuthorizationClaims(claims, p.Authorization)
+	}
+
+	token, err := s.sign(claims)
+	if err != nil {
+		return "", 0, err
+	}
+	metrics.TokensIssued.WithLabelValues("access").Inc()
+	s.audit.Record(ctx, accessAuditEvent(p))
+	return token, p.Lifetime, nil
+}
+
+// accessAuditEvent - запись аудита о выдаче access токена OAuth грантом. Действует клиент,
+// subject - пользователь токена (для client_credentials - сам клиент).
+func accessAuditEvent(p AccessTokenParams) models.AuditEvent {
+	e := models.AuditEvent{
+		Action:  models.AuditTokenIssued,
+		Actor:   models.ClientActor(p.ClientID),
+		Subject: p.Subject,
+		Details: map[string]string{"type": "access", "client_id": p.ClientID, "scope": strings.Join(p.Scopes, " ")},
+	}
+	if p.ClientID == "" {
+		e.Actor = models.UserActor(p.Subject)
+		delete(e.Details, "client_id")
+	}
+	if p.Tenant != nil {
+		e.TenantID = p.Tenant.ID
+	}
+	if p.Actor != nil {
+		e.Details["act"] = p.Actor.Sub
+	}
+	return e
+}
+
+// IssueUserAccessToken выдаёт access токен пользователю p.Subject арендатора p.Tenant с его
+// текущими ролями и разрешениями в этом арендаторе.
+func (s *TokenService) IssueUserAccessToken(ctx context.Context, p AccessTokenParams) (string, time.Duration, error) {
+	authz, err := s.rbac.UserAuthorization(p.Tenant.ID, p.Subject)
+	if err != nil {
+		return "", 0, err
+	}
+	p.Authorization = authz
+	return s.IssueAccessToken(ctx, p)
+}
+
+// GenerateClientToken выдаёт access токен клиенту (client_credentials): sub = client_id,
+// refresh токен не создаётся.
+func (s *TokenService) GenerateClientToken(ctx context.Context, client *models.OAuthClient, scopes []string) (string, time.Duration, error) {
+	return s.IssueAccessToken(ctx, AccessTokenParams{
+		Subject:  client.ClientID,
+		ClientID: client.ClientID,
+		Scopes:   scopes,
+		Lifetime: client.AccessTokenTTL(s.duration),
+	})
+}
+
+// ValidateRefreshToken проверяет refresh токен сессии stored. Verifier сравнивается по SHA-256
+// за постоянное время; bcrypt нужен только сессиям, выданным до selector.verifier.
+func (s *TokenService) ValidateRefreshToken(ctx context.Context, stored *models.Token, inputToken string) bool {
+	if stored.Selector == "" {
+		_, span := tracing.Start(ctx, "bcrypt.Compare")
+		err := compareHash(stored.RefreshToken, inputToken)
+		span.End()
+		return err == nil
+	}
+	selector, verifier, ok := strings.Cut(inputToken, ".")
+	if !ok || selector != stored.Selector {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashVerifier(verifier)), []byte(stored.RefreshToken)) == 1
 }
 
 func (s *TokenService) ValidateTokenPair(accessToken models.TokenClaims, refreshToken string) bool {
@@ -78,27 +388,102 @@ func (s *TokenService) ValidateTokenPair(accessToken models.TokenClaims, refresh
 	return accessToken.RefreshSig == currentRefreshSig
 }
 
-func (s *TokenService) createRefreshToken() (string, error) {
-	bytes := make([]byte, 32)
-	_, err := rand.Read(bytes)
-	if err != nil {
-		return "", err
+// createRefreshToken создаёт refresh токен selector.verifier и возвращает его вместе с selector
+// и хешем verifier для хранения. Verifier - 256 случайных бит, поэтому медленный хеш не нужен.
+func (s *TokenService) createRefreshToken() (token, selector, verifierHash string, err error) {
+	bytes := make([]byte, 16+32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", "", "", err
 	}
-	return base64.URLEncoding.EncodeToString(bytes), nil
+	selector = base64.RawURLEncoding.EncodeToString(bytes[:16])
+	verifier := base64.RawURLEncoding.EncodeToString(bytes[16:])
+	return selector + "." + verifier, selector, hashVerifier(verifier), nil
+}
+
+func hashVerifier(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return hex.EncodeToString(hash[:])
 }
 
-func (s *TokenService) createAccessToken(guid, refreshToken string) (string, error) {
+// createAccessToken подписывает access токен сессии. sid - selector сессии: по нему выход и /api/me
+// находят именно ту сессию, к которой относится токен.
+func (s *TokenService) createAccessToken(session Session, authz *models.UserAuthorization, refreshToken, selector string) (string, error) {
 	hash := sha256.Sum256([]byte(refreshToken))
 	sig := hex.EncodeToString(hash[:])[:8]
 
 	claims := jwt.MapClaims{
-		"exp":         time.Now().Add(s.duration).Unix(),
-		"sub":         guid,
+		"exp":         time.Now().Add(s.accessLifetime(session.Tenant)).Unix(),
+		"sub":         session.UserGuid,
 		"iat":         time.Now().Unix(),
-		"iss":         s.issuer,
+		"iss":         s.Issuer(session.Tenant),
+		"tid":         session.Tenant.Slug,
 		"refresh_sig": sig,
+		"sid":         selector,
+		"auth_time":   session.AuthTime.Unix(),
+	}
+	if len(session.Scopes) > 0 {
+		claims["scope"] = strings.Join(session.Scopes, " ")
 	}
+	if aud := s.audienceFor(session.Tenant); len(aud) > 0 {
+		claims["aud"] = aud
+	}
+	if session.ClientID != "" {
+		claims["client_id"] = session.ClientID
+	}
+	setAuthorizationClaims(claims, authz)
 
+	return s.sign(claims)
+}
+
+// setAuthorizationClaims добавляет роли, разрешения и их версию (pv), по которой
+// RequireRole/RequirePermission отклоняют токены, выданные до изменения ролей.
+func setAuthorizationClaims(claims jwt.MapClaims, authz *models.UserAuthorization) {
+	if len(authz.Roles) > 0 {
+		claims["roles"] = authz.Roles
+	}
+	if len(authz.Permissions) > 0 {
+		claims["permissions"] = authz.Permissions
+	}
+	claims["pv"] = authz.Version
+}
+
+// Issuer возвращает iss токенов арендатора: его собственный issuer или jwt.issuer.
+func (s *TokenService) Issuer(tenant *models.Tenant) string {
+	if tenant != nil {
+		return tenant.TokenIssuer(s.issuer)
+	}
+	return s.issuer
+}
+
+func (s *TokenService) audienceFor(tenant *models.Tenant) []string {
+	if tenant != nil && len(tenant.Audience) > 0 {
+		return tenant.Audience
+	}
+	return s.audience
+}
+
+func (s *TokenService) accessLifetime(tenant *models.Tenant) time.Duration {
+	if tenant != nil {
+		return tenant.AccessTokenTTL(s.duration)
+	}
+	return s.duration
+}
+
+func (s *TokenService) sign(claims jwt.MapClaims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 	return token.SignedString([]byte(s.secret))
 }
+
+// narrowTo возвращает запрошенные scope, если все они входят в allowed. Пустой запрос - все allowed.
+func narrowTo(allowed []string, requested string) ([]string, error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return allowed, nil
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(allowed, scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+	return scopes, nil
+}
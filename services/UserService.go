@@ -3,22 +3,25 @@ package services
 import (
 	"auth-service/models"
 	"auth-service/repositories"
+	"context"
 	"github.com/google/uuid"
+	"time"
 )
 
 type UserService struct {
-	repo repositories.UserRepository
+	repo   repositories.UserRepository
+	tokens repositories.TokenRepository
 }
 
-func NewUserService(r repositories.UserRepository) *UserService {
-	return &UserService{repo: r}
+func NewUserService(r repositories.UserRepository, tokens repositories.TokenRepository) *UserService {
+	return &UserService{repo: r, tokens: tokens}
 }
 
-func (s *UserService) NewUsers(count int) ([]models.User, error) {
+func (s *UserService) NewUsers(tenantID uint, count int) ([]models.User, error) {
 	users := make([]models.User, count)
 	for i := 0; i < count; i++ {
 		users[i].Guid = uuid.New().String()
-		err := s.repo.Create(&users[i])
+		err := s.repo.Create(tenantID, &users[i])
 		if err != nil {
 			return users, err
 		}
@@ -26,19 +29,124 @@ func (s *UserService) NewUsers(count int) ([]models.User, error) {
 	return users, nil
 }
 
-func (s *UserService) IsExist(guid string) bool {
-	return s.repo.IsExist(guid)
+func (s *UserService) IsExist(tenantID uint, guid string) bool {
+	return s.repo.IsExist(tenantID, guid)
 }
 
-func (s *UserService) GetUsers() ([]models.UserResponse, error) {
-	users, err := s.repo.GetUsers()
+// IsActive - пользователь состоит в арендаторе и не отключён.
+func (s *UserService) IsActive(tenantID uint, guid string) bool {
+	user, err := s.repo.FindByGUID(tenantID, guid)
+	return err == nil && !user.Disabled
+}
+
+func (s *UserService) GetUser(tenantID uint, guid string) (*models.User, error) {
+	return s.repo.FindByGUID(tenantID, guid)
+}
+
+// Search ищет пользователей арендатора постранично по курсору.
+func (s *UserService) Search(tenantID uint, filter models.UserSearch) (*models.UserPageResponse, error) {
+	q := listQuery(filter.ListRequest)
+	q.Search = filter.Query
+	q.OnlyDeleted = filter.Deleted
+	if filter.Disabled != nil {
+		q.Filters = append(q.Filters, repositories.Filter{Field: "disabled", Op: repositories.OpEq, Value: *filter.Disabled})
+	}
+	if filter.CreatedAfter != nil {
+		q.Filters = append(q.Filters, repositories.Filter{Field: "created_at", Op: repositories.OpGte, Value: *filter.CreatedAfter})
+	}
+	if filter.CreatedBefore != nil {
+		q.Filters = append(q.Filters, repositories.Filter{Field: "created_at", Op: repositories.OpLt, Value: *filter.CreatedBefore})
+	}
+
+	users, err := s.repo.List(tenantID, q)
+	if err != nil {
+		return nil, listError(err)
+	}
+	page := &models.UserPageResponse{
+		Items:      make([]models.AdminUserResponse, len(users.Items)),
+		NextCursor: users.NextCursor,
+	}
+	for i := range users.Items {
+		page.Items[i] = models.NewAdminUserResponse(&users.Items[i])
+	}
+	return page, nil
+}
+
+// Sessions - refresh сессии арендатора постранично по курсору.
+func (s *UserService) Sessions(ctx context.Context, tenantID uint, filter models.SessionSearch) (*models.SessionPageResponse, error) {
+	q := listQuery(filter.ListRequest)
+	if filter.UserGuid != "" {
+		q.Filters = append(q.Filters, repositories.Filter{Field: "user_guid", Op: repositories.OpEq, Value: filter.UserGuid})
+	}
+	if filter.ClientID != "" {
+		q.Filters = append(q.Filters, repositories.Filter{Field: "client_id", Op: repositories.OpEq, Value: filter.ClientID})
+	}
+	if filter.Active != nil {
+		op := repositories.OpLte
+		if *filter.Active {
+			op = repositories.OpGt
+		}
+		q.Filters = append(q.Filters, repositories.Filter{Field: "expires_at", Op: op, Value: time.Now()})
+	}
+
+	tokens, err := s.tokens.List(ctx, tenantID, q)
+	if err != nil {
+		return nil, listError(err)
+	}
+	page := &models.SessionPageResponse{
+		Items:      make([]models.SessionResponse, len(tokens.Items)),
+		NextCursor: tokens.NextCursor,
+	}
+	for i := range tokens.Items {
+		page.Items[i] = models.NewSessionResponse(&tokens.Items[i])
+	}
+	return page, nil
+}
+
+// Detail возвращает пользователя арендатора вместе с его ролью участника и сессиями в арендаторе.
+func (s *UserService) Detail(ctx context.Context, tenantID uint, guid, role string) (*models.UserDetailResponse, error) {
+	user, err := s.repo.FindByGUID(tenantID, guid)
 	if err != nil {
 		return nil, err
 	}
+	tokens, err := s.tokens.GetByUserGUID(ctx, tenantID, guid)
+	if err != nil {
+		return nil, err
+	}
+	detail := &models.UserDetailResponse{
+		AdminUserResponse: models.NewAdminUserResponse(user),
+		Role:              role,
+		Sessions:          make([]models.SessionResponse, len(tokens)),
+	}
+	for i := range tokens {
+		detail.Sessions[i] = models.NewSessionResponse(&tokens[i])
+	}
+	return detail, nil
+}
+
+func (s *UserService) Disable(ctx context.Context, guid string) error {
+	if err := s.repo.SetDisabled(guid, true); err != nil {
+		return err
+	}
+	return s.tokens.DeleteByUserGUID(ctx, guid)
+}
+
+func (s *UserService) Enable(guid string) error {
+	return s.repo.SetDisabled(guid, false)
+}
+
+func (s *UserService) Delete(ctx context.Context, guid string) error {
+	if err := s.repo.Delete(guid); err != nil {
+		return err
+	}
+	return s.tokens.DeleteByUserGUID(ctx, guid)
+}
 
-	result := make([]models.UserResponse, len(users))
-	for i, u := range users {
-		result[i] = models.NewUserResponse(u.Guid)
+// Logout завершает все сессии пользователя. Сессии удаляются и в хранилище сессий:
+// оно может быть отдельным от базы пользователей (Redis).
+func (s *UserService) Logout(ctx context.Context, guid string) error {
+	if err := s.repo.RevokeSessions(guid); err != nil {
+		return err
 	}
-	return result, nil
+	return s.tokens.DeleteByUserGUID(ctx, guid)
 }
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"auth-service/config"
+	"auth-service/logging"
+	"auth-service/models"
+	"auth-service/repositories"
+	"auth-service/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrWebhookQueued = errors.New("webhook delivery is already queued")
+
+// WebhookService ставит webhook'и в outbox и управляет доставками. Отправляет их
+// webhook.Dispatcher; wake сообщает ему о новых доставках.
+type WebhookService struct {
+	repo    repositories.WebhookRepository
+	enabled bool
+	wake    func()
+}
+
+func NewWebhookService(repo repositories.WebhookRepository, wake func(), c config.Config) *WebhookService {
+	return &WebhookService{repo: repo, enabled: c.Webhook.Url != "", wake: wake}
+}
+
+// NewDelivery готовит событие event с телом payload к постановке в очередь из запроса ctx.
+// Без webhook.url возвращает nil: webhook'и отключены.
+func (s *WebhookService) NewDelivery(ctx context.Context, event string, payload any) (*models.WebhookDelivery, error) {
+	if !s.enabled {
+		return nil, nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &models.WebhookDelivery{
+		Event:         event,
+		Payload:       models.RawJSON(data),
+		Status:        models.WebhookPending,
+		NextAttemptAt: time.Now(),
+		RequestID:     logging.RequestID(ctx),
+		TraceParent:   tracing.Traceparent(ctx),
+	}, nil
+}
+
+// Enqueue ставит доставки в очередь отдельно от других изменений.
+func (s *WebhookService) Enqueue(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := s.repo.Enqueue(ctx, deliveries); err != nil {
+		return err
+	}
+	s.Queued()
+	return nil
+}
+
+// Queued сообщает о доставках, поставленных в очередь вместе с другими изменениями
+// (repositories.OutboxTokenRepository).
+func (s *WebhookService) Queued() {
+	if s.wake != nil {
+		s.wake()
+	}
+}
+
+// Search возвращает страницу доставок по фильтру; по умолчанию - от новых к старым.
+func (s *WebhookService) Search(ctx context.Context, filter models.WebhookSearch) (*models.WebhookPageResponse, error) {
+	q := listQuery(filter.ListRequest)
+	if filter.Status != "" {
+		q.Filters = append(q.Filters, repositories.Filter{Field: "status", Op: repositories.OpEq, Value: filter.Status})
+	}
+	if filter.Event != "" {
+		q.Filters = append(q.Filters, repositories.Filter{Field: "event", Op: repositories.OpEq, Value: filter.Event})
+	}
+	page, err := s.repo.List(ctx, q)
+	if err != nil {
+		return nil, listError(err)
+	}
+	return &models.WebhookPageResponse{Items: page.Items, NextCursor: page.NextCursor}, nil
+}
+
+func (s *WebhookService) GetDelivery(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	return s.repo.Find(ctx, id)
+}
+
+// Replay возвращает доставку в очередь с полным набором попыток: недоставленную или, например,
+// потерянную получателем. Доставку, которая ещё в очереди, не меняет и возвращает ErrWebhookQueued.
+func (s *WebhookService) Replay(ctx context.Context, id uint) (*models.WebhookDelivery, error) {
+	replayed, err := s.repo.Replay(ctx, id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !replayed {
+		return nil, ErrWebhookQueued
+	}
+	s.Queued()
+	return s.repo.Find(ctx, id)
+}
+
+// ReplayDead возвращает в очередь все недоставленные доставки события event (пусто - любого).
+func (s *WebhookService) ReplayDead(ctx context.Context, event string) (int64, error) {
+	n, err := s.repo.ReplayDead(ctx, event, time.Now())
+	if n > 0 {
+		s.Queued()
+	}
+	return n, err
+}
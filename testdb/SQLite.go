@@ -0,0 +1,28 @@
+// Package testdb - базы данных для тестов.
+package testdb
+
+import (
+	"auth-service/connections"
+	"auth-service/models"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// SQLite открывает временную базу SQLite со схемой models.MigrateDB и арендатором по умолчанию.
+// Глобальное соединение connections.DB не меняется; база закрывается в конце теста.
+func SQLite(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := connections.OpenSQLite(t.TempDir() + "/auth.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if pool, err := db.DB(); err == nil {
+			_ = pool.Close()
+		}
+	})
+	if err := models.MigrateDB(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
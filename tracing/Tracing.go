@@ -0,0 +1,130 @@
+// Package tracing - трассировка OpenTelemetry. Span'ы создаются в хендлерах и сервисах через
+// Start, запросы к базе и Redis получают дочерние span'ы от GormPlugin и RedisHook. Контекст
+// трассировки принимается и передаётся дальше в заголовке W3C traceparent.
+package tracing
+
+import (
+	"auth-service/config"
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const instrumentationName = "auth-service"
+
+// Start начинает span name, дочерний к span в ctx.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End завершает span и отмечает его ошибкой err, если она есть.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Traceparent возвращает заголовок traceparent span'а в ctx (пусто, если его нет), чтобы
+// продолжить трассу в работе, отложенной за пределы запроса, например в отправке из outbox.
+func Traceparent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// WithTraceparent возвращает ctx, span'ы в котором продолжают трассу traceparent.
+func WithTraceparent(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
+
+// Install делает provider глобальным и включает распространение W3C traceparent. Тесты
+// (routers/Tracing_test.go) устанавливают провайдер с tracetest.NewInMemoryExporter
+// и проверяют записанные span'ы.
+func Install(provider trace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+	installPropagator()
+}
+
+func installPropagator() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Setup настраивает трассировку по разделу tracing конфигурации и возвращает функцию,
+// отправляющую накопленные span'ы при остановке. При exporter: none span'ы не записываются,
+// но входящий traceparent передаётся в исходящие запросы.
+func Setup(c config.Config) (func(ctx context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch c.Tracing.Exporter {
+	case "", ExporterNone:
+		installPropagator()
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{}
+		if c.Tracing.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(c.Tracing.Endpoint))
+		}
+		if c.Tracing.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", c.Tracing.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(c.Tracing.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.Tracing.SampleRatio))),
+	)
+	Install(provider)
+	return provider.Shutdown, nil
+}
+
+// traced сообщает, идёт ли в ctx трассировка: запросы вне трассируемых операций (миграции,
+// фоновые задачи) не создают отдельных трасс на каждый SQL запрос.
+func traced(ctx context.Context) bool {
+	return ctx != nil && trace.SpanFromContext(ctx).SpanContext().IsValid()
+}
+
+// endIgnoring завершает span, не считая ошибкой ожидаемые результаты вроде "не найдено".
+func endIgnoring(span trace.Span, err error, expected ...error) {
+	for _, e := range expected {
+		if errors.Is(err, e) {
+			err = nil
+		}
+	}
+	End(span, err)
+}
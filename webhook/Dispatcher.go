@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"auth-service/config"
+	"auth-service/logging"
+	"auth-service/metrics"
+	"auth-service/models"
+	"auth-service/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// leaseMargin - запас аренды доставки сверх срока попытки на запись результата.
+const leaseMargin = 30 * time.Second
+
+// Dispatcher - пул воркеров, отправляющих доставки из outbox. Неудачная попытка повторяется
+// через экспоненциально растущую паузу со случайным разбросом; после MaxAttempts попыток
+// доставка переходит в dead и ждёт повторной постановки в очередь через административный API.
+// Доставки занимаются в базе на время попытки, поэтому реплики не отправляют одну доставку
+// одновременно, а доставка, прерванная падением реплики, повторяется после истечения аренды.
+type Dispatcher struct {
+	repo         repositories.WebhookRepository
+	url          string
+	client       *http.Client
+	workers      int
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	timeout      time.Duration
+	pollInterval time.Duration
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	busy     atomic.Int64
+}
+
+func NewDispatcher(repo repositories.WebhookRepository, c config.Config) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		repo:         repo,
+		url:          c.Webhook.Url,
+		client:       &http.Client{Timeout: c.Webhook.Timeout},
+		workers:      c.Webhook.Workers,
+		maxAttempts:  c.Webhook.MaxAttempts,
+		backoff:      c.Webhook.Backoff,
+		maxBackoff:   c.Webhook.MaxBackoff,
+		timeout:      c.Webhook.Timeout,
+		pollInterval: c.Webhook.PollInterval,
+		ctx:          ctx,
+		cancel:       cancel,
+		wake:         make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start запускает разбор очереди. Без webhook.url доставки не отправляются.
+func (d *Dispatcher) Start() {
+	if d.url == "" {
+		return
+	}
+	d.wg.Add(1)
+	go d.poll()
+}
+
+// Wake сообщает, что в очереди появились доставки, чтобы не ждать следующей проверки.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Flush прекращает разбор очереди и ждёт завершения начатых попыток до истечения ctx.
+// Оставшиеся попытки прерываются и не засчитываются: доставки остаются в очереди.
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	d.stopOnce.Do(func() { close(d.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		busy := d.busy.Load()
+		d.cancel()
+		<-done
+		return fmt.Errorf("%d webhook deliveries interrupted: %w", busy, ctx.Err())
+	}
+}
+
+// poll занимает доставки, пока есть свободные воркеры, и ждёт новых: по Wake, по завершении
+// попытки или раз в pollInterval - повторы, срок которых наступил, и доставки других реплик.
+func (d *Dispatcher) poll() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		if free := d.workers - int(d.busy.Load()); free > 0 && d.claim(free) == free {
+			// очередь могла не опустеть
+			continue
+		}
+		select {
+		case <-d.stop:
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) claim(limit int) int {
+	now := time.Now()
+	deliveries, err := d.repo.Claim(d.ctx, now, now.Add(d.timeout+leaseMargin), limit)
+	if err != nil {
+		if d.ctx.Err() == nil {
+			slog.Error("Failed to claim webhook deliveries", "error", err)
+		}
+		return 0
+	}
+	for _, delivery := range deliveries {
+		d.busy.Add(1)
+		d.wg.Add(1)
+		go d.deliver(delivery)
+	}
+	return len(deliveries)
+}
+
+// deliver выполняет одну попытку и записывает её результат.
+func (d *Dispatcher) deliver(delivery models.WebhookDelivery) {
+	defer d.wg.Done()
+	defer func() {
+		d.busy.Add(-1)
+		d.Wake()
+	}()
+	ctx := logging.WithRequestID(d.ctx, delivery.RequestID)
+	attemptCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	status, err := Post(attemptCtx, d.client, d.url, &delivery)
+	cancel()
+	// результат записывается и после отмены ctx при остановке
+	saveCtx := context.WithoutCancel(ctx)
+	log := slog.With("delivery_id", delivery.ID, "event", delivery.Event)
+
+	if err != nil && d.ctx.Err() != nil {
+		// попытку прервала остановка: доставка освобождается для следующего запуска
+		if err := d.repo.Save(saveCtx, &delivery); err != nil {
+			log.ErrorContext(ctx, "Failed to release webhook delivery", "error", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastStatus = status
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		metrics.WebhookDeliveries.WithLabelValues("success").Inc()
+		log.DebugContext(ctx, "Webhook delivered", "attempts", delivery.Attempts)
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = models.WebhookDead
+		delivery.LastError = err.Error()
+		metrics.WebhookDeliveries.WithLabelValues("dead").Inc()
+		log.ErrorContext(ctx, "Webhook delivery failed, giving up", "attempts", delivery.Attempts, "error", err)
+	default:
+		delay := d.retryDelay(delivery.Attempts)
+		delivery.NextAttemptAt = now.Add(delay)
+		delivery.LastError = err.Error()
+		metrics.WebhookDeliveries.WithLabelValues("retry").Inc()
+		log.WarnContext(ctx, "Webhook delivery failed, will retry",
+			"attempts", delivery.Attempts, "retry_in", delay.String(), "error", err)
+	}
+	if err := d.repo.Save(saveCtx, &delivery); errors.Is(err, repositories.ErrLeaseConflict) {
+		// доставку уже повторяет другая реплика: её результат важнее
+		log.WarnContext(ctx, "Webhook delivery lease lost, attempt result discarded", "attempts", delivery.Attempts)
+	} else if err != nil {
+		log.ErrorContext(ctx, "Failed to save webhook delivery", "error", err)
+	}
+}
+
+// retryDelay - пауза после attempt неудачных попыток: Backoff·2^(attempt-1), но не больше
+// MaxBackoff. Вторая половина паузы случайна, чтобы после сбоя получателя повторы накопившихся
+// доставок не приходили одновременно.
+func (d *Dispatcher) retryDelay(attempt int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempt && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.maxBackoff)
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,212 @@
+package webhook_test
+
+import (
+	"auth-service/config"
+	"auth-service/models"
+	"auth-service/repositories"
+	"auth-service/services"
+	"auth-service/testdb"
+	"auth-service/webhook"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// attempt - запрос, принятый тестовым получателем.
+type attempt struct {
+	at      time.Time
+	id      string
+	event   string
+	attempt int
+	body    string
+}
+
+// receiver - получатель webhook'ов, отвечающий кодом status(номер запроса с 1).
+type receiver struct {
+	mu       sync.Mutex
+	attempts []attempt
+	status   func(n int) int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	n, _ := strconv.Atoi(req.Header.Get("X-Webhook-Attempt"))
+	r.mu.Lock()
+	r.attempts = append(r.attempts, attempt{
+		at:      time.Now(),
+		id:      req.Header.Get("X-Webhook-ID"),
+		event:   req.Header.Get("X-Webhook-Event"),
+		attempt: n,
+		body:    string(body),
+	})
+	status := r.status(len(r.attempts))
+	r.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func (r *receiver) received() []attempt {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]attempt(nil), r.attempts...)
+}
+
+// start запускает получателя и диспетчер поверх тестовой базы и возвращает сервис, через
+// который доставки ставятся в очередь.
+func start(t *testing.T, r *receiver, maxAttempts int) (*services.WebhookService, repositories.WebhookRepository) {
+	t.Helper()
+	repo := repositories.NewWebhookRepository(testdb.SQLite(t))
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	var c config.Config
+	c.Webhook.Url = server.URL
+	c.Webhook.Workers = 2
+	c.Webhook.MaxAttempts = maxAttempts
+	c.Webhook.Backoff = 40 * time.Millisecond
+	c.Webhook.MaxBackoff = 80 * time.Millisecond
+	c.Webhook.Timeout = time.Second
+	c.Webhook.PollInterval = 10 * time.Millisecond
+
+	dispatcher := webhook.NewDispatcher(repo, c)
+	dispatcher.Start()
+	t.Cleanup(func() {
+		if err := dispatcher.Flush(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	return services.NewWebhookService(repo, dispatcher.Wake, c), repo
+}
+
+// enqueue ставит в очередь событие event и возвращает доставку.
+func enqueue(t *testing.T, s *services.WebhookService, event string) models.WebhookDelivery {
+	t.Helper()
+	d, err := s.NewDelivery(context.Background(), event, map[string]string{"user": "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliveries := []models.WebhookDelivery{*d}
+	if err := s.Enqueue(context.Background(), deliveries); err != nil {
+		t.Fatal(err)
+	}
+	return deliveries[0]
+}
+
+// waitFor ждёт, пока доставка id перейдёт в status, и возвращает её.
+func waitFor(t *testing.T, repo repositories.WebhookRepository, id uint, status string) *models.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		d, err := repo.Find(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Status == status && d.LockedUntil == nil {
+			return d
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery %d: status %s after %d attempts, want %s", id, d.Status, d.Attempts, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeliverySucceeds(t *testing.T) {
+	r := &receiver{status: func(int) int { return http.StatusNoContent }}
+	s, repo := start(t, r, 3)
+
+	queued := enqueue(t, s, "new_ip")
+	d := waitFor(t, repo, queued.ID, models.WebhookDelivered)
+
+	if d.Attempts != 1 || d.LastStatus != http.StatusNoContent || d.DeliveredAt == nil || d.LastError != "" {
+		t.Errorf("delivery = %+v", d)
+	}
+	got := r.received()
+	if len(got) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(got))
+	}
+	want := attempt{id: strconv.Itoa(int(queued.ID)), event: "new_ip", attempt: 1, body: `{"user":"alice"}`}
+	if got[0].id != want.id || got[0].event != want.event || got[0].attempt != want.attempt || got[0].body != want.body {
+		t.Errorf("request = %+v, want %+v", got[0], want)
+	}
+}
+
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+	// две ошибки 5xx, затем успех
+	r := &receiver{status: func(n int) int {
+		if n <= 2 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	}}
+	s, repo := start(t, r, 5)
+
+	queued := enqueue(t, s, "new_ip")
+	d := waitFor(t, repo, queued.ID, models.WebhookDelivered)
+
+	if d.Attempts != 3 || d.LastStatus != http.StatusOK || d.LastError != "" {
+		t.Errorf("delivery = %+v", d)
+	}
+	got := r.received()
+	if len(got) != 3 {
+		t.Fatalf("receiver got %d requests, want 3", len(got))
+	}
+	for i, a := range got {
+		if a.attempt != i+1 || a.id != got[0].id {
+			t.Errorf("request %d: attempt %d, id %s; want attempt %d, id %s", i, a.attempt, a.id, i+1, got[0].id)
+		}
+	}
+	// паузы - не меньше половины backoff·2^(n-1): 20 и 40 мс
+	for i, least := range []time.Duration{20 * time.Millisecond, 40 * time.Millisecond} {
+		if gap := got[i+1].at.Sub(got[i].at); gap < least {
+			t.Errorf("pause before attempt %d = %s, want at least %s", i+2, gap, least)
+		}
+	}
+}
+
+func TestDeliveryDeadAndReplay(t *testing.T) {
+	var mu sync.Mutex
+	up := false
+	r := &receiver{status: func(int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		if up {
+			return http.StatusOK
+		}
+		return http.StatusInternalServerError
+	}}
+	s, repo := start(t, r, 3)
+
+	queued := enqueue(t, s, "new_ip")
+	d := waitFor(t, repo, queued.ID, models.WebhookDead)
+	if d.Attempts != 3 || d.LastStatus != http.StatusInternalServerError || d.LastError == "" || d.DeliveredAt != nil {
+		t.Errorf("dead delivery = %+v", d)
+	}
+	if n := len(r.received()); n != 3 {
+		t.Fatalf("receiver got %d requests, want 3", n)
+	}
+	// dead доставка больше не отправляется
+	time.Sleep(100 * time.Millisecond)
+	if n := len(r.received()); n != 3 {
+		t.Errorf("dead delivery sent again: %d requests", n)
+	}
+
+	mu.Lock()
+	up = true
+	mu.Unlock()
+	if _, err := s.Replay(context.Background(), queued.ID); err != nil {
+		t.Fatal(err)
+	}
+	d = waitFor(t, repo, queued.ID, models.WebhookDelivered)
+	if d.Attempts != 1 || d.LastStatus != http.StatusOK {
+		t.Errorf("replayed delivery = %+v", d)
+	}
+	got := r.received()
+	if len(got) != 4 || got[3].attempt != 1 || got[3].id != got[0].id {
+		t.Errorf("replayed request = %+v, want attempt 1 of delivery %s", got[len(got)-1], got[0].id)
+	}
+}
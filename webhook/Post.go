@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"auth-service/models"
+	"auth-service/tracing"
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Post отправляет одну попытку доставки d на url и возвращает код ответа. Успех - только 2xx.
+// X-Webhook-ID одинаков во всех попытках доставки: по нему получатель отбрасывает повторы.
+// Span отправки продолжает трассу запроса, в котором возникло событие.
+func Post(ctx context.Context, client *http.Client, url string, d *models.WebhookDelivery) (status int, err error) {
+	ctx, span := tracing.Start(tracing.WithTraceparent(ctx, d.TraceParent), "webhook.Post",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("webhook.event", d.Event),
+			attribute.Int64("webhook.delivery_id", int64(d.ID)),
+			attribute.Int("webhook.attempt", d.Attempts+1),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(string(d.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", strconv.FormatUint(uint64(d.ID), 10))
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Attempt", strconv.Itoa(d.Attempts+1))
+	if d.RequestID != "" {
+		req.Header.Set("X-Request-ID", d.RequestID)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// дочитанный ответ позволяет переиспользовать соединение
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}